@@ -6,31 +6,29 @@ import (
 	"fmt"
 	"log"
 	"subscription/internal/config"
+	"subscription/internal/storage/connect"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 func main() {
 	cfg := config.MustLoad()
+	// Migrations may run longer than any query of the service.
+	cfg.StatementTimeout = 0
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName)
-	db, err := sql.Open("postgres", connStr)
+	db, err := connect.Open(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	err = db.Ping()
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	fmt.Println("Connected to the database!")
 
-	err = runMigrations(db, cfg.MigrationsPath)
+	err = runMigrations(db, cfg.StorageDriver, cfg.MigrationsPath)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
@@ -39,15 +37,25 @@ func main() {
 
 }
 
-func runMigrations(db *sql.DB, migrationsPath string) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+func runMigrations(db *sql.DB, driverName, migrationsPath string) error {
+	var (
+		driver database.Driver
+		err    error
+	)
+
+	switch driverName {
+	case config.StorageSQLite:
+		driver, err = migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	default:
+		driver, err = postgres.WithInstance(db, &postgres.Config{})
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create migration driver: %w", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
 		migrationsPath,
-		"postgres",
+		driverName,
 		driver,
 	)
 	if err != nil {
@@ -0,0 +1,65 @@
+package dispatcher
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/lib/logger/sl"
+	"time"
+)
+
+// batchSize is how many deliveries are attempted per call to the service.
+const batchSize = 100
+
+type Service interface {
+	Dispatch(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// Dispatcher sends queued webhook deliveries.
+type Dispatcher struct {
+	log      *slog.Logger
+	service  Service
+	interval time.Duration
+}
+
+func New(service Service, log *slog.Logger, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		log:      log.With(slog.String("component", "dispatcher")),
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run dispatches on start and then every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch keeps going while batches come back full, so a backlog drains
+// without waiting for the next tick.
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		sent, err := d.service.Dispatch(ctx, time.Now().UTC(), batchSize)
+		if err != nil {
+			d.log.Error("failed to dispatch webhook deliveries", sl.Err(err))
+			return
+		}
+
+		if sent > 0 {
+			d.log.Debug("attempted webhook deliveries", slog.Int("count", sent))
+		}
+		if sent < batchSize {
+			return
+		}
+	}
+}
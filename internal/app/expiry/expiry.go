@@ -0,0 +1,57 @@
+package expiry
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/lib/logger/sl"
+	"time"
+)
+
+type Service interface {
+	ScanExpiries(ctx context.Context, now time.Time, notice time.Duration) (int, error)
+}
+
+// Scanner announces subscriptions that are about to end or ended.
+type Scanner struct {
+	log      *slog.Logger
+	service  Service
+	notice   time.Duration
+	interval time.Duration
+}
+
+func New(service Service, log *slog.Logger, notice, interval time.Duration) *Scanner {
+	return &Scanner{
+		log:      log.With(slog.String("component", "expiry")),
+		service:  service,
+		notice:   notice,
+		interval: interval,
+	}
+}
+
+// Run scans on start and then every interval until ctx is cancelled.
+func (s *Scanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.scan(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scanner) scan(ctx context.Context) {
+	queued, err := s.service.ScanExpiries(ctx, time.Now().UTC(), s.notice)
+	if err != nil {
+		s.log.Error("failed to scan expiring subscriptions", sl.Err(err))
+		return
+	}
+
+	if queued > 0 {
+		s.log.Info("queued expiry notices", slog.Int("count", queued))
+	}
+}
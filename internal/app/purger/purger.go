@@ -0,0 +1,59 @@
+package purger
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/lib/actor"
+	"subscription/internal/lib/logger/sl"
+	"time"
+)
+
+type Service interface {
+	PurgeDeleted(ctx context.Context, retention time.Duration) ([]int64, error)
+}
+
+// Purger permanently removes soft-deleted subscriptions once their
+// retention window is over.
+type Purger struct {
+	log       *slog.Logger
+	service   Service
+	retention time.Duration
+	interval  time.Duration
+}
+
+func New(service Service, log *slog.Logger, retention, interval time.Duration) *Purger {
+	return &Purger{
+		log:       log.With(slog.String("component", "purger")),
+		service:   service,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges on start and then every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	ids, err := p.service.PurgeDeleted(actor.WithActor(ctx, actor.System), p.retention)
+	if err != nil {
+		p.log.Error("failed to purge deleted subscriptions", sl.Err(err))
+		return
+	}
+
+	if len(ids) > 0 {
+		p.log.Info("purged deleted subscriptions", slog.Int("count", len(ids)), slog.Any("ids", ids))
+	}
+}
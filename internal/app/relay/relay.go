@@ -0,0 +1,65 @@
+package relay
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/lib/logger/sl"
+	"time"
+)
+
+// batchSize is how many outbox events are published at once.
+const batchSize = 500
+
+type Service interface {
+	Relay(ctx context.Context, limit int) (int, error)
+}
+
+// Relay publishes the outbox to the message broker.
+type Relay struct {
+	log      *slog.Logger
+	service  Service
+	interval time.Duration
+}
+
+func New(service Service, log *slog.Logger, interval time.Duration) *Relay {
+	return &Relay{
+		log:      log.With(slog.String("component", "relay")),
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run relays on start and then every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.relay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay keeps publishing batches while they come back full, so a backlog
+// drains without waiting for the next tick.
+func (r *Relay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.service.Relay(ctx, batchSize)
+		if err != nil {
+			r.log.Error("failed to relay outbox", sl.Err(err))
+			return
+		}
+
+		if published > 0 {
+			r.log.Debug("published outbox events", slog.Int("count", published))
+		}
+		if published < batchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package renewer
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/lib/logger/sl"
+	"time"
+)
+
+type Service interface {
+	Renew(ctx context.Context, now time.Time) (int, error)
+}
+
+// Renewer emits billing events for subscriptions reaching a new billing
+// period.
+type Renewer struct {
+	log      *slog.Logger
+	service  Service
+	interval time.Duration
+}
+
+func New(service Service, log *slog.Logger, interval time.Duration) *Renewer {
+	return &Renewer{
+		log:      log.With(slog.String("component", "renewer")),
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run renews on start and then every interval until ctx is cancelled.
+func (r *Renewer) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.renew(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Renewer) renew(ctx context.Context) {
+	emitted, err := r.service.Renew(ctx, time.Now().UTC())
+	if err != nil {
+		r.log.Error("failed to renew subscriptions", sl.Err(err))
+		return
+	}
+
+	if emitted > 0 {
+		r.log.Info("emitted billing events", slog.Int("count", emitted))
+	}
+}
@@ -1,16 +1,49 @@
 package rest
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"subscription/internal/app/dispatcher"
+	"subscription/internal/app/expiry"
+	"subscription/internal/app/purger"
+	"subscription/internal/app/relay"
+	"subscription/internal/app/renewer"
 	"subscription/internal/config"
+	"subscription/internal/domain"
 	"subscription/internal/http_server/handler"
+	"subscription/internal/http_server/middleware/actor"
+	authmw "subscription/internal/http_server/middleware/auth"
+	idempotencymw "subscription/internal/http_server/middleware/idempotency"
 	"subscription/internal/http_server/middleware/logger"
+	metricsmw "subscription/internal/http_server/middleware/metrics"
+	"subscription/internal/http_server/middleware/primary"
+	ratelimitmw "subscription/internal/http_server/middleware/ratelimit"
+	tenantmw "subscription/internal/http_server/middleware/tenant"
+	tracingmw "subscription/internal/http_server/middleware/tracing"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/broker"
+	"subscription/internal/lib/cache"
+	"subscription/internal/lib/fx"
+	"subscription/internal/lib/health"
+	"subscription/internal/lib/idempotency"
 	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/metrics"
+	"subscription/internal/lib/ratelimit"
+	"subscription/internal/lib/tracing"
+	"subscription/internal/lib/webhook"
+	st "subscription/internal/storage"
+	"subscription/internal/storage/cached"
+	"subscription/internal/storage/connect"
+	"subscription/internal/storage/instrumented"
+	"subscription/internal/storage/memory"
 	"subscription/internal/storage/postgres"
+	"subscription/internal/storage/sqlite"
 	"subscription/internal/usecases"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,35 +51,182 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// tracingFlushTimeout bounds how long stopping waits for the last spans to
+// be exported.
+const tracingFlushTimeout = 5 * time.Second
+
 type App struct {
-	log *slog.Logger
-	cfg *config.Config
-	srv *http.Server
+	log        *slog.Logger
+	cfg        *config.Config
+	srv        *http.Server
+	purger     *purger.Purger
+	renewer    *renewer.Renewer
+	dispatcher *dispatcher.Dispatcher
+	expiry     *expiry.Scanner
+	relay      *relay.Relay
+	publisher  publisher
+	// stopTracing flushes the spans not exported yet.
+	stopTracing func(context.Context) error
+	storage     storageBackend
+	replicas    *st.Replicas
+	health      *health.Checker
+
+	// ctx scopes the background jobs started by Run, wg waits for them.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 func New(cfg *config.Config, log *slog.Logger) *App {
-	storage, err := postgres.New(cfg.DbConfig)
+	storage, replicas, err := newStorage(cfg, log)
 	if err != nil {
 		log.Error("failed to init storage: ", sl.Err(err))
 		os.Exit(1)
 	}
 
-	subscriptionService := usecases.NewSubscriptionService(storage, log)
-	subscriptionHandler := handler.NewUserSubscriptionHandler(subscriptionService, log, cfg.HTTPServer.Timeout)
+	m := metrics.New()
+	if db, driver, ok := database(storage); ok {
+		if err := m.RegisterDB(db, driver); err != nil {
+			log.Error("failed to init metrics: ", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.ServiceName,
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Error("failed to init tracing: ", sl.Err(err))
+		os.Exit(1)
+	}
+
+	checker, err := newHealthChecker(cfg, storage)
+	if err != nil {
+		log.Error("failed to init readiness checks: ", sl.Err(err))
+		os.Exit(1)
+	}
+
+	rates, err := newRatesProvider(cfg)
+	if err != nil {
+		log.Error("failed to init exchange rates: ", sl.Err(err))
+		os.Exit(1)
+	}
+
+	publisher, err := newPublisher(cfg, log)
+	if err != nil {
+		log.Error("failed to init event publisher: ", sl.Err(err))
+		os.Exit(1)
+	}
+
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		log.Error("failed to init authentication: ", sl.Err(err))
+		os.Exit(1)
+	}
+	if authenticator == nil {
+		log.Warn("authentication is disabled, the API is open to anyone")
+	}
+
+	ipLimiter, rateLimiter, err := newRateLimiters(cfg, storage, log)
+	if err != nil {
+		log.Error("failed to init rate limiting: ", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var subscriptions usecases.SubscriptionStorage = instrumented.NewSubscriptionStorage(storage, m)
+	if cfg.CacheEnabled {
+		subscriptions = cached.NewSubscriptionStorage(subscriptions, cache.NewLRU(cfg.CacheSize), cfg.CacheTTL, m, log)
+	}
+
+	subscriptionService := usecases.NewSubscriptionService(subscriptions, storage, storage, storage, storage, rates, log)
+	subscriptionHandler := handler.NewUserSubscriptionHandler(subscriptionService, log, cfg.HTTPServer.Timeout, cfg.ImportTimeout)
+
+	webhookService := usecases.NewWebhookService(
+		storage,
+		webhook.NewSender(cfg.WebhookTimeout),
+		usecases.DeliveryPolicy{
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			RetryBase:   cfg.WebhookRetryBase,
+			RetryMax:    cfg.WebhookRetryMax,
+			// Long enough for the attempt to time out and be recorded.
+			Lease: 2 * cfg.WebhookTimeout,
+		},
+		log,
+	)
+	webhookHandler := handler.NewWebhookHandler(webhookService, log, cfg.HTTPServer.Timeout)
+
+	idempotent := idempotencymw.New(storage, idempotencymw.Options{
+		TTL: cfg.IdempotencyKeyTTL,
+		// Long enough for the first request to time out.
+		Lock: 2 * cfg.HTTPServer.Timeout,
+	}, log)
+
+	healthHandler := handler.NewHealthHandler(checker, log, cfg.HTTPServer.Timeout)
+
+	tenantService := usecases.NewTenantService(storage, log)
+	tenantHandler := handler.NewTenantHandler(tenantService, log, cfg.HTTPServer.Timeout)
 
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	router.Use(tracingmw.New())
+	router.Use(metricsmw.New(m))
 	router.Use(middleware.Logger)
 	router.Use(logger.New(log))
+	router.Use(actor.New())
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
-	router.Post("/subscriptions", subscriptionHandler.AddUserSubscriptionHandler)
-	router.Get("/subscriptions/{id}", subscriptionHandler.GetUserSubscriptionHandler)
-	router.Get("/subscriptions", subscriptionHandler.GetListUserSubscriptionHandler)
-	router.Delete("/subscriptions/{id}", subscriptionHandler.DeleteUserSubscriptionHandler)
-	router.Put("/subscriptions", subscriptionHandler.UpdateSubscriptionHandler)
-	router.Get("/subscriptions/total_cost", subscriptionHandler.GetTotalCostHandler)
+	router.Group(func(router chi.Router) {
+		if ipLimiter != nil {
+			router.Use(ipLimiter)
+		}
+		if authenticator != nil {
+			router.Use(authmw.New(authenticator, log))
+		}
+		router.Use(tenantmw.New(cfg.TenantHeader, cfg.DefaultTenant, log))
+		if rateLimiter != nil {
+			router.Use(rateLimiter)
+		}
+		if replicas != nil && cfg.ReadYourWrites > 0 {
+			router.Use(primary.New(cfg.ReadYourWrites))
+		}
+
+		router.With(idempotent).Post("/subscriptions", subscriptionHandler.AddUserSubscriptionHandler)
+		router.Post("/subscriptions/import", subscriptionHandler.ImportUserSubscriptionsHandler)
+		router.Get("/subscriptions/{id}", subscriptionHandler.GetUserSubscriptionHandler)
+		router.Get("/subscriptions", subscriptionHandler.GetListUserSubscriptionHandler)
+		router.Get("/subscriptions/export", subscriptionHandler.ExportUserSubscriptionsHandler)
+		router.Delete("/subscriptions/{id}", subscriptionHandler.DeleteUserSubscriptionHandler)
+		router.Post("/subscriptions/{id}/restore", subscriptionHandler.RestoreUserSubscriptionHandler)
+		router.Put("/subscriptions", subscriptionHandler.UpdateSubscriptionHandler)
+		router.Patch("/subscriptions/{id}", subscriptionHandler.PatchSubscriptionHandler)
+		router.Get("/subscriptions/{id}/history", subscriptionHandler.GetUserSubscriptionHistoryHandler)
+		router.Get("/subscriptions/{id}/billing_events", subscriptionHandler.GetBillingEventsHandler)
+		router.Get("/subscriptions/total_cost", subscriptionHandler.GetTotalCostHandler)
+		router.Get("/subscriptions/analytics", subscriptionHandler.GetCostAnalyticsHandler)
+
+		router.Group(func(router chi.Router) {
+			router.Use(authmw.RequireAdmin())
+
+			router.Post("/webhooks", webhookHandler.AddWebhookHandler)
+			router.Get("/webhooks", webhookHandler.ListWebhooksHandler)
+			router.Get("/webhooks/{id}", webhookHandler.GetWebhookHandler)
+			router.Delete("/webhooks/{id}", webhookHandler.DeleteWebhookHandler)
+			router.Get("/webhooks/{id}/deliveries", webhookHandler.ListWebhookDeliveriesHandler)
+			router.Get("/webhooks/deliveries/{id}", webhookHandler.GetWebhookDeliveryHandler)
+			router.Post("/webhooks/deliveries/{id}/redeliver", webhookHandler.RedeliverWebhookDeliveryHandler)
+
+			router.Get("/tenants", tenantHandler.ListTenantsHandler)
+			router.Get("/tenants/{tenant}", tenantHandler.GetTenantHandler)
+		})
+	})
+
+	router.Handle(cfg.MetricsPath, m.Handler())
+	router.Get("/healthz", healthHandler.HealthzHandler)
+	router.Get("/readyz", healthHandler.ReadyzHandler)
 
 	router.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
@@ -60,10 +240,218 @@ func New(cfg *config.Config, log *slog.Logger) *App {
 		IdleTimeout:  cfg.IdleTimeout * time.Second,
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &App{
-		log: log,
-		cfg: cfg,
-		srv: srv,
+		ctx:        ctx,
+		cancel:     cancel,
+		log:        log,
+		cfg:        cfg,
+		srv:        srv,
+		purger:     purger.New(subscriptionService, log, cfg.Retention, cfg.PurgeInterval),
+		renewer:    renewer.New(subscriptionService, log, cfg.RenewalInterval),
+		dispatcher: dispatcher.New(webhookService, log, cfg.DispatchInterval),
+		expiry:     expiry.New(webhookService, log, cfg.ExpiryNotice, cfg.ExpiryScanInterval),
+		relay:      relay.New(usecases.NewOutboxRelay(storage, publisher, cfg.PublishTimeout, log), log, cfg.RelayInterval),
+		publisher:  publisher,
+
+		stopTracing: stopTracing,
+		storage:     storage,
+		replicas:    replicas,
+		health:      checker,
+	}
+}
+
+type storageBackend interface {
+	usecases.SubscriptionStorage
+	usecases.HistoryStorage
+	usecases.BillingEventStorage
+	usecases.WebhookStorage
+	usecases.OutboxStorage
+	usecases.TenantStorage
+	idempotency.Store
+	Close() error
+}
+
+// newStorage also returns the read replicas of the postgres storage, nil
+// when there are none.
+func newStorage(cfg *config.Config, log *slog.Logger) (storageBackend, *st.Replicas, error) {
+	switch cfg.StorageDriver {
+	case config.StorageMemory:
+		return memory.New(), nil, nil
+	case config.StorageSQLite:
+		db, err := connect.Open(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sqlite.New(db), nil, nil
+	case config.StoragePostgres:
+		db, err := connect.Open(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var replicas *st.Replicas
+		if len(cfg.ReplicaURLs) > 0 {
+			dbs, err := connect.OpenReplicas(cfg.DbConfig)
+			if err != nil {
+				db.Close()
+				return nil, nil, err
+			}
+			replicas = st.NewReplicas(dbs, cfg.ReplicaCheckInterval, log)
+		}
+
+		return postgres.New(db, replicas), replicas, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// sqlStorage is implemented by the storages kept in a migrated database.
+type sqlStorage interface {
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (uint, bool, error)
+}
+
+// newHealthChecker checks that the database answers and that its schema is
+// at the version of the last migration of MIGRATIONS_PATH.
+func newHealthChecker(cfg *config.Config, storage storageBackend) (*health.Checker, error) {
+	checker := health.NewChecker()
+
+	db, ok := storage.(sqlStorage)
+	if !ok {
+		return checker, nil
+	}
+
+	expected, err := st.LatestMigration(cfg.MigrationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	checker.Add("database", db.Ping)
+	checker.Add("migrations", func(ctx context.Context) error {
+		version, dirty, err := db.MigrationVersion(ctx)
+		switch {
+		case err != nil:
+			return err
+		case dirty:
+			return fmt.Errorf("migration %d failed halfway", version)
+		case version != expected:
+			return fmt.Errorf("schema at version %d, expected %d", version, expected)
+		}
+		return nil
+	})
+
+	return checker, nil
+}
+
+// database returns the connection pool of the SQL storages.
+func database(storage storageBackend) (*sql.DB, string, bool) {
+	switch s := storage.(type) {
+	case *postgres.Storage:
+		return s.DB, config.StoragePostgres, true
+	case *sqlite.Storage:
+		return s.DB, config.StorageSQLite, true
+	default:
+		return nil, "", false
+	}
+}
+
+type publisher interface {
+	usecases.EventPublisher
+	Close() error
+}
+
+func newPublisher(cfg *config.Config, log *slog.Logger) (publisher, error) {
+	switch cfg.Publisher {
+	case config.PublisherLog:
+		return broker.NewLog(log), nil
+	case config.PublisherKafka:
+		return broker.NewKafka(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.PublishTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown publisher %q", cfg.Publisher)
+	}
+}
+
+// newAuthenticator returns nil when authentication is disabled.
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	if !cfg.AuthEnabled {
+		return nil, nil
+	}
+
+	opts := auth.Options{
+		APIKeys:     cfg.APIKeys,
+		HS256Secret: cfg.JWTSecret,
+		Issuer:      cfg.JWTIssuer,
+		Audience:    cfg.JWTAudience,
+		AdminRole:   cfg.AdminRole,
+		TenantClaim: cfg.TenantClaim,
+	}
+	if cfg.JWKSFile != "" {
+		jwks, err := auth.NewJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.JWKS = jwks
+	}
+
+	return auth.New(opts), nil
+}
+
+// rateLimitStorage is implemented by the storages able to share rate limits
+// between replicas.
+type rateLimitStorage interface {
+	TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error)
+}
+
+// newRateLimiters returns the limiter of IP addresses, mounted before
+// authentication, and the limiter of clients, mounted after. Both are nil
+// when rate limiting is disabled.
+func newRateLimiters(cfg *config.Config, storage storageBackend, log *slog.Logger) (ip, client func(http.Handler) http.Handler, err error) {
+	if !cfg.RateLimitEnabled {
+		return nil, nil, nil
+	}
+
+	ipOpts := ratelimitmw.Options{Key: ratelimitmw.ByIP}
+	if ipOpts.Default, err = ratelimit.ParseLimit(cfg.RateLimitIP); err != nil {
+		return nil, nil, err
+	}
+
+	opts := ratelimitmw.Options{Routes: make(map[string]ratelimit.Limit, len(cfg.RateLimitRoutes))}
+	if opts.Default, err = ratelimit.ParseLimit(cfg.RateLimitDefault); err != nil {
+		return nil, nil, err
+	}
+	for route, limit := range cfg.RateLimitRoutes {
+		if opts.Routes[route], err = ratelimit.ParseLimit(limit); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var store ratelimit.Store
+	switch cfg.RateLimitStore {
+	case config.RateLimitMemory:
+		store = ratelimit.NewMemory()
+	case config.RateLimitStorage:
+		shared, ok := storage.(rateLimitStorage)
+		if !ok {
+			return nil, nil, fmt.Errorf("the %s storage can't share rate limits", cfg.StorageDriver)
+		}
+		store = ratelimit.StoreFunc(shared.TakeRateLimitToken)
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit store %q", cfg.RateLimitStore)
+	}
+
+	return ratelimitmw.New(store, ipOpts, log), ratelimitmw.New(store, opts, log), nil
+}
+
+func newRatesProvider(cfg *config.Config) (fx.Provider, error) {
+	switch {
+	case cfg.RatesFile != "":
+		return fx.NewFileProvider(cfg.RatesFile)
+	case cfg.RatesURL != "":
+		return fx.NewHTTPProvider(cfg.RatesURL, cfg.RatesTTL), nil
+	default:
+		return fx.Static(&fx.Rates{Base: domain.DefaultCurrency, Date: time.Now().UTC()}), nil
 	}
 }
 
@@ -77,6 +465,35 @@ func (a *App) Run() error {
 	url := fmt.Sprintf("http://%s/swagger/index.html", a.cfg.Address)
 	a.log.Info("starting server", slog.String("url", url))
 
+	a.wg.Add(5)
+	go func() {
+		defer a.wg.Done()
+		a.purger.Run(a.ctx)
+	}()
+	go func() {
+		defer a.wg.Done()
+		a.renewer.Run(a.ctx)
+	}()
+	go func() {
+		defer a.wg.Done()
+		a.dispatcher.Run(a.ctx)
+	}()
+	go func() {
+		defer a.wg.Done()
+		a.expiry.Run(a.ctx)
+	}()
+	go func() {
+		defer a.wg.Done()
+		a.relay.Run(a.ctx)
+	}()
+	if a.replicas != nil {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.replicas.Run(a.ctx)
+		}()
+	}
+
 	if err := a.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		a.log.Error("server error", slog.Any("err", err))
 		return err
@@ -85,9 +502,38 @@ func (a *App) Run() error {
 	return nil
 }
 
+// Stop fails readiness, waits for the shutdown delay, then stops taking
+// requests and waits for the ones in flight until the shutdown timeout, when
+// they are cut off. The background jobs, the publisher and the storage are
+// stopped after the server.
 func (a *App) Stop() {
-	a.log.Info("stopping server")
-	if err := a.srv.Close(); err != nil {
-		a.log.Error("failed to stop server", slog.Any("err", err))
+	a.log.Info("stopping server", slog.Duration("delay", a.cfg.ShutdownDelay))
+	a.health.Drain()
+	time.Sleep(a.cfg.ShutdownDelay)
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancelDrain()
+	if err := a.srv.Shutdown(drainCtx); err != nil {
+		a.log.Error("requests didn't finish in time", sl.Err(err))
+		if err := a.srv.Close(); err != nil {
+			a.log.Error("failed to stop server", slog.Any("err", err))
+		}
+	}
+
+	a.cancel()
+	a.wg.Wait()
+
+	if err := a.publisher.Close(); err != nil {
+		a.log.Error("failed to close event publisher", sl.Err(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	if err := a.stopTracing(ctx); err != nil {
+		a.log.Error("failed to flush traces", sl.Err(err))
+	}
+
+	if err := a.storage.Close(); err != nil {
+		a.log.Error("failed to close storage", sl.Err(err))
 	}
 }
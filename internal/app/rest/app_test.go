@@ -0,0 +1,447 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription/internal/config"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+const (
+	testSecret = "0123456789abcdef0123456789abcdef"
+	testAPIKey = "test-api-key-0123456789abcdef"
+)
+
+// newTestServer serves the API backed by the memory storage, with
+// authentication and rate limiting disabled unless configure enables them.
+func newTestServer(t *testing.T, configure func(cfg *config.Config)) *httptest.Server {
+	t.Helper()
+
+	t.Setenv("ENV", "local")
+
+	var cfg config.Config
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		t.Fatalf("can't read config: %v", err)
+	}
+	cfg.StorageDriver = config.StorageMemory
+	cfg.AuthEnabled = false
+	cfg.RateLimitEnabled = false
+	cfg.CacheEnabled = false
+	cfg.TracingExporter = "none"
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	app := New(&cfg, slog.New(slog.DiscardHandler))
+	srv := httptest.NewServer(app.srv.Handler)
+	t.Cleanup(func() {
+		srv.Close()
+		app.storage.Close()
+	})
+
+	return srv
+}
+
+func withAuth(cfg *config.Config) {
+	cfg.AuthEnabled = true
+	cfg.JWTSecret = testSecret
+	cfg.APIKeys = map[string]string{"ops": testAPIKey}
+}
+
+// client sends requests with the same headers.
+type client struct {
+	t      *testing.T
+	srv    *httptest.Server
+	header http.Header
+}
+
+func newClient(t *testing.T, srv *httptest.Server, kv ...string) *client {
+	c := &client{t: t, srv: srv, header: http.Header{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		c.header.Set(kv[i], kv[i+1])
+	}
+	return c
+}
+
+// do sends body, JSON encoded unless nil, and decodes the response into out
+// unless nil. It returns the status code.
+func (c *client) do(method, path string, body, out any) int {
+	c.t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("can't encode request: %v", err)
+		}
+		r = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, c.srv.URL+path, r)
+	if err != nil {
+		c.t.Fatalf("can't build request: %v", err)
+	}
+	req.Header = c.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.srv.Client().Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatalf("%s %s: can't read response: %v", method, path, err)
+	}
+	if out != nil && res.StatusCode < http.StatusBadRequest {
+		if err := json.Unmarshal(raw, out); err != nil {
+			c.t.Fatalf("%s %s: can't decode %s: %v", method, path, raw, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// create adds a subscription and returns its id, failing unless the status
+// is want.
+func (c *client) create(sub map[string]any, want int) int {
+	c.t.Helper()
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	if got := c.do(http.MethodPost, "/subscriptions", sub, &created); got != want {
+		c.t.Fatalf("POST /subscriptions %v: status %d, want %d", sub, got, want)
+	}
+	return created.ID
+}
+
+func subscription(user uuid.UUID, service, start, end string) map[string]any {
+	sub := map[string]any{
+		"service_name": service,
+		"price":        "100",
+		"user_id":      user,
+		"start_date":   start,
+	}
+	if end != "" {
+		sub["end_date"] = end
+	}
+	return sub
+}
+
+func token(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("can't sign token: %v", err)
+	}
+	return "Bearer " + signed
+}
+
+func TestSubscriptionConstraints(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newClient(t, srv)
+	user, other := uuid.New(), uuid.New()
+
+	first := c.create(subscription(user, "Netflix", "2025-01-01", "2025-06-30"), http.StatusCreated)
+
+	tests := []struct {
+		name   string
+		tenant string
+		sub    map[string]any
+		want   int
+	}{
+		{"same subscription", "", subscription(user, "Netflix", "2025-01-01", "2025-06-30"), http.StatusConflict},
+		{"overlapping", "", subscription(user, "Netflix", "2025-03-01", "2025-12-31"), http.StatusConflict},
+		{"within", "", subscription(user, "Netflix", "2025-02-01", "2025-02-28"), http.StatusConflict},
+		{"open-ended overlapping", "", subscription(user, "Netflix", "2024-01-01", ""), http.StatusConflict},
+		{"another service", "", subscription(user, "Spotify", "2025-01-01", "2025-06-30"), http.StatusCreated},
+		{"another user", "", subscription(other, "Netflix", "2025-01-01", "2025-06-30"), http.StatusCreated},
+		{"another tenant", "acme", subscription(user, "Netflix", "2025-01-01", "2025-06-30"), http.StatusCreated},
+		{"one day", "", subscription(user, "Hulu", "2025-01-01", "2025-01-01"), http.StatusCreated},
+		{"ending before it starts", "", subscription(user, "Kinopoisk", "2025-01-02", "2025-01-01"), http.StatusBadRequest},
+		{"right after", "", subscription(user, "Netflix", "2025-07-01", ""), http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(t, srv)
+			if tt.tenant != "" {
+				c.header.Set("X-Tenant-ID", tt.tenant)
+			}
+			c.create(tt.sub, tt.want)
+		})
+	}
+
+	t.Run("update into an overlap", func(t *testing.T) {
+		id := c.create(subscription(user, "Spotify", "2027-01-01", "2027-12-31"), http.StatusCreated)
+
+		update := subscription(user, "Spotify", "2026-01-01", "2026-12-31")
+		update["id"] = id
+		if got := c.do(http.MethodPut, "/subscriptions", update, nil); got != http.StatusCreated {
+			t.Fatalf("moving to free days: status %d, want %d", got, http.StatusCreated)
+		}
+
+		update["start_date"] = "2025-06-01"
+		if got := c.do(http.MethodPut, "/subscriptions", update, nil); got != http.StatusConflict {
+			t.Errorf("moving into an overlap: status %d, want %d", got, http.StatusConflict)
+		}
+	})
+
+	t.Run("deleted subscriptions free their days", func(t *testing.T) {
+		path := "/subscriptions/" + strconv.Itoa(first)
+		if got := c.do(http.MethodDelete, path, nil, nil); got != http.StatusOK {
+			t.Fatalf("DELETE %s: status %d, want %d", path, got, http.StatusOK)
+		}
+
+		c.create(subscription(user, "Netflix", "2025-01-01", "2025-06-30"), http.StatusCreated)
+
+		if got := c.do(http.MethodPost, path+"/restore", nil, nil); got != http.StatusConflict {
+			t.Errorf("restoring over the replacement: status %d, want %d", got, http.StatusConflict)
+		}
+	})
+}
+
+func TestListPagination(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newClient(t, srv)
+	user := uuid.New()
+
+	// Prices tie, so pages sorted by price must break ties by id.
+	prices := []string{"20", "10", "20", "30", "20", "10", "20"}
+	want := make(map[string]bool, len(prices))
+	for i, price := range prices {
+		sub := subscription(user, fmt.Sprintf("Service %d", i), "2025-01-01", "")
+		sub["price"] = price
+		want[strconv.Itoa(c.create(sub, http.StatusCreated))] = true
+	}
+	// Subscriptions of another user stay out of the listing.
+	c.create(subscription(uuid.New(), "Service 0", "2025-01-01", ""), http.StatusCreated)
+
+	for _, sort := range []struct{ by, order string }{{"id", "asc"}, {"id", "desc"}, {"price", "asc"}, {"price", "desc"}} {
+		t.Run(sort.by+" "+sort.order, func(t *testing.T) {
+			query := url.Values{
+				"user_id": {user.String()},
+				"sort_by": {sort.by},
+				"order":   {sort.order},
+				"limit":   {"2"},
+			}
+
+			var seen []*domain.UserSubscription
+			for pages := 0; ; pages++ {
+				if pages > len(prices) {
+					t.Fatalf("pagination doesn't end")
+				}
+
+				var page domain.UserSubscriptionPage
+				if got := c.do(http.MethodGet, "/subscriptions?"+query.Encode(), nil, &page); got != http.StatusOK {
+					t.Fatalf("GET /subscriptions: status %d, want %d", got, http.StatusOK)
+				}
+				if len(page.Items) > 2 {
+					t.Fatalf("page of %d items, limit is 2", len(page.Items))
+				}
+				seen = append(seen, page.Items...)
+
+				if page.NextCursor == "" {
+					break
+				}
+				query.Set("cursor", page.NextCursor)
+			}
+
+			if len(seen) != len(want) {
+				t.Fatalf("listed %d subscriptions, want %d", len(seen), len(want))
+			}
+			ids := make(map[string]bool, len(seen))
+			for i, sub := range seen {
+				if !want[sub.ID] || ids[sub.ID] {
+					t.Fatalf("subscription %s listed unexpectedly", sub.ID)
+				}
+				ids[sub.ID] = true
+
+				if i > 0 && !ordered(seen[i-1], sub, sort.by, sort.order) {
+					t.Errorf("subscription %s listed after %s, out of %s %s order", sub.ID, seen[i-1].ID, sort.by, sort.order)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		var page domain.UserSubscriptionPage
+		if got := c.do(http.MethodGet, "/subscriptions?limit=2", nil, &page); got != http.StatusOK || page.NextCursor == "" {
+			t.Fatalf("GET /subscriptions?limit=2: status %d, cursor %q", got, page.NextCursor)
+		}
+
+		for name, query := range map[string]string{
+			"garbage":      "cursor=not-a-cursor",
+			"another sort": "sort_by=price&cursor=" + page.NextCursor,
+			"text price":   "sort_by=price&order=asc&cursor=" + storage.EncodeCursor(storage.Cursor{SortBy: "price", Order: "asc", Value: "ten", ID: 1}),
+			"bad date":     "sort_by=start_date&order=asc&cursor=" + storage.EncodeCursor(storage.Cursor{SortBy: "start_date", Order: "asc", Value: "2025-13-01", ID: 1}),
+			"limit zero":   "limit=0",
+			"limit over":   "limit=1001",
+		} {
+			if got := c.do(http.MethodGet, "/subscriptions?"+query, nil, nil); got != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want %d", name, got, http.StatusBadRequest)
+			}
+		}
+	})
+}
+
+// ordered reports whether a may be listed before b.
+func ordered(a, b *domain.UserSubscription, by, order string) bool {
+	aID, _ := strconv.Atoi(a.ID)
+	bID, _ := strconv.Atoi(b.ID)
+
+	c := 0
+	if by == "price" {
+		c = a.Price.Cmp(b.Price)
+	}
+	if c == 0 {
+		c = aID - bID
+	}
+	if order == "desc" {
+		c = -c
+	}
+	return c < 0
+}
+
+func TestAuth(t *testing.T) {
+	srv := newTestServer(t, withAuth)
+	owner, stranger := uuid.New(), uuid.New()
+
+	admin := newClient(t, srv, "X-API-Key", testAPIKey)
+	id := admin.create(subscription(owner, "Netflix", "2025-01-01", ""), http.StatusCreated)
+	path := "/subscriptions/" + strconv.Itoa(id)
+
+	unauthenticated := []struct {
+		name string
+		kv   []string
+	}{
+		{"no credentials", nil},
+		{"unknown API key", []string{"X-API-Key", "nope"}},
+		{"not a bearer token", []string{"Authorization", "Basic " + testSecret}},
+		{"malformed token", []string{"Authorization", "Bearer nope"}},
+		{"token of another secret", []string{"Authorization", token(t, "fedcba9876543210fedcba9876543210", jwt.MapClaims{"sub": owner.String()})}},
+		{"token of an empty secret", []string{"Authorization", token(t, "", jwt.MapClaims{"sub": "ops", "role": "admin"})}},
+		{"expired token", []string{"Authorization", token(t, testSecret, jwt.MapClaims{"sub": owner.String(), "exp": time.Now().Add(-time.Hour).Unix()})}},
+		{"token without expiry", []string{"Authorization", token(t, testSecret, jwt.MapClaims{"sub": owner.String(), "exp": nil})}},
+		{"subject not a user", []string{"Authorization", token(t, testSecret, jwt.MapClaims{"sub": "svc"})}},
+	}
+	for _, tt := range unauthenticated {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newClient(t, srv, tt.kv...).do(http.MethodGet, path, nil, nil); got != http.StatusUnauthorized {
+				t.Errorf("status %d, want %d", got, http.StatusUnauthorized)
+			}
+		})
+	}
+
+	t.Run("probes stay open", func(t *testing.T) {
+		if got := newClient(t, srv).do(http.MethodGet, "/healthz", nil, nil); got != http.StatusOK {
+			t.Errorf("GET /healthz: status %d, want %d", got, http.StatusOK)
+		}
+	})
+
+	ownerClient := newClient(t, srv, "Authorization", token(t, testSecret, jwt.MapClaims{"sub": owner.String()}))
+	strangerClient := newClient(t, srv, "Authorization", token(t, testSecret, jwt.MapClaims{"sub": stranger.String()}))
+	adminClient := newClient(t, srv, "Authorization", token(t, testSecret, jwt.MapClaims{"sub": "ops", "role": "admin"}))
+
+	tests := []struct {
+		name         string
+		c            *client
+		method, path string
+		body         any
+		want         int
+	}{
+		{"owner reads", ownerClient, http.MethodGet, path, nil, http.StatusOK},
+		{"stranger can't see it", strangerClient, http.MethodGet, path, nil, http.StatusNotFound},
+		{"stranger can't delete it", strangerClient, http.MethodDelete, path, nil, http.StatusNotFound},
+		{"admin token reads", adminClient, http.MethodGet, path, nil, http.StatusOK},
+		{"API key reads", admin, http.MethodGet, path, nil, http.StatusOK},
+		{"stranger lists the owner's", strangerClient, http.MethodGet, "/subscriptions?user_id=" + owner.String(), nil, http.StatusForbidden},
+		{"stranger creates for the owner", strangerClient, http.MethodPost, "/subscriptions", subscription(owner, "Spotify", "2025-01-01", ""), http.StatusForbidden},
+		{"owner creates", ownerClient, http.MethodPost, "/subscriptions", subscription(owner, "Spotify", "2025-01-01", ""), http.StatusCreated},
+		{"user can't list webhooks", ownerClient, http.MethodGet, "/webhooks", nil, http.StatusForbidden},
+		{"admin lists webhooks", admin, http.MethodGet, "/webhooks", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.c.t = t
+			if got := tt.c.do(tt.method, tt.path, tt.body, nil); got != tt.want {
+				t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("users list their own", func(t *testing.T) {
+		strangerClient.t = t
+		var page domain.UserSubscriptionPage
+		if got := strangerClient.do(http.MethodGet, "/subscriptions", nil, &page); got != http.StatusOK {
+			t.Fatalf("status %d, want %d", got, http.StatusOK)
+		}
+		if len(page.Items) != 0 {
+			t.Errorf("stranger listed %d subscriptions of others", len(page.Items))
+		}
+	})
+}
+
+func TestAuthWithAPIKeysOnly(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		withAuth(cfg)
+		cfg.JWTSecret = ""
+	})
+
+	// Without a secret to verify them, no token is accepted, least of all
+	// one signed with the empty secret.
+	forged := newClient(t, srv, "Authorization", token(t, "", jwt.MapClaims{"sub": "ops", "role": "admin"}))
+	if got := forged.do(http.MethodGet, "/subscriptions", nil, nil); got != http.StatusUnauthorized {
+		t.Errorf("token of an empty secret: status %d, want %d", got, http.StatusUnauthorized)
+	}
+
+	admin := newClient(t, srv, "X-API-Key", testAPIKey)
+	if got := admin.do(http.MethodGet, "/subscriptions", nil, nil); got != http.StatusOK {
+		t.Errorf("API key: status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		withAuth(cfg)
+		cfg.RateLimitEnabled = true
+		cfg.RateLimitIP = "3/1m"
+	})
+	c := newClient(t, srv, "X-API-Key", "guess")
+
+	for i := range 3 {
+		if got := c.do(http.MethodGet, "/subscriptions", nil, nil); got != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, got, http.StatusUnauthorized)
+		}
+	}
+	if got := c.do(http.MethodGet, "/subscriptions", nil, nil); got != http.StatusTooManyRequests {
+		t.Errorf("attempt 4: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+
+	// The limit holds for the address, whatever it authenticates as.
+	admin := newClient(t, srv, "X-API-Key", testAPIKey)
+	if got := admin.do(http.MethodGet, "/subscriptions", nil, nil); got != http.StatusTooManyRequests {
+		t.Errorf("with a valid key: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
@@ -1,32 +1,208 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"subscription/internal/lib/ratelimit"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/lib/tracing"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+
+	PublisherLog   = "log"
+	PublisherKafka = "kafka"
+
+	RateLimitMemory  = "memory"
+	RateLimitStorage = "storage"
+)
+
 type Config struct {
-	Env string `env:"ENV" env-default:"local" env-required:"true"`
+	Env           string `env:"ENV" env-default:"local" env-required:"true"`
+	StorageDriver string `env:"STORAGE_DRIVER" env-default:"postgres"`
 	DbConfig
+	SQLiteConfig
 	HTTPServer
-	MigrationsPath string `env:"MIGRATIONS_PATH" env-required:"true"`
+	SoftDelete
+	Renewal
+	Webhooks
+	Outbox
+	Auth
+	Tenancy
+	RateLimit
+	Idempotency
+	Cache
+	Telemetry
+	FX
+	MigrationsPath string `env:"MIGRATIONS_PATH"`
 }
 
+// DbConfig configures the Postgres connections. URL, a postgres:// URL or a
+// key=value DSN, replaces the host, port, credentials, database and SSL
+// settings. The application name and statement timeout are added to it
+// unless it sets them. The pool settings apply either way, 0 leaves them
+// unlimited.
+//
+// It also controls how patiently the database is waited for at startup: the
+// connection is tried ConnectAttempts times, waiting ConnectBackoff after
+// the first failure and twice as long after each next.
+//
+// Reads tolerating lag go to the ReplicaURLs that answered their last check.
+// A client that changed data reads from the primary for ReadYourWrites.
 type DbConfig struct {
-	Host     string `env:"DB_HOST" env-required:"true"`
-	Port     string `env:"DB_PORT" env-required:"true"`
-	Username string `env:"POSTGRES_USER" env-required:"true"`
-	Password string `env:"POSTGRES_PASSWORD" env-required:"true"`
-	DBName   string `env:"POSTGRES_DB" env-required:"true"`
+	URL         string `env:"DATABASE_URL"`
+	Host        string `env:"DB_HOST"`
+	Port        string `env:"DB_PORT"`
+	Username    string `env:"POSTGRES_USER"`
+	Password    string `env:"POSTGRES_PASSWORD"`
+	DBName      string `env:"POSTGRES_DB"`
+	SSLMode     string `env:"DB_SSLMODE" env-default:"disable"`
+	SSLRootCert string `env:"DB_SSLROOTCERT"`
+	SSLCert     string `env:"DB_SSLCERT"`
+	SSLKey      string `env:"DB_SSLKEY"`
+
+	ApplicationName  string        `env:"DB_APPLICATION_NAME" env-default:"subscriptions"`
+	StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT" env-default:"0s"`
+
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+
+	ConnectAttempts int           `env:"DB_CONNECT_ATTEMPTS" env-default:"5"`
+	ConnectBackoff  time.Duration `env:"DB_CONNECT_BACKOFF" env-default:"1s"`
+
+	ReplicaURLs          []string      `env:"DB_REPLICA_URLS" env-separator:","`
+	ReplicaCheckInterval time.Duration `env:"DB_REPLICA_CHECK_INTERVAL" env-default:"5s"`
+	ReadYourWrites       time.Duration `env:"DB_READ_YOUR_WRITES" env-default:"0s"`
 }
 
+type SQLiteConfig struct {
+	SQLitePath string `env:"SQLITE_PATH" env-default:"subscriptions.db"`
+}
+
+// HTTPServer also controls the shutdown: readiness fails first, requests
+// keep being served for ShutdownDelay so that load balancers notice, then
+// the server waits up to ShutdownTimeout for the requests in flight.
+//
+// Imports store every row on its own and get ImportTimeout instead of
+// Timeout.
 type HTTPServer struct {
-	Address     string        `env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8080"`
-	Timeout     time.Duration `env:"HTTP_SERVER_TIMEOUT" env-default:"4s"`
-	IdleTimeout time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" env-default:"60s"`
+	Address         string        `env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8080"`
+	Timeout         time.Duration `env:"HTTP_SERVER_TIMEOUT" env-default:"4s"`
+	ImportTimeout   time.Duration `env:"HTTP_SERVER_IMPORT_TIMEOUT" env-default:"5m"`
+	IdleTimeout     time.Duration `env:"HTTP_SERVER_IDLE_TIMEOUT" env-default:"60s"`
+	ShutdownDelay   time.Duration `env:"HTTP_SERVER_SHUTDOWN_DELAY" env-default:"0s"`
+	ShutdownTimeout time.Duration `env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" env-default:"30s"`
+}
+
+// SoftDelete controls how long deleted subscriptions stay restorable.
+type SoftDelete struct {
+	Retention     time.Duration `env:"SOFT_DELETE_RETENTION" env-default:"720h"`
+	PurgeInterval time.Duration `env:"PURGE_INTERVAL" env-default:"1h"`
+}
+
+// Renewal controls how often due billing periods are looked for.
+type Renewal struct {
+	RenewalInterval time.Duration `env:"RENEWAL_INTERVAL" env-default:"1h"`
+}
+
+// Webhooks controls how webhook deliveries are sent and retried and when
+// subscriptions are announced as expiring.
+type Webhooks struct {
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"10"`
+	WebhookRetryBase   time.Duration `env:"WEBHOOK_RETRY_BASE" env-default:"30s"`
+	WebhookRetryMax    time.Duration `env:"WEBHOOK_RETRY_MAX" env-default:"6h"`
+	DispatchInterval   time.Duration `env:"WEBHOOK_DISPATCH_INTERVAL" env-default:"5s"`
+	ExpiryNotice       time.Duration `env:"EXPIRY_NOTICE" env-default:"72h"`
+	ExpiryScanInterval time.Duration `env:"EXPIRY_SCAN_INTERVAL" env-default:"1h"`
+}
+
+// Outbox configures where subscription events are published. The log
+// publisher only logs them.
+type Outbox struct {
+	Publisher      string        `env:"OUTBOX_PUBLISHER" env-default:"log"`
+	RelayInterval  time.Duration `env:"OUTBOX_RELAY_INTERVAL" env-default:"1s"`
+	PublishTimeout time.Duration `env:"OUTBOX_PUBLISH_TIMEOUT" env-default:"10s"`
+	KafkaBrokers   []string      `env:"KAFKA_BROKERS" env-separator:","`
+	KafkaTopic     string        `env:"KAFKA_TOPIC" env-default:"subscription-events"`
+}
+
+// Auth configures how callers authenticate. API keys are given as
+// name:key pairs and act as admins, bearer tokens are verified with the
+// HS256 secret or the RS256 keys of the JWKS file.
+type Auth struct {
+	AuthEnabled bool              `env:"AUTH_ENABLED" env-default:"true"`
+	APIKeys     map[string]string `env:"AUTH_API_KEYS" env-separator:","`
+	JWTSecret   string            `env:"AUTH_JWT_SECRET"`
+	JWKSFile    string            `env:"AUTH_JWKS_FILE"`
+	JWTIssuer   string            `env:"AUTH_JWT_ISSUER"`
+	JWTAudience string            `env:"AUTH_JWT_AUDIENCE"`
+	AdminRole   string            `env:"AUTH_ADMIN_ROLE" env-default:"admin"`
+	TenantClaim string            `env:"AUTH_TENANT_CLAIM" env-default:"tenant"`
+}
+
+// Tenancy configures how requests are scoped to a tenant. Tokens carrying
+// the tenant claim are bound to their tenant, unbound admins name one in
+// the tenant header and everybody else works in the default tenant.
+type Tenancy struct {
+	TenantHeader  string `env:"TENANT_HEADER" env-default:"X-Tenant-ID"`
+	DefaultTenant string `env:"DEFAULT_TENANT" env-default:"default"`
+}
+
+// RateLimit configures the requests every client may make. Limits are
+// written as requests/period, routes as method and pattern. RateLimitIP
+// limits every IP address before authentication. The storage store shares
+// the limits between the replicas using the same database.
+type RateLimit struct {
+	RateLimitEnabled bool              `env:"RATE_LIMIT_ENABLED" env-default:"true"`
+	RateLimitDefault string            `env:"RATE_LIMIT" env-default:"300/1m"`
+	RateLimitIP      string            `env:"RATE_LIMIT_IP" env-default:"600/1m"`
+	RateLimitRoutes  map[string]string `env:"RATE_LIMIT_ROUTES" env-separator:"," env-default:"GET /subscriptions/total_cost:30/1m,GET /subscriptions/analytics:30/1m"`
+	RateLimitStore   string            `env:"RATE_LIMIT_STORE" env-default:"memory"`
+}
+
+// Idempotency configures how long the responses to requests sent with an
+// Idempotency-Key are replayed to their retries.
+type Idempotency struct {
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" env-default:"24h"`
+}
+
+// Cache configures the cache of subscription reads and cost totals. It's
+// kept in process memory: writes served by another replica of the service
+// show here once the entries expire, so it's off unless a single replica
+// runs.
+type Cache struct {
+	CacheEnabled bool          `env:"CACHE_ENABLED" env-default:"false"`
+	CacheSize    int           `env:"CACHE_SIZE" env-default:"10000"`
+	CacheTTL     time.Duration `env:"CACHE_TTL" env-default:"1m"`
+}
+
+// Telemetry configures where the Prometheus metrics are served and where
+// the traces go: to an OTLP/HTTP collector, stdout or nowhere.
+type Telemetry struct {
+	MetricsPath        string  `env:"METRICS_PATH" env-default:"/metrics"`
+	ServiceName        string  `env:"SERVICE_NAME" env-default:"subscriptions"`
+	TracingExporter    string  `env:"TRACING_EXPORTER" env-default:"none"`
+	TracingEndpoint    string  `env:"TRACING_OTLP_ENDPOINT" env-default:"http://localhost:4318/v1/traces"`
+	TracingSampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+}
+
+// FX configures where exchange rates come from. Without a file or URL only
+// subscriptions in the requested currency can be totalled.
+type FX struct {
+	RatesFile string        `env:"FX_RATES_FILE"`
+	RatesURL  string        `env:"FX_RATES_URL"`
+	RatesTTL  time.Duration `env:"FX_RATES_TTL" env-default:"1h"`
 }
 
 func MustLoad() *Config {
@@ -39,5 +215,222 @@ func MustLoad() *Config {
 		log.Fatal("cannot read environment variables: ", err)
 	}
 
+	if err := cfg.validate(); err != nil {
+		log.Fatal("invalid configuration: ", err)
+	}
+
 	return &cfg
 }
+
+func (c *Config) validate() error {
+	if c.ShutdownDelay < 0 {
+		return fmt.Errorf("HTTP_SERVER_SHUTDOWN_DELAY must not be negative")
+	}
+	if c.ImportTimeout <= 0 {
+		return fmt.Errorf("HTTP_SERVER_IMPORT_TIMEOUT must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("HTTP_SERVER_SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("SOFT_DELETE_RETENTION must not be negative")
+	}
+	if c.PurgeInterval <= 0 {
+		return fmt.Errorf("PURGE_INTERVAL must be positive")
+	}
+	if c.RenewalInterval <= 0 {
+		return fmt.Errorf("RENEWAL_INTERVAL must be positive")
+	}
+	if c.WebhookTimeout <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must be positive")
+	}
+	if c.WebhookMaxAttempts < 1 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.WebhookRetryBase <= 0 || c.WebhookRetryMax < c.WebhookRetryBase {
+		return fmt.Errorf("WEBHOOK_RETRY_BASE must be positive and at most WEBHOOK_RETRY_MAX")
+	}
+	if c.DispatchInterval <= 0 {
+		return fmt.Errorf("WEBHOOK_DISPATCH_INTERVAL must be positive")
+	}
+	if c.ExpiryNotice < 0 {
+		return fmt.Errorf("EXPIRY_NOTICE must not be negative")
+	}
+	if c.ExpiryScanInterval <= 0 {
+		return fmt.Errorf("EXPIRY_SCAN_INTERVAL must be positive")
+	}
+	if c.RelayInterval <= 0 {
+		return fmt.Errorf("OUTBOX_RELAY_INTERVAL must be positive")
+	}
+	if c.PublishTimeout <= 0 {
+		return fmt.Errorf("OUTBOX_PUBLISH_TIMEOUT must be positive")
+	}
+	switch c.Publisher {
+	case PublisherLog:
+	case PublisherKafka:
+		if len(c.KafkaBrokers) == 0 || c.KafkaTopic == "" {
+			return fmt.Errorf("KAFKA_BROKERS and KAFKA_TOPIC are required for the %s publisher", c.Publisher)
+		}
+	default:
+		return fmt.Errorf("unknown OUTBOX_PUBLISHER %q", c.Publisher)
+	}
+	if c.AuthEnabled {
+		if len(c.APIKeys) == 0 && c.JWTSecret == "" && c.JWKSFile == "" {
+			return fmt.Errorf("AUTH_API_KEYS, AUTH_JWT_SECRET or AUTH_JWKS_FILE is required unless AUTH_ENABLED is false")
+		}
+		if c.JWTSecret != "" && len(strings.TrimSpace(c.JWTSecret)) < 32 {
+			return fmt.Errorf("AUTH_JWT_SECRET must be at least 32 bytes, not counting surrounding spaces")
+		}
+		for name, key := range c.APIKeys {
+			if key == "" {
+				return fmt.Errorf("AUTH_API_KEYS: empty key for %q", name)
+			}
+		}
+		if c.AdminRole == "" {
+			return fmt.Errorf("AUTH_ADMIN_ROLE must not be empty")
+		}
+	}
+	if c.TenantHeader == "" {
+		return fmt.Errorf("TENANT_HEADER must not be empty")
+	}
+	if !tenant.Valid(c.DefaultTenant) {
+		return fmt.Errorf("invalid DEFAULT_TENANT %q", c.DefaultTenant)
+	}
+	if c.RateLimitEnabled {
+		if err := c.validateRateLimit(); err != nil {
+			return err
+		}
+	}
+	if c.IdempotencyKeyTTL <= 0 {
+		return fmt.Errorf("IDEMPOTENCY_KEY_TTL must be positive")
+	}
+	if c.CacheEnabled {
+		if c.CacheSize <= 0 {
+			return fmt.Errorf("CACHE_SIZE must be positive")
+		}
+		if c.CacheTTL <= 0 {
+			return fmt.Errorf("CACHE_TTL must be positive")
+		}
+	}
+	if !strings.HasPrefix(c.MetricsPath, "/") {
+		return fmt.Errorf("METRICS_PATH must start with /")
+	}
+	switch c.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if c.TracingEndpoint == "" {
+			return fmt.Errorf("TRACING_OTLP_ENDPOINT is required for the %s exporter", c.TracingExporter)
+		}
+	default:
+		return fmt.Errorf("unknown TRACING_EXPORTER %q", c.TracingExporter)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	if c.RatesFile != "" && c.RatesURL != "" {
+		return fmt.Errorf("FX_RATES_FILE and FX_RATES_URL are mutually exclusive")
+	}
+
+	switch c.StorageDriver {
+	case StorageMemory:
+		return nil
+	case StorageSQLite:
+		if c.MigrationsPath == "" {
+			return fmt.Errorf("MIGRATIONS_PATH is required for the %s storage", c.StorageDriver)
+		}
+		return nil
+	case StoragePostgres:
+		if c.MigrationsPath == "" {
+			return fmt.Errorf("MIGRATIONS_PATH is required for the %s storage", c.StorageDriver)
+		}
+		return c.DbConfig.validate()
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q", c.StorageDriver)
+	}
+}
+
+func (c *DbConfig) validate() error {
+	if c.URL == "" {
+		required := map[string]string{
+			"DB_HOST":       c.Host,
+			"DB_PORT":       c.Port,
+			"POSTGRES_USER": c.Username,
+			"POSTGRES_DB":   c.DBName,
+		}
+		// Client certificates can stand in for the password.
+		if c.SSLCert == "" {
+			required["POSTGRES_PASSWORD"] = c.Password
+		}
+		for name, value := range required {
+			if value == "" {
+				return fmt.Errorf("%s is required unless DATABASE_URL is set", name)
+			}
+		}
+
+		switch c.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			return fmt.Errorf("unknown DB_SSLMODE %q", c.SSLMode)
+		}
+		if (c.SSLCert == "") != (c.SSLKey == "") {
+			return fmt.Errorf("DB_SSLCERT and DB_SSLKEY must be set together")
+		}
+	}
+
+	if c.StatementTimeout < 0 {
+		return fmt.Errorf("DB_STATEMENT_TIMEOUT must not be negative")
+	}
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	// Renewals hold their lock on a connection while they write on others.
+	if c.MaxOpenConns == 1 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be 0 or at least 2")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must be at most DB_MAX_OPEN_CONNS")
+	}
+	if c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME must not be negative")
+	}
+	if c.ConnectAttempts < 1 {
+		return fmt.Errorf("DB_CONNECT_ATTEMPTS must be at least 1")
+	}
+	if c.ConnectBackoff <= 0 {
+		return fmt.Errorf("DB_CONNECT_BACKOFF must be positive")
+	}
+	if c.ReplicaCheckInterval <= 0 {
+		return fmt.Errorf("DB_REPLICA_CHECK_INTERVAL must be positive")
+	}
+	if c.ReadYourWrites < 0 {
+		return fmt.Errorf("DB_READ_YOUR_WRITES must not be negative")
+	}
+
+	return nil
+}
+
+func (c *Config) validateRateLimit() error {
+	if _, err := ratelimit.ParseLimit(c.RateLimitDefault); err != nil {
+		return fmt.Errorf("RATE_LIMIT: %w", err)
+	}
+	if _, err := ratelimit.ParseLimit(c.RateLimitIP); err != nil {
+		return fmt.Errorf("RATE_LIMIT_IP: %w", err)
+	}
+	for route, limit := range c.RateLimitRoutes {
+		if _, err := ratelimit.ParseLimit(limit); err != nil {
+			return fmt.Errorf("RATE_LIMIT_ROUTES: %s: %w", route, err)
+		}
+	}
+
+	switch c.RateLimitStore {
+	case RateLimitMemory:
+	case RateLimitStorage:
+		if c.StorageDriver == StorageMemory {
+			return fmt.Errorf("RATE_LIMIT_STORE %s requires the %s or %s storage", RateLimitStorage, StoragePostgres, StorageSQLite)
+		}
+	default:
+		return fmt.Errorf("unknown RATE_LIMIT_STORE %q", c.RateLimitStore)
+	}
+
+	return nil
+}
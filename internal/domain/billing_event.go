@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	BillingEventCharge  = "charge"
+	BillingEventRenewal = "renewal"
+)
+
+// BillingEvent charges a subscription for one billing period: a charge for
+// the first period and a renewal for every later one. A period the
+// subscription ends in is cut at the end date and its amount prorated.
+type BillingEvent struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	Type           string          `json:"type"`
+	PeriodStart    string          `json:"period_start" example:"2025-07-15"`
+	PeriodEnd      string          `json:"period_end" example:"2025-08-14"`
+	Amount         decimal.Decimal `json:"amount" swaggertype:"string" example:"9.99"`
+	Currency       string          `json:"currency"`
+	CreatedAt      time.Time       `json:"created_at"`
+	TenantID       string          `json:"-"`
+}
+
+// DueRenewal is a live subscription with billing periods that may not be
+// billed yet. BilledUntil is the last billed day, nil before the first charge.
+type DueRenewal struct {
+	Subscription *UserSubscription
+	BilledUntil  *time.Time
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+const (
+	ActionCreated  = "created"
+	ActionUpdated  = "updated"
+	ActionDeleted  = "deleted"
+	ActionRestored = "restored"
+	ActionPurged   = "purged"
+)
+
+type HistoryRecord struct {
+	ID             int64             `json:"id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	Action         string            `json:"action"`
+	Before         *UserSubscription `json:"before,omitempty"`
+	After          *UserSubscription `json:"after,omitempty"`
+	Actor          string            `json:"actor"`
+	RequestID      string            `json:"request_id,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	TenantID       string            `json:"-"`
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "errors"
+
+// ErrImportInterrupted is the error of the rows an import ran out of time
+// for. They weren't stored, the rows before them may have been.
+var ErrImportInterrupted = errors.New("import interrupted before the row was stored")
+
+// ImportResult is the outcome of storing one row of a bulk import. ID is
+// set when the row was created, Err when it was rejected.
+type ImportResult struct {
+	ID  int64
+	Err error
+}
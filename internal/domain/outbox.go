@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventSubscriptionRestored is published to the broker only, webhooks
+// receive restores as updates.
+const EventSubscriptionRestored = "subscription.restored"
+
+// SubscriptionEvent is the payload published to the broker for every change
+// of a subscription. Before is missing for creations and restores, After for
+// deletions.
+type SubscriptionEvent struct {
+	ID             string            `json:"id"`
+	Type           string            `json:"type"`
+	OccurredAt     time.Time         `json:"occurred_at"`
+	TenantID       string            `json:"tenant_id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	UserID         uuid.UUID         `json:"user_id"`
+	Before         *UserSubscription `json:"before,omitempty"`
+	After          *UserSubscription `json:"after,omitempty"`
+}
+
+// OutboxEvent is an event written in the transaction of the change it
+// describes and published by the relay afterwards. Key orders the events:
+// events sharing it are published in the order they were written.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	EventID   string          `json:"event_id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	TenantID  string          `json:"tenant_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -1,14 +1,89 @@
 package domain
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is assumed for subscriptions and totals that don't name
+// a currency.
+const DefaultCurrency = "RUB"
+
+// Billing periods a subscription price is charged for.
+const (
+	BillingWeekly    = "weekly"
+	BillingMonthly   = "monthly"
+	BillingQuarterly = "quarterly"
+	BillingYearly    = "yearly"
+
+	DefaultBillingPeriod = BillingMonthly
+)
+
+// PricePlaces and PriceDigits bound prices to NUMERIC(18, 4): 14 digits
+// before and 4 after the decimal point.
+const (
+	PricePlaces = 4
+	PriceDigits = 18
 )
 
 type UserSubscription struct {
-	ID          string    `json:"id,omitempty"`
-	ServiceName string    `json:"service_name,omitempty"`
-	Price       int       `json:"price,omitempty"`
-	UserID      uuid.UUID `json:"user_id,omitempty"`
-	StartDate   string    `json:"start_date,omitempty"`
-	EndDate     string    `json:"end_date,omitempty"`
+	ID            string          `json:"id,omitempty"`
+	ServiceName   string          `json:"service_name,omitempty"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" example:"9.99"`
+	Currency      string          `json:"currency,omitempty"`
+	BillingPeriod string          `json:"billing_period,omitempty"`
+	UserID        uuid.UUID       `json:"user_id,omitempty"`
+	StartDate     string          `json:"start_date,omitempty" example:"2025-07-15"`
+	EndDate       string          `json:"end_date,omitempty" example:"2026-07-14"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	// TenantID is implied by the request and never rendered.
+	TenantID string `json:"-"`
+}
+
+type SubscriptionCost struct {
+	ID            string          `json:"id"`
+	ServiceName   string          `json:"service_name"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" example:"9.99"`
+	Currency      string          `json:"currency"`
+	BillingPeriod string          `json:"billing_period"`
+	StartDate     string          `json:"start_date"`
+	EndDate       string          `json:"end_date,omitempty"`
+	// Periods is the number of billing periods charged, fractional when
+	// a period is prorated.
+	Periods decimal.Decimal `json:"periods" swaggertype:"string" example:"3"`
+	// Cost is in the currency of the subscription, ConvertedCost in the
+	// currency of the total.
+	Cost          decimal.Decimal `json:"cost" swaggertype:"string" example:"29.97"`
+	ConvertedCost decimal.Decimal `json:"converted_cost" swaggertype:"string" example:"2997.00"`
+}
+
+type TotalCost struct {
+	TotalCost     decimal.Decimal     `json:"total_cost" swaggertype:"string" example:"2997.00"`
+	Currency      string              `json:"currency"`
+	Conversion    *Conversion         `json:"conversion"`
+	Subscriptions []*SubscriptionCost `json:"subscriptions"`
+}
+
+// Conversion describes the exchange rates a total was converted with. Rates
+// hold how much of each currency one unit of Base buys.
+type Conversion struct {
+	Base  string                     `json:"base"`
+	Date  string                     `json:"date"`
+	Rates map[string]decimal.Decimal `json:"rates" swaggertype:"object,string"`
+}
+
+// CostBucket spend is in the currency the analytics were requested in.
+type CostBucket struct {
+	Month               string          `json:"month,omitempty"`
+	ServiceName         string          `json:"service_name,omitempty"`
+	UserID              *uuid.UUID      `json:"user_id,omitempty"`
+	Spend               decimal.Decimal `json:"spend" swaggertype:"string" example:"29.97"`
+	ActiveSubscriptions int             `json:"active_subscriptions"`
+}
+
+type UserSubscriptionPage struct {
+	Items      []*UserSubscription `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,12 @@
+package domain
+
+// TenantReport sums up the data of a tenant. Tenants aren't registered
+// anywhere, a tenant exists once it owns a subscription or a webhook.
+type TenantReport struct {
+	TenantID             string `json:"tenant_id"`
+	Subscriptions        int64  `json:"subscriptions"`
+	ActiveSubscriptions  int64  `json:"active_subscriptions"`
+	DeletedSubscriptions int64  `json:"deleted_subscriptions"`
+	Users                int64  `json:"users"`
+	Webhooks             int64  `json:"webhooks"`
+}
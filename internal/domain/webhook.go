@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook events. Started and renewed follow the billing events of a
+// subscription, expiring and expired are sent by the expiry scan.
+const (
+	EventSubscriptionCreated  = "subscription.created"
+	EventSubscriptionUpdated  = "subscription.updated"
+	EventSubscriptionDeleted  = "subscription.deleted"
+	EventSubscriptionStarted  = "subscription.started"
+	EventSubscriptionRenewed  = "subscription.renewed"
+	EventSubscriptionExpiring = "subscription.expiring"
+	EventSubscriptionExpired  = "subscription.expired"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint receiving the events it subscribed to. Secret signs
+// the payloads and is only returned when the webhook is registered.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	TenantID  string    `json:"-"`
+}
+
+// WebhookEvent is the payload posted to webhooks.
+type WebhookEvent struct {
+	ID        string           `json:"id"`
+	Type      string           `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+type WebhookEventData struct {
+	Subscription *UserSubscription `json:"subscription"`
+	BillingEvent *BillingEvent     `json:"billing_event,omitempty"`
+}
+
+// WebhookDelivery is an event queued for one webhook. Pending deliveries are
+// attempted once NextAttemptAt passes until one succeeds or they run out of
+// attempts and fail.
+type WebhookDelivery struct {
+	ID             int64             `json:"id"`
+	WebhookID      int64             `json:"webhook_id"`
+	EventID        string            `json:"event_id"`
+	Event          string            `json:"event"`
+	Payload        json.RawMessage   `json:"payload" swaggertype:"object"`
+	Status         string            `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastStatusCode int               `json:"last_status_code,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty"`
+	Log            []*WebhookAttempt `json:"log,omitempty"`
+	TenantID       string            `json:"-"`
+}
+
+// WebhookAttempt logs one try to deliver an event. StatusCode is zero when
+// no response was received.
+type WebhookAttempt struct {
+	ID          int64     `json:"id"`
+	DeliveryID  int64     `json:"delivery_id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
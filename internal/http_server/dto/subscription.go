@@ -1,29 +1,78 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type CreateUserSubDTO struct {
-	ServiceName string    `json:"service_name" validate:"min=3,max=255"`
-	Price       int       `json:"price" validate:"min=0"`
-	UserID      uuid.UUID `json:"user_id" validate:"required,uuid4"`
-	StartDate   string    `json:"start_date" validate:"required"`
-	EndDate     string    `json:"end_date,omitempty"`
+	ServiceName   string          `json:"service_name" validate:"min=3,max=255"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" example:"9.99"`
+	Currency      string          `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	BillingPeriod string          `json:"billing_period,omitempty" validate:"omitempty,oneof=weekly monthly quarterly yearly"`
+	UserID        uuid.UUID       `json:"user_id" validate:"required,uuid4"`
+	StartDate     string          `json:"start_date" validate:"required" example:"2025-07-15"`
+	EndDate       string          `json:"end_date,omitempty" example:"2026-07-14"`
 }
 
 type UpdateUserSubDTO struct {
-	ID          int       `json:"id,omitempty"`
-	ServiceName string    `json:"service_name" validate:"min=3,max=255"`
-	Price       int       `json:"price" validate:"min=0"`
+	ID            int             `json:"id,omitempty"`
+	ServiceName   string          `json:"service_name" validate:"min=3,max=255"`
+	Price         decimal.Decimal `json:"price" swaggertype:"string" example:"9.99"`
+	Currency      string          `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	BillingPeriod string          `json:"billing_period,omitempty" validate:"omitempty,oneof=weekly monthly quarterly yearly"`
+	UserID        uuid.UUID       `json:"user_id" validate:"required,uuid4"`
+	StartDate     string          `json:"start_date" validate:"required" example:"2025-07-15"`
+	EndDate       string          `json:"end_date,omitempty" example:"2026-07-14"`
+	// Version is the updated_at the client last saw. When set, the update
+	// only applies if the subscription was not modified since.
+	Version *time.Time `json:"-"`
+}
+
+type TotalCost struct {
+	ServiceName string    `json:"service_name,omitempty" validate:"omitempty,min=3,max=255"`
+	Currency    string    `json:"currency,omitempty" validate:"omitempty,iso4217"`
 	UserID      uuid.UUID `json:"user_id" validate:"required,uuid4"`
 	StartDate   string    `json:"start_date" validate:"required"`
 	EndDate     string    `json:"end_date,omitempty"`
 }
 
-type TotalCost struct {
-	ServiceName string    `json:"service_name" validate:"min=3,max=255"`
-	UserID      uuid.UUID `json:"user_id" validate:"required,uuid4"`
+type CostAnalytics struct {
+	GroupBy     []string  `json:"group_by" validate:"required,dive,oneof=service_name month user_id"`
+	UserID      uuid.UUID `json:"user_id,omitempty"`
+	ServiceName string    `json:"service_name,omitempty" validate:"omitempty,min=3,max=255"`
+	Currency    string    `json:"currency,omitempty" validate:"omitempty,iso4217"`
 	StartDate   string    `json:"start_date" validate:"required"`
 	EndDate     string    `json:"end_date,omitempty"`
 }
+
+type ListUserSubs struct {
+	UserID            uuid.UUID        `json:"user_id,omitempty"`
+	ServiceName       string           `json:"service_name,omitempty"`
+	ServiceNamePrefix string           `json:"service_name_prefix,omitempty"`
+	MinPrice          *decimal.Decimal `json:"min_price,omitempty"`
+	MaxPrice          *decimal.Decimal `json:"max_price,omitempty"`
+	ActiveOn          string           `json:"active_on,omitempty"`
+	StartFrom         string           `json:"start_from,omitempty"`
+	StartTo           string           `json:"start_to,omitempty"`
+	EndFrom           string           `json:"end_from,omitempty"`
+	EndTo             string           `json:"end_to,omitempty"`
+	SortBy            string           `json:"sort_by" validate:"oneof=id service_name price user_id start_date end_date created_at updated_at"`
+	Order             string           `json:"order" validate:"oneof=asc desc"`
+	Limit             int              `json:"limit" validate:"min=1,max=1000"`
+	Cursor            string           `json:"cursor,omitempty"`
+}
+
+// PatchUserSubDTO documents the JSON Merge Patch accepted by the PATCH
+// endpoint. Absent fields are left unchanged, a null end_date removes it.
+type PatchUserSubDTO struct {
+	ServiceName   *string          `json:"service_name,omitempty"`
+	Price         *decimal.Decimal `json:"price,omitempty" swaggertype:"string" example:"9.99"`
+	Currency      *string          `json:"currency,omitempty"`
+	BillingPeriod *string          `json:"billing_period,omitempty"`
+	UserID        *uuid.UUID       `json:"user_id,omitempty"`
+	StartDate     *string          `json:"start_date,omitempty"`
+	EndDate       *string          `json:"end_date,omitempty"`
+}
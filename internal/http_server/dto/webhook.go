@@ -0,0 +1,13 @@
+package dto
+
+type CreateWebhookDTO struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048" example:"https://example.com/hooks/subscriptions"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=subscription.created subscription.updated subscription.deleted subscription.started subscription.renewed subscription.expiring subscription.expired"`
+	// Secret signs the payloads. A random one is generated when omitted.
+	Secret string `json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+}
+
+type ListWebhookDeliveries struct {
+	Status string `json:"status,omitempty" validate:"omitempty,oneof=pending delivered failed"`
+	Limit  int    `json:"limit" validate:"min=1,max=1000"`
+}
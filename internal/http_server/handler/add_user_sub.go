@@ -24,15 +24,23 @@ type CreateResponse struct {
 
 // AddUserSubscriptionHandler godoc
 // @Summary Add user subscription
-// @Description Adding user subscription to the database.
+// @Description Adding user subscription to the database. Retries sent with the same Idempotency-Key get the first response again.
 // @Tags Subscription
 // @Accept json
 // @Produce json
 // @Param request body dto.CreateUserSubDTO true "Data for creating a user subscription"
+// @Param Idempotency-Key header string false "Key making retries of the request safe"
 // @Success 201 {object} CreateResponse "Subscription created successfully"
 // @Failure 400 {object} resp.ErrorResponse "Invalid request"
-// @Failure 409 {object} resp.ErrorResponse "User subscription conflicts with existing record"
+// @Failure 401 {object} resp.ErrorResponse "Authentication required"
+// @Failure 403 {object} resp.ErrorResponse "Access denied"
+// @Failure 409 {object} resp.ErrorResponse "User subscription conflicts with existing record, or a request with the idempotency key is in progress"
+// @Failure 413 {object} resp.ErrorResponse "Body of a request with an idempotency key too large"
+// @Failure 422 {object} resp.ErrorResponse "Idempotency key was used for another request"
+// @Failure 429 {object} resp.ErrorResponse "Rate limit exceeded"
 // @Failure 500 {object} resp.ErrorResponse "Server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /subscriptions [post]
 func (h *UserSubscriptionHandler) AddUserSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handler.AddUserSubscriptionHandler"
@@ -62,6 +70,13 @@ func (h *UserSubscriptionHandler) AddUserSubscriptionHandler(w http.ResponseWrit
 		return
 	}
 
+	if err := valid.ValidatePrice("price", req.Price); err != nil {
+		log.Error("invalid request body", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
 	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
 	if err := validWithOpts.Struct(req); err != nil {
 		var validateErr validator.ValidationErrors
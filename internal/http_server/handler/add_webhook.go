@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+// AddWebhookHandler godoc
+// @Summary Register webhook
+// @Description Registers an endpoint receiving the listed subscription events as signed JSON POST requests.
+// @Description Every request carries the X-Webhook-Event, X-Webhook-Delivery and X-Webhook-Timestamp headers and
+// @Description X-Webhook-Signature: "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with
+// @Description the secret. The secret is only returned here; a random one is generated when omitted.
+// @Description Requests answered with anything but 2xx are retried with exponential backoff.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateWebhookDTO true "Webhook endpoint and events"
+// @Success 201 {object} domain.Webhook "Webhook registered"
+// @Failure 400 {object} resp.ErrorResponse "Invalid request"
+// @Failure 401 {object} resp.ErrorResponse "Authentication required"
+// @Failure 403 {object} resp.ErrorResponse "Admin role required"
+// @Failure 429 {object} resp.ErrorResponse "Rate limit exceeded"
+// @Failure 500 {object} resp.ErrorResponse "Server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /webhooks [post]
+func (h *WebhookHandler) AddWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.AddWebhookHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	var req dto.CreateWebhookDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("failed to decode request", sl.Err(err))
+
+		resp.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.service.Register(ctx, req)
+	if err != nil {
+		log.Error("failed to register webhook", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to register webhook", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, hook, http.StatusCreated)
+}
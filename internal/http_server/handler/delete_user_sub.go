@@ -20,13 +20,17 @@ type DeleteResponse struct {
 
 // DeleteUserSubscriptionHandler godoc
 // @Summary      Delete user subscription
-// @Description  Deletes a user subscription by ID
+// @Description  Soft-deletes a user subscription by ID. It can be restored until the retention window expires.
 // @Tags Subscription
 // @Param        id   path      int  true  "User subscription ID"
 // @Success      200  {object}  DeleteResponse
 // @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
-// @Failure      404  {object}  resp.ErrorResponse "User ubscription not found"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      404  {object}  resp.ErrorResponse "User subscription not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
 // @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/{id} [delete]
 func (h *UserSubscriptionHandler) DeleteUserSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handler.DeleteUserSubscriptionHandler"
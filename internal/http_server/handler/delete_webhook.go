@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DeleteWebhookHandler godoc
+// @Summary      Delete webhook
+// @Description  Deletes a webhook together with its deliveries, pending ones are not sent.
+// @Tags Webhook
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  DeleteResponse
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404  {object}  resp.ErrorResponse "Webhook not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.DeleteWebhookHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteById(ctx, id); err != nil {
+		log.Error("failed to delete webhook", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	response := DeleteResponse{
+		Id:      id,
+		Message: "webhook successfully deleted",
+	}
+
+	resp.ResponseOk(w, response, http.StatusOK)
+}
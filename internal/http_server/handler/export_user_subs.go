@@ -0,0 +1,303 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/logger/sl"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	exportFormatCSV   = "csv"
+	exportFormatJSONL = "jsonl"
+	exportFormatJSON  = "json"
+
+	// exportFlushRows is how many rows are buffered before they are sent.
+	exportFlushRows = 500
+)
+
+var exportColumns = []string{"id", "service_name", "price", "currency", "billing_period", "user_id", "start_date", "end_date", "updated_at"}
+
+// ExportUserSubscriptionsHandler godoc
+// @Summary      Export subscriptions
+// @Description  Streams every subscription matching the filters, which work like in the listing. limit and cursor are ignored.
+// @Description  With excel=true the CSV starts with a byte order mark and uses CRLF line endings, so spreadsheet applications open it as UTF-8.
+// @Tags Subscription
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      json
+// @Param        format              query string false "Export format" Enums(csv, jsonl, json) default(csv)
+// @Param        excel               query bool   false "Make the CSV spreadsheet friendly"
+// @Param        user_id             query string false "User UUID"
+// @Param        service_name        query string false "Exact service name"
+// @Param        service_name_prefix query string false "Service name prefix"
+// @Param        min_price           query string false "Minimal price"
+// @Param        max_price           query string false "Maximal price"
+// @Param        active_on           query string false "Subscriptions active on the day (YYYY-MM-DD) or in the month (MM-YYYY)"
+// @Param        start_from          query string false "Start date lower bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        start_to            query string false "Start date upper bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        end_from            query string false "End date lower bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        end_to              query string false "End date upper bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        sort_by             query string false "Sort column" Enums(id, service_name, price, user_id, start_date, end_date, created_at, updated_at) default(id)
+// @Param        order               query string false "Sort order" Enums(asc, desc) default(asc)
+// @Success      200 {array}  domain.UserSubscription
+// @Failure      400 {object} resp.ErrorResponse "Invalid format or filter"
+// @Failure      401 {object} resp.ErrorResponse "Authentication required"
+// @Failure      403 {object} resp.ErrorResponse "Access denied"
+// @Failure      429 {object} resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500 {object} resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/export [get]
+func (h *UserSubscriptionHandler) ExportUserSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ExportUserSubscriptionsHandler"
+
+	// An export takes as long as it takes, it only stops when the client goes away.
+	ctx := r.Context()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = exportFormatCSV
+	}
+
+	var excel bool
+	if v := query.Get("excel"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			resp.Error(w, "invalid query parameters: invalid excel: must be a boolean", http.StatusBadRequest)
+			return
+		}
+		excel = b
+	}
+
+	req, err := parseListQuery(query)
+	if err != nil {
+		log.Error("invalid query parameters", sl.Err(err))
+		resp.Error(w, fmt.Sprintf("invalid query parameters: %s", err), http.StatusBadRequest)
+		return
+	}
+	req.Limit = defaultListLimit
+	req.Cursor = ""
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
+		return
+	}
+
+	var enc exportEncoder
+	switch format {
+	case exportFormatCSV:
+		enc = &csvExportEncoder{excel: excel}
+	case exportFormatJSONL:
+		enc = &jsonLinesExportEncoder{}
+	case exportFormatJSON:
+		enc = &jsonExportEncoder{}
+	default:
+		resp.Error(w, fmt.Sprintf("invalid format %q, must be one of [%s %s %s]",
+			format, exportFormatCSV, exportFormatJSONL, exportFormatJSON), http.StatusBadRequest)
+		return
+	}
+
+	buf := bufio.NewWriter(w)
+	rc := http.NewResponseController(w)
+	started := false
+	rows := 0
+
+	// start sends the headers once the export is known to produce output, so
+	// a failing query can still be reported with an error status.
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", enc.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="subscriptions.%s"`, format))
+		w.WriteHeader(http.StatusOK)
+
+		return enc.begin(buf)
+	}
+
+	err = h.service.Export(ctx, req, func(sub *domain.UserSubscription) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if err := enc.write(buf, sub); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to export user subscriptions", slog.Int("rows", rows), sl.Err(err))
+
+		if !started {
+			if msg, code, ok := er.MapErrorToStatus(err); ok {
+				resp.Error(w, msg, code)
+				return
+			}
+			resp.Error(w, "failed to export user subscriptions", http.StatusInternalServerError)
+			return
+		}
+
+		// The status is already sent, aborting the response is the only way
+		// left to tell the client that the export is incomplete.
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			log.Error("failed to write export", sl.Err(err))
+			return
+		}
+	}
+
+	if err := enc.end(buf); err != nil {
+		log.Error("failed to write export", sl.Err(err))
+		return
+	}
+	if err := buf.Flush(); err != nil {
+		log.Error("failed to write export", sl.Err(err))
+	}
+}
+
+type exportEncoder interface {
+	contentType() string
+	begin(w *bufio.Writer) error
+	write(w *bufio.Writer, sub *domain.UserSubscription) error
+	end(w *bufio.Writer) error
+}
+
+type csvExportEncoder struct {
+	excel bool
+	csv   *csv.Writer
+}
+
+func (e *csvExportEncoder) contentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e *csvExportEncoder) begin(w *bufio.Writer) error {
+	if e.excel {
+		if _, err := w.WriteString("\ufeff"); err != nil {
+			return err
+		}
+	}
+
+	e.csv = csv.NewWriter(w)
+	e.csv.UseCRLF = e.excel
+
+	return e.csv.Write(exportColumns)
+}
+
+func (e *csvExportEncoder) write(w *bufio.Writer, sub *domain.UserSubscription) error {
+	err := e.csv.Write([]string{
+		sub.ID,
+		sub.ServiceName,
+		sub.Price.String(),
+		sub.Currency,
+		sub.BillingPeriod,
+		sub.UserID.String(),
+		sub.StartDate,
+		sub.EndDate,
+		sub.UpdatedAt.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return err
+	}
+
+	// csv.Writer buffers on its own, hand the row over to w right away.
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func (e *csvExportEncoder) end(w *bufio.Writer) error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+type jsonLinesExportEncoder struct{}
+
+func (e *jsonLinesExportEncoder) contentType() string {
+	return "application/x-ndjson"
+}
+
+func (e *jsonLinesExportEncoder) begin(w *bufio.Writer) error {
+	return nil
+}
+
+func (e *jsonLinesExportEncoder) write(w *bufio.Writer, sub *domain.UserSubscription) error {
+	return json.NewEncoder(w).Encode(sub)
+}
+
+func (e *jsonLinesExportEncoder) end(w *bufio.Writer) error {
+	return nil
+}
+
+type jsonExportEncoder struct {
+	rows int
+}
+
+func (e *jsonExportEncoder) contentType() string {
+	return "application/json"
+}
+
+func (e *jsonExportEncoder) begin(w *bufio.Writer) error {
+	return w.WriteByte('[')
+}
+
+func (e *jsonExportEncoder) write(w *bufio.Writer, sub *domain.UserSubscription) error {
+	if e.rows > 0 {
+		if err := w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.rows++
+
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (e *jsonExportEncoder) end(w *bufio.Writer) error {
+	_, err := w.WriteString("]\n")
+	return err
+}
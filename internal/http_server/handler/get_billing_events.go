@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetBillingEventsHandler godoc
+// @Summary      Get subscription billing events
+// @Description  Returns the billing events of a subscription ordered by period: a charge for the first billing
+// @Description  period and a renewal for every later one. Events are emitted by the renewal scheduler once a
+// @Description  period begins; the period a subscription ends in is cut at the end date and prorated.
+// @Tags Subscription
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      200  {array}   domain.BillingEvent
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      404  {object}  resp.ErrorResponse "User subscription not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id}/billing_events [get]
+func (h *UserSubscriptionHandler) GetBillingEventsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetBillingEventsHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid user subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.service.BillingEvents(ctx, id)
+	if err != nil {
+		log.Error("failed to get billing events", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get billing events", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, events, http.StatusOK)
+}
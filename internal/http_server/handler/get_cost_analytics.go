@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type CostAnalyticsResponse struct {
+	GroupBy   []string             `json:"group_by"`
+	Currency  string               `json:"currency"`
+	StartDate string               `json:"start_date"`
+	EndDate   string               `json:"end_date,omitempty"`
+	Buckets   []*domain.CostBucket `json:"buckets"`
+}
+
+// GetCostAnalyticsHandler godoc
+// @Summary      Get subscription cost analytics
+// @Description  Aggregates subscription spend and active subscription counts over a period.
+// @Description  Buckets are grouped by any combination of service_name, month and user_id.
+// @Description  The period spans at most 120 months.
+// @Tags Total Cost
+// @Produce      json
+// @Param        group_by     query    string false "Comma separated group-by fields: service_name, month, user_id" default(month)
+// @Param        start_date   query    string true  "Period start (YYYY-MM-DD or MM-YYYY)"
+// @Param        end_date     query    string false "Period end (YYYY-MM-DD or MM-YYYY), defaults to the end of the current month"
+// @Param        user_id      query    string false "User UUID filter"
+// @Param        service_name query    string false "Service name filter"
+// @Param        currency     query    string false "ISO 4217 currency the spend is converted to" default(RUB)
+// @Success      200 {object} CostAnalyticsResponse
+// @Failure      400 {object} resp.ErrorResponse "Invalid request"
+// @Failure      401 {object} resp.ErrorResponse "Authentication required"
+// @Failure      403 {object} resp.ErrorResponse "Access denied"
+// @Failure      422 {object} resp.ErrorResponse "No exchange rate for a currency"
+// @Failure      429 {object} resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500 {object} resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/analytics [get]
+func (h *UserSubscriptionHandler) GetCostAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetCostAnalyticsHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	query := r.URL.Query()
+
+	req := dto.CostAnalytics{
+		GroupBy:     []string{billing.GroupByMonth},
+		ServiceName: query.Get("service_name"),
+		Currency:    query.Get("currency"),
+		StartDate:   query.Get("start_date"),
+		EndDate:     query.Get("end_date"),
+	}
+
+	if groupBy := query.Get("group_by"); groupBy != "" {
+		req.GroupBy = strings.Split(groupBy, ",")
+		for i := range req.GroupBy {
+			req.GroupBy[i] = strings.TrimSpace(req.GroupBy[i])
+		}
+	}
+
+	if userIdStr := query.Get("user_id"); userIdStr != "" {
+		userId, err := uuid.Parse(userIdStr)
+		if err != nil {
+			log.Error("failed to parse user_id as UUID", sl.Err(err))
+			resp.Error(w, "invalid user_id format (must be a valid UUID)", http.StatusBadRequest)
+			return
+		}
+		req.UserID = userId
+	}
+
+	err := valid.ValidateDates(req.StartDate, req.EndDate)
+	if err != nil {
+		log.Error("invalid request", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := h.service.CostAnalytics(ctx, req)
+	if err != nil {
+		log.Error("failed to get cost analytics", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get cost analytics", http.StatusInternalServerError)
+		return
+	}
+
+	response := CostAnalyticsResponse{
+		GroupBy:   req.GroupBy,
+		Currency:  cmp.Or(req.Currency, domain.DefaultCurrency),
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Buckets:   buckets,
+	}
+
+	resp.ResponseOk(w, response, http.StatusOK)
+}
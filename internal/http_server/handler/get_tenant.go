@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetTenantHandler godoc
+// @Summary      Get tenant
+// @Description  Reports the subscriptions, users and webhooks of a tenant.
+// @Tags Tenant
+// @Produce      json
+// @Param        tenant  path      string  true  "Tenant ID"
+// @Success      200     {object}  domain.TenantReport
+// @Failure      401     {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403     {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404     {object}  resp.ErrorResponse "Tenant not found"
+// @Failure      429     {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500     {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /tenants/{tenant} [get]
+func (h *TenantHandler) GetTenantHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetTenantHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	report, err := h.service.Report(ctx, chi.URLParam(r, "tenant"))
+	if err != nil {
+		log.Error("failed to get tenant", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get tenant", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, report, http.StatusOK)
+}
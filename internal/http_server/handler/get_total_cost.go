@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"subscription/internal/domain"
 	"subscription/internal/http_server/dto"
 	"subscription/internal/lib/api/er"
 	"subscription/internal/lib/api/resp"
@@ -15,22 +16,40 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
 )
 
 type TotalCostResponse struct {
-	TotalCost int64 `json:"total_cost"`
+	TotalCost     decimal.Decimal            `json:"total_cost" swaggertype:"string" example:"2997.00"`
+	Currency      string                     `json:"currency"`
+	Conversion    *domain.Conversion         `json:"conversion"`
+	Subscriptions []*domain.SubscriptionCost `json:"subscriptions"`
 }
 
 // GetTotalCostHandler godoc
 // @Summary      Get total user subscription cost
-// @Description  Returns the total cost of a user's subscriptions for the specified period
+// @Description  Returns the total cost of a user's subscriptions for the specified period.
+// @Description  Every subscription is billed its price for each billing period (weekly, monthly,
+// @Description  quarterly or yearly, counted from its start date) within the period; periods only
+// @Description  partly covered are prorated by days. Open-ended subscriptions are billed up to the
+// @Description  end of the period. Dates are YYYY-MM-DD or MM-YYYY, a month ending on its last day.
+// @Description  Service name and end date are optional, a period without end date lasts until the
+// @Description  end of the current month. The period spans at most 120 months.
+// @Description  Costs are converted to currency (RUB by default), conversion lists the exchange
+// @Description  rates and their date used for it.
 // @Tags Total Cost
 // @Accept       json
 // @Produce      json
 // @Param        request body dto.TotalCost true "Request data"
 // @Success      200 {object} TotalCostResponse
 // @Failure      400 {object} resp.ErrorResponse "Invalid request"
+// @Failure      401 {object} resp.ErrorResponse "Authentication required"
+// @Failure      403 {object} resp.ErrorResponse "Access denied"
+// @Failure      422 {object} resp.ErrorResponse "No exchange rate for a currency"
+// @Failure      429 {object} resp.ErrorResponse "Rate limit exceeded"
 // @Failure      500 {object} resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/total_cost [get]
 func (h *UserSubscriptionHandler) GetTotalCostHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handler.GetTotalCostHandler"
@@ -82,7 +101,12 @@ func (h *UserSubscriptionHandler) GetTotalCostHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	response := TotalCostResponse{TotalCost: totalCost}
+	response := TotalCostResponse{
+		TotalCost:     totalCost.TotalCost,
+		Currency:      totalCost.Currency,
+		Conversion:    totalCost.Conversion,
+		Subscriptions: totalCost.Subscriptions,
+	}
 
 	resp.ResponseOk(w, response, http.StatusOK)
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/etag"
 	"subscription/internal/lib/api/resp"
 	"subscription/internal/lib/logger/sl"
 
@@ -19,9 +20,14 @@ import (
 // @Tags Subscription
 // @Param        id   path      int  true  "Subscription ID"
 // @Success      200  {object}  domain.UserSubscription "Request data"
+// @Header       200  {string}  ETag "Version of the subscription, use it in If-Match"
 // @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
 // @Failure      404  {object}  resp.ErrorResponse "User subscription not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
 // @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions/{id} [get]
 func (h *UserSubscriptionHandler) GetUserSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handler.GetUserSubscriptionHandler"
@@ -55,5 +61,6 @@ func (h *UserSubscriptionHandler) GetUserSubscriptionHandler(w http.ResponseWrit
 		return
 	}
 
+	w.Header().Set("ETag", etag.Format(subscription.UpdatedAt))
 	resp.ResponseOk(w, subscription, http.StatusOK)
 }
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetUserSubscriptionHistoryHandler godoc
+// @Summary      Get user subscription history
+// @Description  Returns the change history of a subscription, oldest first. Every record holds the subscription
+// @Description  before and after the change, who made it and in which request. Deleted subscriptions keep their history.
+// @Tags Subscription
+// @Produce      json
+// @Param        id   path      int  true  "Subscription ID"
+// @Success      200  {array}   domain.HistoryRecord
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      404  {object}  resp.ErrorResponse "User subscription not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id}/history [get]
+func (h *UserSubscriptionHandler) GetUserSubscriptionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetUserSubscriptionHistoryHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid user subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.service.History(ctx, id)
+	if err != nil {
+		log.Error("failed to get user subscription history", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get user subscription history", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, records, http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetWebhookHandler godoc
+// @Summary      Get webhook
+// @Description  Returns a webhook without its secret.
+// @Tags Webhook
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  domain.Webhook
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404  {object}  resp.ErrorResponse "Webhook not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetWebhookHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.service.GetById(ctx, id)
+	if err != nil {
+		log.Error("failed to get webhook", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get webhook", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, hook, http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetWebhookDeliveryHandler godoc
+// @Summary      Get webhook delivery
+// @Description  Returns a delivery with its payload and the log of every attempt to send it.
+// @Tags Webhook
+// @Produce      json
+// @Param        id   path      int  true  "Delivery ID"
+// @Success      200  {object}  domain.WebhookDelivery
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404  {object}  resp.ErrorResponse "Webhook delivery not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks/deliveries/{id} [get]
+func (h *WebhookHandler) GetWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.GetWebhookDeliveryHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid webhook delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.service.Delivery(ctx, id)
+	if err != nil {
+		log.Error("failed to get webhook delivery", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get webhook delivery", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, delivery, http.StatusOK)
+}
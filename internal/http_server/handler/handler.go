@@ -5,31 +5,36 @@ import (
 	"log/slog"
 	"subscription/internal/domain"
 	"subscription/internal/http_server/dto"
-	"subscription/internal/usecases"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 type UserSubUseCases interface {
 	Add(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error)
+	Import(ctx context.Context, subs []dto.CreateUserSubDTO, atomic bool) ([]domain.ImportResult, error)
 	GetById(ctx context.Context, id int) (*domain.UserSubscription, error)
-	GetListByUUID(ctx context.Context, userId uuid.UUID) ([]*domain.UserSubscription, error)
+	List(ctx context.Context, filter dto.ListUserSubs) (*domain.UserSubscriptionPage, error)
+	Export(ctx context.Context, filter dto.ListUserSubs, fn func(sub *domain.UserSubscription) error) error
 	DeleteById(ctx context.Context, id int) error
+	RestoreById(ctx context.Context, id int) (*domain.UserSubscription, error)
 	UpdateById(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error)
-	TotalCost(ctx context.Context, cost dto.TotalCost) (int64, error)
+	TotalCost(ctx context.Context, cost dto.TotalCost) (*domain.TotalCost, error)
+	CostAnalytics(ctx context.Context, analytics dto.CostAnalytics) ([]*domain.CostBucket, error)
+	History(ctx context.Context, id int) ([]*domain.HistoryRecord, error)
+	BillingEvents(ctx context.Context, id int) ([]*domain.BillingEvent, error)
 }
 
 type UserSubscriptionHandler struct {
-	log     *slog.Logger
-	service UserSubUseCases
-	timeOut time.Duration
+	log           *slog.Logger
+	service       UserSubUseCases
+	timeOut       time.Duration
+	importTimeOut time.Duration
 }
 
 func NewUserSubscriptionHandler(
-	service *usecases.UserSubscriptionService,
+	service UserSubUseCases,
 	l *slog.Logger,
 	timeOut time.Duration,
+	importTimeOut time.Duration,
 ) *UserSubscriptionHandler {
-	return &UserSubscriptionHandler{service: service, log: l, timeOut: timeOut}
+	return &UserSubscriptionHandler{service: service, log: l, timeOut: timeOut, importTimeOut: importTimeOut}
 }
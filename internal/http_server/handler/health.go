@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type HealthChecker interface {
+	Ready(ctx context.Context) map[string]error
+}
+
+type HealthHandler struct {
+	log     *slog.Logger
+	checker HealthChecker
+	timeOut time.Duration
+}
+
+func NewHealthHandler(
+	checker HealthChecker,
+	l *slog.Logger,
+	timeOut time.Duration,
+) *HealthHandler {
+	return &HealthHandler{checker: checker, log: l, timeOut: timeOut}
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	// Checks holds the errors of the failed checks by name.
+	Checks map[string]string `json:"checks,omitempty"`
+}
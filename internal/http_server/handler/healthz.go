@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"net/http"
+	"subscription/internal/lib/api/resp"
+)
+
+// HealthzHandler godoc
+// @Summary      Liveness probe
+// @Description  Answers as long as the process serves requests, without checking its dependencies.
+// @Tags Health
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Router       /healthz [get]
+func (h *HealthHandler) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	resp.ResponseOk(w, HealthResponse{Status: "ok"}, http.StatusOK)
+}
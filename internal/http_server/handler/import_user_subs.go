@@ -0,0 +1,445 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+
+	importFormatCSV   = "csv"
+	importFormatJSONL = "jsonl"
+
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+
+	importStatusCreated = "created"
+	importStatusFailed  = "failed"
+	importStatusSkipped = "skipped"
+)
+
+type ImportRowResult struct {
+	// Line is the line of the row in the uploaded file.
+	Line   int    `json:"line"`
+	Status string `json:"status" enums:"created,failed,skipped"`
+	ID     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	Mode    string `json:"mode"`
+	Total   int    `json:"total"`
+	Created int    `json:"created"`
+	Failed  int    `json:"failed"`
+	// Skipped rows weren't stored, the import was rejected or timed out.
+	Skipped int               `json:"skipped"`
+	Rows    []ImportRowResult `json:"rows"`
+}
+
+type importRow struct {
+	line int
+	sub  dto.CreateUserSubDTO
+	err  error
+}
+
+// ImportUserSubscriptionsHandler godoc
+// @Summary      Import user subscriptions
+// @Description  Creates subscriptions from a CSV or JSON lines upload, sent as the request body or as the "file" field of a multipart form.
+// @Description  CSV files need a header with the columns service_name, price, user_id, start_date and optionally currency, billing_period and end_date.
+// @Description  In atomic mode nothing is created unless every row is valid and stored, best_effort mode creates every row it can.
+// @Description  The response reports the outcome of each row. Rows left when the import times out are skipped, the rows before them are kept in best_effort mode.
+// @Tags Subscription
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        format query string false "File format, detected from Content-Type when omitted" Enums(csv, jsonl)
+// @Param        mode   query string false "Import mode" Enums(atomic, best_effort) default(atomic)
+// @Param        file   formData file false "File to import"
+// @Success      200  {object}  ImportResponse "Every row was processed, see rows for failed and skipped rows in best_effort mode"
+// @Failure      400  {object}  resp.ErrorResponse "Invalid format, mode or file"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      413  {object}  resp.ErrorResponse "File too large"
+// @Failure      422  {object}  ImportResponse "Atomic import rejected or timed out, nothing was created"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/import [post]
+func (h *UserSubscriptionHandler) ImportUserSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ImportUserSubscriptionsHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.importTimeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = importModeAtomic
+	}
+	if mode != importModeAtomic && mode != importModeBestEffort {
+		resp.Error(w, fmt.Sprintf("invalid mode %q, must be one of [%s %s]", mode, importModeAtomic, importModeBestEffort), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	body, contentType, err := importFile(r)
+	if err != nil {
+		log.Error("failed to read upload", sl.Err(err))
+
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(w, fmt.Sprintf("file is larger than %d bytes", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp.Error(w, "invalid upload", http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	format, err := importFormat(r.URL.Query().Get("format"), contentType)
+	if err != nil {
+		resp.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []importRow
+	switch format {
+	case importFormatCSV:
+		rows, err = parseImportCSV(body)
+	default:
+		rows, err = parseImportJSONLines(body)
+	}
+	if err != nil {
+		log.Error("failed to parse upload", sl.Err(err))
+
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			resp.Error(w, fmt.Sprintf("file is larger than %d bytes", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp.Error(w, fmt.Sprintf("invalid %s file: %s", format, err), http.StatusBadRequest)
+		return
+	}
+
+	if len(rows) == 0 {
+		resp.Error(w, "file has no rows", http.StatusBadRequest)
+		return
+	}
+	if len(rows) > maxImportRows {
+		resp.Error(w, fmt.Sprintf("file has more than %d rows", maxImportRows), http.StatusBadRequest)
+		return
+	}
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+
+	var (
+		subs  []dto.CreateUserSubDTO
+		index []int
+	)
+	for i := range rows {
+		if rows[i].err == nil {
+			rows[i].err = validateImportRow(validWithOpts, rows[i].sub)
+		}
+		if rows[i].err == nil {
+			subs = append(subs, rows[i].sub)
+			index = append(index, i)
+		}
+	}
+
+	response := ImportResponse{
+		Mode:  mode,
+		Total: len(rows),
+		Rows:  make([]ImportRowResult, len(rows)),
+	}
+	for i, row := range rows {
+		response.Rows[i] = ImportRowResult{Line: row.line}
+		if row.err != nil {
+			response.Rows[i].Status = importStatusFailed
+			response.Rows[i].Error = row.err.Error()
+			response.Failed++
+		}
+	}
+
+	atomic := mode == importModeAtomic
+
+	if atomic && response.Failed > 0 {
+		rejectImport(w, response)
+		return
+	}
+
+	if len(subs) > 0 {
+		results, err := h.service.Import(ctx, subs, atomic)
+		if err != nil {
+			log.Error("failed to import user subscriptions", sl.Err(err))
+
+			resp.Error(w, "failed to import user subscriptions", http.StatusInternalServerError)
+			return
+		}
+
+		for i, result := range results {
+			row := &response.Rows[index[i]]
+			if errors.Is(result.Err, domain.ErrImportInterrupted) {
+				row.Status = importStatusSkipped
+				row.Error = "import timed out before the row was stored"
+				response.Skipped++
+				continue
+			}
+			if result.Err != nil {
+				row.Status = importStatusFailed
+				row.Error = importErrorMessage(result.Err)
+				response.Failed++
+				continue
+			}
+			row.ID = result.ID
+			row.Status = importStatusCreated
+			response.Created++
+		}
+
+		if response.Skipped > 0 {
+			log.Warn("import timed out", slog.Int("skipped", response.Skipped))
+		}
+		if atomic && response.Failed+response.Skipped > 0 {
+			log.Info("atomic import rejected")
+			rejectImport(w, response)
+			return
+		}
+	}
+
+	resp.ResponseOk(w, response, http.StatusOK)
+}
+
+// rejectImport reports an atomic import of which nothing was kept.
+func rejectImport(w http.ResponseWriter, response ImportResponse) {
+	response.Skipped = 0
+	for i := range response.Rows {
+		if response.Rows[i].Status != importStatusFailed {
+			response.Rows[i].Status = importStatusSkipped
+			response.Rows[i].ID = 0
+			response.Skipped++
+		}
+	}
+	response.Created = 0
+
+	resp.ResponseOk(w, response, http.StatusUnprocessableEntity)
+}
+
+// importFile returns the uploaded file and its content type, taken either
+// from the "file" field of a multipart form or from the raw body.
+func importFile(r *http.Request) (io.ReadCloser, string, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		return r.Body, contentType, nil
+	}
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		return nil, "", err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType = header.Header.Get("Content-Type")
+	switch {
+	case strings.HasSuffix(header.Filename, ".csv"):
+		contentType = "text/csv"
+	case strings.HasSuffix(header.Filename, ".jsonl"), strings.HasSuffix(header.Filename, ".ndjson"):
+		contentType = "application/x-ndjson"
+	}
+
+	return file, contentType, nil
+}
+
+func importFormat(format, contentType string) (string, error) {
+	switch format {
+	case importFormatCSV, importFormatJSONL:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid format %q, must be one of [%s %s]", format, importFormatCSV, importFormatJSONL)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return importFormatCSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines", "application/json":
+		return importFormatJSONL, nil
+	default:
+		return "", fmt.Errorf("can't detect file format from content type %q, pass format", contentType)
+	}
+}
+
+var importColumns = []string{"service_name", "price", "user_id", "start_date", "currency", "billing_period", "end_date"}
+
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range importColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header has no %s column", name)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(rows) == maxImportRows {
+			// One row over the limit is enough to reject the file.
+			return append(rows, importRow{line: line}), nil
+		}
+
+		rows = append(rows, csvImportRow(line, columns, record))
+	}
+
+	return rows, nil
+}
+
+func csvImportRow(line int, columns map[string]int, record []string) importRow {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := importRow{
+		line: line,
+		sub: dto.CreateUserSubDTO{
+			ServiceName:   field("service_name"),
+			Currency:      field("currency"),
+			BillingPeriod: field("billing_period"),
+			StartDate:     field("start_date"),
+			EndDate:       field("end_date"),
+		},
+	}
+
+	price, err := decimal.NewFromString(field("price"))
+	if err != nil {
+		row.err = fmt.Errorf("field price must be a decimal number")
+		return row
+	}
+	row.sub.Price = price
+
+	userID, err := uuid.Parse(field("user_id"))
+	if err != nil {
+		row.err = fmt.Errorf("field user_id must be a valid UUID")
+		return row
+	}
+	row.sub.UserID = userID
+
+	return row
+}
+
+func parseImportJSONLines(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportSize)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows) == maxImportRows {
+			return append(rows, importRow{line: line}), nil
+		}
+
+		row := importRow{line: line}
+		if err := json.Unmarshal(data, &row.sub); err != nil {
+			row.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// validateImportRow applies the checks of AddUserSubscriptionHandler to a row.
+func validateImportRow(v *validator.Validate, sub dto.CreateUserSubDTO) error {
+	if err := valid.ValidateDates(sub.StartDate, sub.EndDate); err != nil {
+		return err
+	}
+
+	if err := valid.ValidatePrice("price", sub.Price); err != nil {
+		return err
+	}
+
+	if err := v.Struct(sub); err != nil {
+		var validateErr validator.ValidationErrors
+		if errors.As(err, &validateErr) {
+			return errors.New(valid.ValidationError(validateErr, sub))
+		}
+		return err
+	}
+
+	return nil
+}
+
+func importErrorMessage(err error) string {
+	if msg, _, ok := er.MapErrorToStatus(err); ok {
+		return msg
+	}
+	return "failed to create user subscription"
+}
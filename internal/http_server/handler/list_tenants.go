@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ListTenantsHandler godoc
+// @Summary      List tenants
+// @Description  Reports the subscriptions, users and webhooks of every tenant. Admins bound to a tenant only see their own.
+// @Tags Tenant
+// @Produce      json
+// @Success      200  {array}   domain.TenantReport
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /tenants [get]
+func (h *TenantHandler) ListTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ListTenantsHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	reports, err := h.service.List(ctx)
+	if err != nil {
+		log.Error("failed to list tenants", sl.Err(err))
+
+		resp.Error(w, "failed to list tenants", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, reports, http.StatusOK)
+}
@@ -2,30 +2,62 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"subscription/internal/http_server/dto"
 	"subscription/internal/lib/api/er"
 	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
 	"subscription/internal/lib/logger/sl"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultListLimit = 50
+	defaultSortBy    = "id"
+	defaultOrder     = "asc"
 )
 
 // GetListUserSubscriptionHandler godoc
-// @Summary      Get list of user subscriptions
-// @Description  Returns a list of a user's subscriptions by their UUID
+// @Summary      List subscriptions
+// @Description  Returns a page of subscriptions matching the filters. Without filters subscriptions of all users are listed.
+// @Description  Pages are ordered by sort_by and id; pass next_cursor of the previous page as cursor to get the next one.
 // @Tags Subscription
 // @Accept       json
 // @Produce      json
-// @Param        user_id query     string true "User UUID"
-// @Success      200 {array}  domain.UserSubscription
-// @Failure      400 {object} resp.ErrorResponse "Invalid UUID or missing parameter"
-// @Failure      404 {object} resp.ErrorResponse "User not found"
+// @Param        user_id             query string false "User UUID"
+// @Param        service_name        query string false "Exact service name"
+// @Param        service_name_prefix query string false "Service name prefix"
+// @Param        min_price           query string false "Minimal price"
+// @Param        max_price           query string false "Maximal price"
+// @Param        active_on           query string false "Subscriptions active on the day (YYYY-MM-DD) or in the month (MM-YYYY)"
+// @Param        start_from          query string false "Start date lower bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        start_to            query string false "Start date upper bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        end_from            query string false "End date lower bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        end_to              query string false "End date upper bound (YYYY-MM-DD or MM-YYYY)"
+// @Param        sort_by             query string false "Sort column" Enums(id, service_name, price, user_id, start_date, end_date, created_at, updated_at) default(id)
+// @Param        order               query string false "Sort order" Enums(asc, desc) default(asc)
+// @Param        limit               query int    false "Page size (1-1000)" default(50)
+// @Param        cursor              query string false "Cursor of the next page"
+// @Success      200 {object} domain.UserSubscriptionPage
+// @Failure      400 {object} resp.ErrorResponse "Invalid filter, sort or cursor"
+// @Failure      401 {object} resp.ErrorResponse "Authentication required"
+// @Failure      403 {object} resp.ErrorResponse "Access denied"
+// @Failure      429 {object} resp.ErrorResponse "Rate limit exceeded"
 // @Failure      500 {object} resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
 // @Router       /subscriptions [get]
 func (h *UserSubscriptionHandler) GetListUserSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
-	const op = "handler.GetUserSubscriptionHandler"
+	const op = "handler.GetListUserSubscriptionHandler"
 
 	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
 	defer cancel()
@@ -35,30 +67,109 @@ func (h *UserSubscriptionHandler) GetListUserSubscriptionHandler(w http.Response
 		slog.String("request_url", middleware.GetReqID(ctx)),
 	)
 
-	userIdStr := r.URL.Query().Get("user_id")
-	if userIdStr == "" {
-		log.Error("user_id is missing in query parameters")
-		resp.Error(w, "user_id is required in query parameters", http.StatusBadRequest)
+	req, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		log.Error("invalid query parameters", sl.Err(err))
+		resp.Error(w, fmt.Sprintf("invalid query parameters: %s", err), http.StatusBadRequest)
 		return
 	}
 
-	userId, err := uuid.Parse(userIdStr)
-	if err != nil {
-		log.Error("failed to parse user_id as UUID", sl.Err(err))
-		resp.Error(w, "invalid user_id format (must be a valid UUID)", http.StatusBadRequest)
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
 		return
 	}
 
-	subs, err := h.service.GetListByUUID(ctx, userId)
+	page, err := h.service.List(ctx, req)
 	if err != nil {
 		log.Error("failed to get user subscriptions", sl.Err(err))
 		if msg, code, ok := er.MapErrorToStatus(err); ok {
 			resp.Error(w, msg, code)
 			return
 		}
-		resp.Error(w, "failed to get user subscriptions", http.StatusBadRequest)
+		resp.Error(w, "failed to get user subscriptions", http.StatusInternalServerError)
 		return
 	}
 
-	resp.ResponseOk(w, subs, http.StatusOK)
+	resp.ResponseOk(w, page, http.StatusOK)
+}
+
+func parseListQuery(query url.Values) (dto.ListUserSubs, error) {
+	req := dto.ListUserSubs{
+		ServiceName:       query.Get("service_name"),
+		ServiceNamePrefix: query.Get("service_name_prefix"),
+		ActiveOn:          query.Get("active_on"),
+		StartFrom:         query.Get("start_from"),
+		StartTo:           query.Get("start_to"),
+		EndFrom:           query.Get("end_from"),
+		EndTo:             query.Get("end_to"),
+		SortBy:            defaultSortBy,
+		Order:             defaultOrder,
+		Limit:             defaultListLimit,
+		Cursor:            query.Get("cursor"),
+	}
+
+	if v := query.Get("user_id"); v != "" {
+		userId, err := uuid.Parse(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid user_id format (must be a valid UUID)")
+		}
+		req.UserID = userId
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **decimal.Decimal
+	}{
+		{"min_price", &req.MinPrice},
+		{"max_price", &req.MaxPrice},
+	} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := decimal.NewFromString(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid %s: must be a decimal number", p.name)
+		}
+		if err := valid.ValidatePrice(p.name, n); err != nil {
+			return req, err
+		}
+		*p.dst = &n
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid limit: must be an integer")
+		}
+		req.Limit = n
+	}
+	if v := query.Get("sort_by"); v != "" {
+		req.SortBy = v
+	}
+	if v := query.Get("order"); v != "" {
+		req.Order = v
+	}
+
+	for _, p := range []struct {
+		name  string
+		value string
+	}{
+		{"active_on", req.ActiveOn},
+		{"start_from", req.StartFrom},
+		{"start_to", req.StartTo},
+		{"end_from", req.EndFrom},
+		{"end_to", req.EndTo},
+	} {
+		if err := valid.ValidateDate(p.name, p.value); err != nil {
+			return req, err
+		}
+	}
+
+	return req, nil
 }
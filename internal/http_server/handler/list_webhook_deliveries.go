@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+// ListWebhookDeliveriesHandler godoc
+// @Summary      List webhook deliveries
+// @Description  Returns the latest deliveries of a webhook, newest first. Pending deliveries are attempted
+// @Description  once next_attempt_at passes; failed ones ran out of attempts and can be redelivered.
+// @Tags Webhook
+// @Produce      json
+// @Param        id      path      int     true   "Webhook ID"
+// @Param        status  query     string  false  "Delivery status" Enums(pending, delivered, failed)
+// @Param        limit   query     int     false  "Maximum number of deliveries (1-1000)" default(50)
+// @Success      200  {array}   domain.WebhookDelivery
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID or filter"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404  {object}  resp.ErrorResponse "Webhook not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ListWebhookDeliveriesHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	req := dto.ListWebhookDeliveries{
+		Status: r.URL.Query().Get("status"),
+		Limit:  defaultListLimit,
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			log.Error("invalid query parameters", sl.Err(err))
+			resp.Error(w, "invalid query parameters: invalid limit: must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.service.Deliveries(ctx, id, req)
+	if err != nil {
+		log.Error("failed to list webhook deliveries", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to list webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []*domain.WebhookDelivery{}
+	}
+
+	resp.ResponseOk(w, deliveries, http.StatusOK)
+}
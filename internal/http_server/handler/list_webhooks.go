@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"subscription/internal/domain"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ListWebhooksHandler godoc
+// @Summary      List webhooks
+// @Description  Returns the registered webhooks without their secrets.
+// @Tags Webhook
+// @Produce      json
+// @Success      200  {array}   domain.Webhook
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks [get]
+func (h *WebhookHandler) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ListWebhooksHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	hooks, err := h.service.List(ctx)
+	if err != nil {
+		log.Error("failed to list webhooks", sl.Err(err))
+
+		resp.Error(w, "failed to list webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	if hooks == nil {
+		hooks = []*domain.Webhook{}
+	}
+
+	resp.ResponseOk(w, hooks, http.StatusOK)
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/etag"
+	"subscription/internal/lib/api/resp"
+	valid "subscription/internal/lib/api/valid"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// PatchSubscriptionHandler godoc
+// @Summary      Partially update user subscription
+// @Description  Applies a JSON Merge Patch (RFC 7396) to a subscription. Only the fields present in the body change,
+// @Description  "end_date": null removes the end date. Send the ETag of the subscription in If-Match to make sure
+// @Description  nobody changed it since it was read.
+// @Tags Subscription
+// @Accept       json
+// @Produce      json
+// @Param        id       path   int    true  "Subscription ID"
+// @Param        If-Match header string false "ETag of the subscription version the patch is based on"
+// @Param        request  body   dto.PatchUserSubDTO true "Fields to change"
+// @Success      200  {object}  domain.UserSubscription
+// @Header       200  {string}  ETag "Version of the updated subscription"
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID or request body"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Access denied"
+// @Failure      404  {object}  resp.ErrorResponse "User subscription not found"
+// @Failure      409  {object}  resp.ErrorResponse "User subscription conflicts with existing record"
+// @Failure      412  {object}  resp.ErrorResponse "Subscription was modified since it was read"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Error updating subscription"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id} [patch]
+func (h *UserSubscriptionHandler) PatchSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.PatchSubscriptionHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid user subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	cond, err := etag.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		log.Error("invalid If-Match header", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid If-Match header: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		log.Error("failed to decode request", sl.Err(err))
+
+		resp.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The row compared with If-Match must be the latest one, not a lagging
+	// replica's or a cached one.
+	current, err := h.service.GetById(storage.WithPrimary(ctx), id)
+	if err != nil {
+		log.Error("failed to get user subscription", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to get user subscription", http.StatusInternalServerError)
+		return
+	}
+
+	if !cond.Matches(current.UpdatedAt) {
+		log.Info("If-Match precondition failed")
+
+		resp.Error(w, "user subscription was modified, reload it and retry", http.StatusPreconditionFailed)
+		return
+	}
+
+	req, err := applyMergePatch(current, patch)
+	if err != nil {
+		log.Error("invalid patch", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+	req.ID = id
+	req.Version = &current.UpdatedAt
+
+	err = valid.ValidateDates(req.StartDate, req.EndDate)
+	if err != nil {
+		log.Error("invalid request body", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := valid.ValidatePrice("price", req.Price); err != nil {
+		log.Error("invalid request body", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
+	if err := validWithOpts.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Error("invalid request", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request: %s", valid.ValidationError(validateErr, req)), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := h.service.UpdateById(ctx, req)
+	if err != nil {
+		log.Error("failed to update user subscription", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+		resp.Error(w, "failed to update user subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag.Format(sub.UpdatedAt))
+	resp.ResponseOk(w, sub, http.StatusOK)
+}
+
+// applyMergePatch merges the patch document into the current subscription.
+// Members set to null are removed, which is only allowed for end_date.
+func applyMergePatch(current *domain.UserSubscription, patch map[string]json.RawMessage) (dto.UpdateUserSubDTO, error) {
+	req := dto.UpdateUserSubDTO{
+		ServiceName:   current.ServiceName,
+		Price:         current.Price,
+		Currency:      current.Currency,
+		BillingPeriod: current.BillingPeriod,
+		UserID:        current.UserID,
+		StartDate:     current.StartDate,
+		EndDate:       current.EndDate,
+	}
+
+	for field, raw := range patch {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+
+		var dst any
+		switch field {
+		case "service_name":
+			dst = &req.ServiceName
+		case "price":
+			dst = &req.Price
+		case "currency":
+			dst = &req.Currency
+		case "billing_period":
+			dst = &req.BillingPeriod
+		case "user_id":
+			dst = &req.UserID
+		case "start_date":
+			dst = &req.StartDate
+		case "end_date":
+			if isNull {
+				req.EndDate = ""
+				continue
+			}
+			dst = &req.EndDate
+		default:
+			return req, fmt.Errorf("unknown field %s", field)
+		}
+
+		if isNull {
+			return req, fmt.Errorf("field %s can't be removed", field)
+		}
+		if err := json.Unmarshal(raw, dst); err != nil {
+			return req, fmt.Errorf("invalid value of field %s", field)
+		}
+	}
+
+	if req.UserID == uuid.Nil {
+		return req, fmt.Errorf("field user_id must be a valid UUID")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"subscription/internal/lib/api/resp"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ReadyzHandler godoc
+// @Summary      Readiness probe
+// @Description  Reports whether the service can take requests: the database answers, its migrations are at the expected version and the service isn't shutting down.
+// @Tags Health
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Failure      503  {object}  HealthResponse "Not ready, checks holds the reasons"
+// @Router       /readyz [get]
+func (h *HealthHandler) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.ReadyzHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	failed := h.checker.Ready(ctx)
+	if len(failed) == 0 {
+		resp.ResponseOk(w, HealthResponse{Status: "ready"}, http.StatusOK)
+		return
+	}
+
+	response := HealthResponse{Status: "unavailable", Checks: make(map[string]string, len(failed))}
+	for name, err := range failed {
+		response.Checks[name] = err.Error()
+	}
+
+	h.log.Warn("not ready",
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+		slog.Any("checks", response.Checks),
+	)
+
+	resp.ResponseOk(w, response, http.StatusServiceUnavailable)
+}
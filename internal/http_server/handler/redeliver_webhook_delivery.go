@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RedeliverWebhookDeliveryHandler godoc
+// @Summary      Redeliver webhook delivery
+// @Description  Queues a delivery to be sent again right away with a fresh retry budget, whatever its status.
+// @Description  The payload is sent unchanged, so receivers can deduplicate on the event ID.
+// @Tags Webhook
+// @Produce      json
+// @Param        id   path      int  true  "Delivery ID"
+// @Success      202  {object}  domain.WebhookDelivery
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Admin role required"
+// @Failure      404  {object}  resp.ErrorResponse "Webhook delivery not found"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /webhooks/deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) RedeliverWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.RedeliverWebhookDeliveryHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid webhook delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.service.Redeliver(ctx, id)
+	if err != nil {
+		log.Error("failed to redeliver webhook delivery", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to redeliver webhook delivery", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ResponseOk(w, delivery, http.StatusAccepted)
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/etag"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RestoreUserSubscriptionHandler godoc
+// @Summary      Restore user subscription
+// @Description  Restores a soft-deleted user subscription by ID
+// @Tags Subscription
+// @Param        id   path      int  true  "User subscription ID"
+// @Success      200  {object}  domain.UserSubscription "Restored subscription"
+// @Header       200  {string}  ETag "Version of the subscription, use it in If-Match"
+// @Failure      400  {object}  resp.ErrorResponse "Invalid ID"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      404  {object}  resp.ErrorResponse "Deleted user subscription not found"
+// @Failure      409  {object}  resp.ErrorResponse "Subscription overlaps with a live one"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
+// @Failure      500  {object}  resp.ErrorResponse "Server error"
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions/{id}/restore [post]
+func (h *UserSubscriptionHandler) RestoreUserSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.RestoreUserSubscriptionHandler"
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeOut)
+	defer cancel()
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_url", middleware.GetReqID(ctx)),
+	)
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Error("failed to parse id", sl.Err(err))
+
+		resp.Error(w, "invalid user subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := h.service.RestoreById(ctx, id)
+	if err != nil {
+		log.Error("failed to restore user subscription", sl.Err(err))
+		if msg, code, ok := er.MapErrorToStatus(err); ok {
+			resp.Error(w, msg, code)
+			return
+		}
+
+		resp.Error(w, "failed to restore user subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag.Format(subscription.UpdatedAt))
+	resp.ResponseOk(w, subscription, http.StatusOK)
+}
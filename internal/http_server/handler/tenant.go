@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/domain"
+	"time"
+)
+
+type TenantUseCases interface {
+	List(ctx context.Context) ([]*domain.TenantReport, error)
+	Report(ctx context.Context, id string) (*domain.TenantReport, error)
+}
+
+type TenantHandler struct {
+	log     *slog.Logger
+	service TenantUseCases
+	timeOut time.Duration
+}
+
+func NewTenantHandler(
+	service TenantUseCases,
+	l *slog.Logger,
+	timeOut time.Duration,
+) *TenantHandler {
+	return &TenantHandler{service: service, log: l, timeOut: timeOut}
+}
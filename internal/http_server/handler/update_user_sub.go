@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"subscription/internal/http_server/dto"
 	"subscription/internal/lib/api/er"
+	"subscription/internal/lib/api/etag"
 	"subscription/internal/lib/api/resp"
 	valid "subscription/internal/lib/api/valid"
 	"subscription/internal/lib/logger/sl"
@@ -23,12 +24,19 @@ import (
 // @Tags Subscription
 // @Accept       json
 // @Produce      json
-// @Param        id   path      int  true  "Subscription ID"
+// @Param        If-Match header string false "ETag of the subscription version the update is based on"
 // @Param        request body      dto.UpdateUserSubDTO true  "Data for updating the subscription"
 // @Success      201  {object}  domain.UserSubscription
+// @Header       201  {string}  ETag "Version of the updated subscription"
 // @Failure      400  {object}  resp.ErrorResponse "Invalid ID or request body"
+// @Failure      401  {object}  resp.ErrorResponse "Authentication required"
+// @Failure      403  {object}  resp.ErrorResponse "Access denied"
+// @Failure      412  {object}  resp.ErrorResponse "Subscription was modified since it was read"
+// @Failure      429  {object}  resp.ErrorResponse "Rate limit exceeded"
 // @Failure      500  {object}  resp.ErrorResponse "Error updating subscription"
-// @Router       /subscriptions/{id} [put]
+// @Security     ApiKeyAuth
+// @Security     BearerAuth
+// @Router       /subscriptions [put]
 func (h *UserSubscriptionHandler) UpdateSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "handler.AddUserSubscriptionHandler"
 
@@ -49,7 +57,19 @@ func (h *UserSubscriptionHandler) UpdateSubscriptionHandler(w http.ResponseWrite
 		return
 	}
 
-	err := valid.ValidateDates(req.StartDate, req.EndDate)
+	cond, err := etag.ParseIfMatch(r.Header.Get("If-Match"))
+	if err == nil && cond != nil && !cond.Any && len(cond.Versions) > 1 {
+		err = fmt.Errorf("only a single entity tag is supported")
+	}
+	if err != nil {
+		log.Error("invalid If-Match header", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid If-Match header: %s", err), http.StatusBadRequest)
+		return
+	}
+	req.Version = cond.Version()
+
+	err = valid.ValidateDates(req.StartDate, req.EndDate)
 	if err != nil {
 		log.Error("invalid request body", sl.Err(err))
 
@@ -57,6 +77,13 @@ func (h *UserSubscriptionHandler) UpdateSubscriptionHandler(w http.ResponseWrite
 		return
 	}
 
+	if err := valid.ValidatePrice("price", req.Price); err != nil {
+		log.Error("invalid request body", sl.Err(err))
+
+		resp.Error(w, fmt.Sprintf("invalid request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
 	validWithOpts := validator.New(validator.WithRequiredStructEnabled())
 	if err := validWithOpts.Struct(req); err != nil {
 		var validateErr validator.ValidationErrors
@@ -79,5 +106,6 @@ func (h *UserSubscriptionHandler) UpdateSubscriptionHandler(w http.ResponseWrite
 		return
 	}
 
+	w.Header().Set("ETag", etag.Format(sub.UpdatedAt))
 	resp.ResponseOk(w, sub, http.StatusCreated)
 }
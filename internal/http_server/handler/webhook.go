@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"time"
+)
+
+type WebhookUseCases interface {
+	Register(ctx context.Context, dto dto.CreateWebhookDTO) (*domain.Webhook, error)
+	List(ctx context.Context) ([]*domain.Webhook, error)
+	GetById(ctx context.Context, id int) (*domain.Webhook, error)
+	DeleteById(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, webhookID int, filter dto.ListWebhookDeliveries) ([]*domain.WebhookDelivery, error)
+	Delivery(ctx context.Context, id int) (*domain.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id int) (*domain.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	log     *slog.Logger
+	service WebhookUseCases
+	timeOut time.Duration
+}
+
+func NewWebhookHandler(
+	service WebhookUseCases,
+	l *slog.Logger,
+	timeOut time.Duration,
+) *WebhookHandler {
+	return &WebhookHandler{service: service, log: l, timeOut: timeOut}
+}
@@ -0,0 +1,22 @@
+package actor
+
+import (
+	"net/http"
+	"subscription/internal/lib/actor"
+)
+
+const Header = "X-Actor"
+
+// New stores the caller named in the X-Actor header in the request context,
+// so changes can be attributed to it in the audit log.
+func New() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if name := r.Header.Get(Header); name != "" {
+				r = r.WithContext(actor.WithActor(r.Context(), name))
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"subscription/internal/lib/actor"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/logger/sl"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New rejects requests that don't authenticate with 401 and stores the
+// principal of the others in the request context. Changes are attributed to
+// the principal, whatever the X-Actor header claims.
+func New(authenticator *auth.Authenticator, log *slog.Logger) func(http.Handler) http.Handler {
+	log = log.With(slog.String("component", "middleware/auth"))
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				log.Warn("authentication failed",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					sl.Err(err),
+				)
+
+				w.Header().Set("WWW-Authenticate", `Bearer realm="subscriptions"`)
+				resp.Error(w, "authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = actor.WithActor(ctx, principal.Actor())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireAdmin rejects requests of non-admin principals with 403. Requests
+// without principal pass, authentication is disabled for them.
+func RequireAdmin() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := auth.FromContext(r.Context()); ok && !p.Admin() {
+				resp.Error(w, "admin role required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
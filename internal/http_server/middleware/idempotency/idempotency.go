@@ -0,0 +1,142 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/idempotency"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tenant"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const HeaderReplayed = "Idempotent-Replayed"
+
+type Options struct {
+	// TTL is how long a key and its response are kept.
+	TTL time.Duration
+	// Lock is how long a request holds its key before a retry may take it
+	// over, in case the request never finished.
+	Lock time.Duration
+}
+
+// New makes requests carrying the Idempotency-Key header safe to retry. The
+// first response, unless it's a server error, is stored under the key of
+// the client and replayed to the retries. A key reused for another request
+// gets 422, one whose request is still being handled 409.
+func New(store idempotency.Store, opts Options, log *slog.Logger) func(http.Handler) http.Handler {
+	log = log.With(slog.String("component", "middleware/idempotency"))
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.HeaderKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				resp.Error(w, "idempotency key must be at most "+strconv.Itoa(idempotency.MaxKeyLength)+" characters", http.StatusBadRequest)
+				return
+			}
+
+			log := log.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, idempotency.MaxBodySize))
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					resp.Error(w, "request body must be at most "+strconv.Itoa(idempotency.MaxBodySize)+" bytes", http.StatusRequestEntityTooLarge)
+					return
+				}
+				resp.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			tenantID, _ := tenant.FromContext(r.Context())
+			key = tenantID + " " + auth.ClientKey(r) + " " + key
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+
+			now := time.Now()
+			rec, err := store.ReserveIdempotencyKey(r.Context(), key, fingerprint, now, now.Add(-opts.TTL), now.Add(-opts.Lock))
+			if errors.Is(err, idempotency.ErrKeyBusy) {
+				resp.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Error("failed to reserve idempotency key", sl.Err(err))
+				resp.Error(w, "failed to check idempotency key", http.StatusInternalServerError)
+				return
+			}
+
+			switch {
+			case rec == nil:
+			case rec.Fingerprint != fingerprint:
+				resp.Error(w, "idempotency key was used for another request", http.StatusUnprocessableEntity)
+				return
+			case rec.Response == nil:
+				resp.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+				return
+			default:
+				replay(w, rec.Response)
+				return
+			}
+
+			// The outcome is stored even when the client is gone by then.
+			ctx := context.WithoutCancel(r.Context())
+
+			saved := false
+			defer func() {
+				if saved {
+					return
+				}
+				if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+					log.Error("failed to release idempotency key", sl.Err(err))
+				}
+			}()
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			err = store.SaveIdempotentResponse(ctx, key, idempotency.Response{
+				Status:      status,
+				ContentType: ww.Header().Get("Content-Type"),
+				Body:        buf.Bytes(),
+			})
+			if err != nil {
+				log.Error("failed to save idempotent response", sl.Err(err))
+				return
+			}
+			saved = true
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func replay(w http.ResponseWriter, r *idempotency.Response) {
+	if r.ContentType != "" {
+		w.Header().Set("Content-Type", r.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(r.Status)
+	_, _ = w.Write(r.Body)
+}
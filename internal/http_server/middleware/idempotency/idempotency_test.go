@@ -0,0 +1,167 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"subscription/internal/lib/idempotency"
+	"subscription/internal/storage/memory"
+)
+
+// counter answers with status and the number of requests it handled so far.
+type counter struct {
+	handled atomic.Int32
+	status  int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := c.handled.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c.status)
+	fmt.Fprintf(w, `{"n":%d}`, n)
+}
+
+func newHandler(next http.Handler) http.Handler {
+	opts := Options{TTL: time.Hour, Lock: time.Minute}
+	return New(memory.New(), opts, slog.New(slog.DiscardHandler))(next)
+}
+
+func send(h http.Handler, key, body, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(idempotency.HeaderKey, key)
+	}
+	if remoteAddr != "" {
+		r.RemoteAddr = remoteAddr
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	next := &counter{status: http.StatusCreated}
+	h := newHandler(next)
+
+	first := send(h, "k1", `{"a":1}`, "")
+	if first.Code != http.StatusCreated || first.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("first response: %d, replayed %q", first.Code, first.Header().Get(HeaderReplayed))
+	}
+
+	retry := send(h, "k1", `{"a":1}`, "")
+	if retry.Code != http.StatusCreated || retry.Header().Get(HeaderReplayed) != "true" {
+		t.Errorf("retry: %d, replayed %q, want %d replayed", retry.Code, retry.Header().Get(HeaderReplayed), http.StatusCreated)
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry replayed %q (%s), want %q", retry.Body, retry.Header().Get("Content-Type"), first.Body)
+	}
+	if n := next.handled.Load(); n != 1 {
+		t.Errorf("handled %d times, want once", n)
+	}
+
+	// Keys belong to a client, another one may use the same.
+	if w := send(h, "k1", `{"a":1}`, "198.51.100.7:1234"); w.Header().Get(HeaderReplayed) != "" {
+		t.Error("response replayed to another client")
+	}
+	// Requests without key are never replayed.
+	send(h, "", `{"a":1}`, "")
+	send(h, "", `{"a":1}`, "")
+	if n := next.handled.Load(); n != 4 {
+		t.Errorf("handled %d times, want 4", n)
+	}
+}
+
+func TestKeyReusedForAnotherRequest(t *testing.T) {
+	next := &counter{status: http.StatusCreated}
+	h := newHandler(next)
+
+	send(h, "k1", `{"a":1}`, "")
+	if w := send(h, "k1", `{"a":2}`, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("another body: %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if n := next.handled.Load(); n != 1 {
+		t.Errorf("handled %d times, want once", n)
+	}
+}
+
+func TestRequestInProgress(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	h := newHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send(h, "k1", `{"a":1}`, "") }()
+	<-entered
+
+	if w := send(h, "k1", `{"a":1}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("concurrent retry: %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Errorf("first request: %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w := send(h, "k1", `{"a":1}`, ""); w.Code != http.StatusCreated || w.Header().Get(HeaderReplayed) != "true" {
+		t.Errorf("retry once done: %d, replayed %q", w.Code, w.Header().Get(HeaderReplayed))
+	}
+}
+
+func TestServerErrorsAreNotStored(t *testing.T) {
+	next := &counter{status: http.StatusInternalServerError}
+	h := newHandler(next)
+
+	send(h, "k1", `{"a":1}`, "")
+	next.status = http.StatusCreated
+	if w := send(h, "k1", `{"a":1}`, ""); w.Code != http.StatusCreated || w.Header().Get(HeaderReplayed) != "" {
+		t.Errorf("retry after a server error: %d, replayed %q, want a new %d", w.Code, w.Header().Get(HeaderReplayed), http.StatusCreated)
+	}
+	if n := next.handled.Load(); n != 2 {
+		t.Errorf("handled %d times, want twice", n)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	h := newHandler(&counter{status: http.StatusCreated})
+
+	if w := send(h, strings.Repeat("k", idempotency.MaxKeyLength+1), `{}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	body := `{"a":"` + string(bytes.Repeat([]byte("x"), idempotency.MaxBodySize)) + `"}`
+	if w := send(h, "k1", body, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// busyStore finds every key changing under it.
+type busyStore struct {
+	idempotency.Store
+}
+
+func (busyStore) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, now, expiredBefore, staleBefore time.Time) (*idempotency.Record, error) {
+	return nil, idempotency.ErrKeyBusy
+}
+
+func TestKeyBusy(t *testing.T) {
+	next := &counter{status: http.StatusCreated}
+	h := New(busyStore{}, Options{TTL: time.Hour, Lock: time.Minute}, slog.New(slog.DiscardHandler))(next)
+
+	if w := send(h, "k1", `{"a":1}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("busy key: %d, want %d", w.Code, http.StatusConflict)
+	}
+	if n := next.handled.Load(); n != 0 {
+		t.Errorf("handled %d times, want never", n)
+	}
+}
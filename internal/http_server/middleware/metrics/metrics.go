@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"subscription/internal/lib/metrics"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatched labels the requests no route matched, so that random paths
+// don't create series of their own.
+const unmatched = "unmatched"
+
+// New counts the requests and measures their latency by route pattern.
+func New(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			start := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				m.ObserveHTTP(r.Method, Route(r), status, time.Since(start))
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Route returns the pattern of the route that served r.
+func Route(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return unmatched
+}
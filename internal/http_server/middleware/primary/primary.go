@@ -0,0 +1,85 @@
+package primary
+
+import (
+	"net/http"
+	"subscription/internal/lib/auth"
+	"subscription/internal/storage"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// sweepInterval is how often expired pins are dropped.
+const sweepInterval = time.Minute
+
+type pins struct {
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+}
+
+// New pins a client that changed data to the primary database for window,
+// so that it reads its own writes even when replicas lag behind. Pins are
+// kept by this process, a client served by another replica of the service
+// isn't pinned there.
+func New(window time.Duration) func(http.Handler) http.Handler {
+	p := &pins{until: make(map[string]time.Time)}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := auth.ClientKey(r)
+			if p.pinned(client, time.Now()) {
+				r = r.WithContext(storage.WithPrimary(r.Context()))
+			}
+
+			if !mutates(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			// Rejected requests changed nothing.
+			if status := ww.Status(); status == 0 || status < http.StatusBadRequest {
+				p.pin(client, time.Now().Add(window))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func mutates(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *pins) pinned(client string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return now.Before(p.until[client])
+}
+
+func (p *pins) pin(client string, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.until[client] = until
+
+	now := time.Now()
+	if now.Sub(p.lastSweep) < sweepInterval {
+		return
+	}
+	p.lastSweep = now
+	for c, u := range p.until {
+		if !now.Before(u) {
+			delete(p.until, c)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/ratelimit"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type Options struct {
+	// Default limits the routes without a limit of their own.
+	Default ratelimit.Limit
+	// Routes limits routes by method and pattern, like
+	// "GET /subscriptions/total_cost". Each has a bucket of its own.
+	Routes map[string]ratelimit.Limit
+	// Key identifies the client of a request, auth.ClientKey when nil.
+	Key func(r *http.Request) string
+}
+
+// New limits the requests of every client: the API key or the user of the
+// principal, or the IP address when there is none. Rejected requests get
+// 429 with Retry-After, every response gets the RateLimit headers. Requests
+// pass when the store fails, a broken store mustn't take the API down.
+func New(store ratelimit.Store, opts Options, log *slog.Logger) func(http.Handler) http.Handler {
+	log = log.With(slog.String("component", "middleware/ratelimit"))
+	if opts.Key == nil {
+		opts.Key = auth.ClientKey
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			limit, route := opts.Default, "*"
+			if pattern := routePattern(r); pattern != "" {
+				if l, ok := opts.Routes[pattern]; ok {
+					limit, route = l, pattern
+				}
+			}
+
+			res, err := store.Take(r.Context(), opts.Key(r)+" "+route, limit, time.Now())
+			if err != nil {
+				log.Error("failed to check rate limit",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					sl.Err(err),
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Period)))
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+				resp.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ByIP keys requests by their IP address whoever they authenticate as, so
+// that requests failing authentication are limited too.
+func ByIP(r *http.Request) string {
+	return "addr:" + auth.ClientIP(r)
+}
+
+// routePattern returns the method and pattern of the route r is headed to,
+// which isn't known to middlewares before routing.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := rctx.RoutePath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+
+	return r.Method + " " + tctx.RoutePattern()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,52 @@
+package tenant
+
+import (
+	"log/slog"
+	"net/http"
+	"subscription/internal/lib/api/resp"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/tenant"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New scopes the request to a tenant. A principal bound to a tenant always
+// works in it. Admins that aren't bound, and callers when authentication is
+// disabled, pick the tenant with the header. Anybody else works in the
+// fallback tenant. Naming a tenant the caller may not use is rejected with
+// 403.
+func New(header, fallback string, log *slog.Logger) func(http.Handler) http.Handler {
+	log = log.With(slog.String("component", "middleware/tenant"))
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			requested := r.Header.Get(header)
+			p, authenticated := auth.FromContext(r.Context())
+
+			id := fallback
+			switch {
+			case authenticated && p.Tenant != "":
+				id = p.Tenant
+			case requested != "" && (!authenticated || p.Admin()):
+				id = requested
+			}
+
+			if requested != "" && requested != id {
+				log.Warn("tenant access denied",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("tenant", requested),
+				)
+				resp.Error(w, "access to tenant denied", http.StatusForbidden)
+				return
+			}
+
+			if !tenant.Valid(id) {
+				resp.Error(w, "invalid tenant id", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
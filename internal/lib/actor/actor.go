@@ -0,0 +1,23 @@
+package actor
+
+import "context"
+
+const (
+	Anonymous = "anonymous"
+	// System attributes changes made by background jobs.
+	System = "system"
+)
+
+type ctxKey struct{}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, actor)
+}
+
+// FromContext returns who performs the request, or Anonymous when unknown.
+func FromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(ctxKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
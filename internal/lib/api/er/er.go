@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/fx"
 	"subscription/internal/storage"
 )
 
@@ -18,6 +21,22 @@ func MapErrorToStatus(err error) (string, int, bool) {
 		return "user subscription already exists", http.StatusConflict, true
 	case errors.Is(err, storage.ErrOverlap):
 		return "user subscription conflicts with existing record", http.StatusConflict, true
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return "user subscription was modified, reload it and retry", http.StatusPreconditionFailed, true
+	case errors.Is(err, storage.ErrWebhookNotFound):
+		return "webhook not found", http.StatusNotFound, true
+	case errors.Is(err, storage.ErrDeliveryNotFound):
+		return "webhook delivery not found", http.StatusNotFound, true
+	case errors.Is(err, storage.ErrTenantNotFound):
+		return "tenant not found", http.StatusNotFound, true
+	case errors.Is(err, storage.ErrInvalidCursor):
+		return "invalid cursor", http.StatusBadRequest, true
+	case errors.Is(err, auth.ErrForbidden):
+		return "access denied", http.StatusForbidden, true
+	case errors.Is(err, billing.ErrWindowTooLong):
+		return billing.ErrWindowTooLong.Error(), http.StatusBadRequest, true
+	case errors.Is(err, fx.ErrNoRate):
+		return "no exchange rate to convert to the requested currency", http.StatusUnprocessableEntity, true
 	default:
 		return "", 0, false
 	}
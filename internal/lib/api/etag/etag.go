@@ -0,0 +1,76 @@
+package etag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid entity tag")
+
+// Format renders the version of a resource as a strong entity tag.
+func Format(version time.Time) string {
+	return fmt.Sprintf(`"%d"`, version.UnixMicro())
+}
+
+// Condition is a parsed If-Match header.
+type Condition struct {
+	Any      bool
+	Versions []time.Time
+}
+
+// ParseIfMatch parses an If-Match header. An empty header yields nil, which
+// means the request is unconditional.
+func ParseIfMatch(header string) (*Condition, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+	if header == "*" {
+		return &Condition{Any: true}, nil
+	}
+
+	var cond Condition
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			return nil, fmt.Errorf("%w: weak tags can't be used with If-Match", ErrInvalid)
+		}
+
+		raw, err := strconv.Unquote(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, tag)
+		}
+		micros, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, tag)
+		}
+
+		cond.Versions = append(cond.Versions, time.UnixMicro(micros).UTC())
+	}
+
+	return &cond, nil
+}
+
+// Matches reports whether the current version satisfies the condition.
+func (c *Condition) Matches(version time.Time) bool {
+	if c == nil || c.Any {
+		return true
+	}
+	for _, v := range c.Versions {
+		if v.Equal(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// Version returns the single version the condition pins, if any.
+func (c *Condition) Version() *time.Time {
+	if c == nil || len(c.Versions) != 1 {
+		return nil
+	}
+	return &c.Versions[0]
+}
@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
-	"time"
+	"subscription/internal/domain"
+	"subscription/internal/lib/billing"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
 )
 
+// ValidateDates checks the bounds of a subscription or a billing window. Both
+// accept YYYY-MM-DD or MM-YYYY, a month ending on its last day.
 func ValidateDates(startDateStr, endDateStr string) error {
-	const layout = "01-2006"
-
-	startDate, err := time.Parse(layout, startDateStr)
+	startDate, err := billing.ParseDate(startDateStr)
 	if err != nil {
 		return fmt.Errorf("invalid start_date format: %w", err)
 	}
@@ -20,18 +22,43 @@ func ValidateDates(startDateStr, endDateStr string) error {
 	if endDateStr == "" {
 		return nil
 	}
-	endDate, err := time.Parse(layout, endDateStr)
+	endDate, err := billing.ParseDate(endDateStr)
 	if err != nil {
 		return fmt.Errorf("invalid end_date format: %w", err)
 	}
 
-	if !endDate.After(startDate) {
-		return fmt.Errorf("end_date must be after start_date")
+	if endDate.To.Before(startDate.From) {
+		return fmt.Errorf("end_date must not be before start_date")
 	}
 
 	return nil
 }
 
+func ValidateDate(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := billing.ParseDate(value); err != nil {
+		return fmt.Errorf("invalid %s format: %w", field, err)
+	}
+	return nil
+}
+
+var maxPrice = decimal.New(1, domain.PriceDigits-domain.PricePlaces)
+
+func ValidatePrice(field string, price decimal.Decimal) error {
+	if price.IsNegative() {
+		return fmt.Errorf("field %s must be at least 0", field)
+	}
+	if !price.LessThan(maxPrice) {
+		return fmt.Errorf("field %s must be less than %s", field, maxPrice)
+	}
+	if !price.Equal(price.Truncate(domain.PricePlaces)) {
+		return fmt.Errorf("field %s must have at most %d decimal places", field, domain.PricePlaces)
+	}
+	return nil
+}
+
 func ValidationError(errs validator.ValidationErrors, req interface{}) string {
 	var errMsgs []string
 
@@ -44,7 +71,7 @@ func ValidationError(errs validator.ValidationErrors, req interface{}) string {
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		jsonTag := field.Tag.Get("json")
+		jsonTag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
 		if jsonTag == "" {
 			jsonTag = field.Name
 		}
@@ -53,6 +80,9 @@ func ValidationError(errs validator.ValidationErrors, req interface{}) string {
 
 	for _, err := range errs {
 		fieldName := err.Field()
+		if i := strings.Index(fieldName, "["); i > 0 {
+			fieldName = fieldName[:i]
+		}
 		jsonName, ok := fieldToJSON[fieldName]
 		if !ok {
 			jsonName = fieldName
@@ -62,16 +92,29 @@ func ValidationError(errs validator.ValidationErrors, req interface{}) string {
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is required", jsonName))
 		case "min":
 			switch fieldName {
-			case "ServiceName":
+			case "ServiceName", "Secret":
 				errMsgs = append(errMsgs, fmt.Sprintf("field %s must be at least %s characters long", jsonName, err.Param()))
-			case "Price":
+			case "Price", "MinPrice", "MaxPrice", "Limit":
 				errMsgs = append(errMsgs, fmt.Sprintf("field %s must be at least %s", jsonName, err.Param()))
+			case "Events":
+				errMsgs = append(errMsgs, fmt.Sprintf("field %s must list at least %s event", jsonName, err.Param()))
 			default:
 				errMsgs = append(errMsgs, fmt.Sprintf("field %s has a minimum value requirement", jsonName))
 			}
 
 		case "max":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be no more than %s characters long", jsonName, err.Param()))
+			switch fieldName {
+			case "ServiceName", "URL", "Secret":
+				errMsgs = append(errMsgs, fmt.Sprintf("field %s must be no more than %s characters long", jsonName, err.Param()))
+			default:
+				errMsgs = append(errMsgs, fmt.Sprintf("field %s must be no more than %s", jsonName, err.Param()))
+			}
+		case "oneof":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be one of [%s]", jsonName, err.Param()))
+		case "http_url":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be an http or https URL", jsonName))
+		case "iso4217":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be an ISO 4217 currency code", jsonName))
 		case "uuid4":
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s must be a valid UUID", jsonName))
 		default:
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
+	ErrForbidden       = errors.New("access denied")
+)
+
+// Principal is the authenticated caller. Users are identified by the user
+// id in the subject of their token, API keys by their name. Tenant binds the
+// principal to one tenant, principals without one may pick any.
+type Principal struct {
+	Subject string
+	UserID  uuid.UUID
+	Role    string
+	Method  string
+	Tenant  string
+}
+
+func (p *Principal) Admin() bool {
+	return p.Role == RoleAdmin
+}
+
+// Actor names the principal in the audit log.
+func (p *Principal) Actor() string {
+	if p.Method == MethodAPIKey {
+		return MethodAPIKey + ":" + p.Subject
+	}
+	return p.Subject
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok
+}
+
+// Restricted returns the user whose subscriptions ctx is limited to. Admins
+// aren't restricted, nor are contexts without principal: background jobs
+// and requests served with authentication disabled.
+func Restricted(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := FromContext(ctx)
+	if !ok || p.Admin() {
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+// CanAccess reports whether ctx may touch the subscriptions of userID.
+func CanAccess(ctx context.Context, userID uuid.UUID) bool {
+	restricted, ok := Restricted(ctx)
+	return !ok || restricted == userID
+}
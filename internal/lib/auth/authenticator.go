@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderAPIKey = "X-API-Key"
+
+	// leeway tolerates clock skew between the token issuer and us.
+	leeway = 30 * time.Second
+)
+
+type Options struct {
+	// APIKeys maps key names to keys. API keys authenticate services and
+	// act as admins.
+	APIKeys map[string]string
+	// HS256Secret verifies HS256 tokens.
+	HS256Secret string
+	// JWKS verifies RS256 tokens.
+	JWKS *JWKSFile
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// AdminRole in the role or roles claim makes the token an admin one.
+	AdminRole string
+	// TenantClaim names the claim binding a token to a tenant.
+	TenantClaim string
+}
+
+// Authenticator identifies the caller of a request by its API key or its
+// bearer token.
+type Authenticator struct {
+	apiKeys     map[[sha256.Size]byte]string
+	secret      []byte
+	jwks        *JWKSFile
+	adminRole   string
+	tenantClaim string
+	parser      *jwt.Parser
+}
+
+func New(opts Options) *Authenticator {
+	a := &Authenticator{
+		apiKeys:     make(map[[sha256.Size]byte]string, len(opts.APIKeys)),
+		jwks:        opts.JWKS,
+		adminRole:   opts.AdminRole,
+		tenantClaim: opts.TenantClaim,
+	}
+
+	// Keys are looked up by digest, so the lookup time says nothing
+	// about how close a guess came.
+	for name, key := range opts.APIKeys {
+		a.apiKeys[sha256.Sum256([]byte(key))] = name
+	}
+
+	var methods []string
+	if opts.HS256Secret != "" {
+		a.secret = []byte(opts.HS256Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.JWKS != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	// Without a key to verify them with, bearer tokens aren't accepted. An
+	// empty list of methods would let the parser accept any.
+	if len(methods) == 0 {
+		return a
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	a.parser = jwt.NewParser(parserOpts...)
+
+	return a
+}
+
+// Authenticate returns the principal of the request or an error wrapping
+// ErrUnauthenticated.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return a.apiKey(key)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && token != "" {
+		return a.token(strings.TrimSpace(token))
+	}
+
+	return nil, ErrUnauthenticated
+}
+
+// ClientKey identifies the client of the request by the API key or the user
+// of its principal, or by the IP address when there is none.
+func ClientKey(r *http.Request) string {
+	if p, ok := FromContext(r.Context()); ok {
+		if p.Method == MethodAPIKey {
+			return "key:" + p.Subject
+		}
+		return "user:" + p.Subject
+	}
+
+	return "ip:" + ClientIP(r)
+}
+
+// ClientIP returns the IP address the request comes from.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (a *Authenticator) apiKey(key string) (*Principal, error) {
+	name, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+
+	return &Principal{Subject: name, Role: RoleAdmin, Method: MethodAPIKey}, nil
+}
+
+func (a *Authenticator) token(raw string) (*Principal, error) {
+	if a.parser == nil {
+		return nil, fmt.Errorf("%w: bearer tokens aren't accepted", ErrUnauthenticated)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	p := &Principal{Subject: subject, Role: RoleUser, Method: MethodJWT}
+	if a.tenantClaim != "" {
+		if claim, ok := claims[a.tenantClaim]; ok {
+			if p.Tenant, ok = claim.(string); !ok || p.Tenant == "" {
+				return nil, fmt.Errorf("%w: invalid %s claim", ErrUnauthenticated, a.tenantClaim)
+			}
+		}
+	}
+
+	if a.adminRole != "" && slices.Contains(roles(claims), a.adminRole) {
+		p.Role = RoleAdmin
+		return p, nil
+	}
+
+	// Users may only touch their own subscriptions, which requires
+	// knowing who they are.
+	if p.UserID, err = uuid.Parse(subject); err != nil {
+		return nil, fmt.Errorf("%w: subject is not a user id", ErrUnauthenticated)
+	}
+
+	return p, nil
+}
+
+func (a *Authenticator) key(t *jwt.Token) (any, error) {
+	switch t.Method {
+	case jwt.SigningMethodHS256:
+		// Anybody can sign with an empty secret.
+		if len(a.secret) == 0 {
+			return nil, errors.New("no HS256 secret")
+		}
+		return a.secret, nil
+	case jwt.SigningMethodRS256:
+		if a.jwks == nil {
+			return nil, errors.New("no JWKS")
+		}
+		kid, _ := t.Header["kid"].(string)
+		return a.jwks.Key(kid)
+	default:
+		return nil, errors.New("unexpected signing method")
+	}
+}
+
+// roles collects the role claim, a string, and the roles claim, a list.
+func roles(claims jwt.MapClaims) []string {
+	var roles []string
+	if role, ok := claims["role"].(string); ok {
+		roles = append(roles, role)
+	}
+	if list, ok := claims["roles"].([]any); ok {
+		for _, v := range list {
+			if role, ok := v.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func bearer(t *testing.T, method jwt.SigningMethod, key any) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{"sub": "ops", "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}
+	signed, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("can't sign token: %v", err)
+	}
+	return "Bearer " + signed
+}
+
+func TestForgedTokens(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name  string
+		opts  Options
+		token string
+	}{
+		{"empty secret, API keys only", Options{APIKeys: map[string]string{"ops": "key"}}, bearer(t, jwt.SigningMethodHS256, []byte(""))},
+		{"no key at all", Options{}, bearer(t, jwt.SigningMethodHS256, []byte(""))},
+		{"unsigned, API keys only", Options{APIKeys: map[string]string{"ops": "key"}}, bearer(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)},
+		{"empty secret", Options{HS256Secret: secret}, bearer(t, jwt.SigningMethodHS256, []byte(""))},
+		{"unsigned", Options{HS256Secret: secret}, bearer(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType)},
+		{"HS512", Options{HS256Secret: secret}, bearer(t, jwt.SigningMethodHS512, []byte(secret))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/subscriptions", nil)
+			r.Header.Set("Authorization", tt.token)
+
+			// Admins needn't be users, a token accepted gets through.
+			tt.opts.AdminRole = "admin"
+			p, err := New(tt.opts).Authenticate(r)
+			if !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("Authenticate() = %+v, %v, want %v", p, err, ErrUnauthenticated)
+			}
+		})
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/subscriptions", nil)
+		r.Header.Set("Authorization", bearer(t, jwt.SigningMethodHS256, []byte(secret)))
+
+		p, err := New(Options{HS256Secret: secret, AdminRole: "admin"}).Authenticate(r)
+		if err != nil || p.Role != RoleAdmin {
+			t.Errorf("Authenticate() = %+v, %v, want an admin", p, err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// JWKSFile reads RSA verification keys from a JSON Web Key Set file and
+// rereads it whenever it changes, so keys can be rotated without a restart.
+type JWKSFile struct {
+	path string
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	modTime time.Time
+}
+
+// NewJWKSFile loads the file right away to fail early on a bad one.
+func NewJWKSFile(path string) (*JWKSFile, error) {
+	const op = "auth.NewJWKSFile"
+
+	f := &JWKSFile{path: path}
+	if _, err := f.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f, nil
+}
+
+// Key returns the key with the given id. A token without key id may use the
+// only key of a set holding one.
+func (f *JWKSFile) Key(kid string) (*rsa.PublicKey, error) {
+	keys, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (f *JWKSFile) load() (map[string]*rsa.PublicKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.keys != nil && info.ModTime().Equal(f.modTime) {
+		return f.keys, nil
+	}
+
+	raw, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.keys = keys
+	f.modTime = info.ModTime()
+
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS returns the RSA signing keys of a set by key id. Keys of other
+// types or uses are skipped.
+func parseJWKS(raw []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid exponent: %w", k.Kid, err)
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q: invalid exponent", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+
+	return keys, nil
+}
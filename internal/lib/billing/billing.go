@@ -0,0 +1,512 @@
+package billing
+
+import (
+	"cmp"
+	"fmt"
+	"sort"
+	"subscription/internal/domain"
+	"subscription/internal/lib/fx"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DateLayout  = "2006-01-02"
+	MonthLayout = "01-2006"
+)
+
+// openEnd bounds subscriptions without end date.
+var openEnd = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Period is an inclusive range of days.
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseDate parses an ISO date or, for compatibility, a MM-YYYY month. A date
+// covers a single day and a month all of its days.
+func ParseDate(s string) (Period, error) {
+	if t, err := time.Parse(DateLayout, s); err == nil {
+		return Period{From: t, To: t}, nil
+	}
+
+	t, err := time.Parse(MonthLayout, s)
+	if err != nil {
+		return Period{}, fmt.Errorf("%q is neither YYYY-MM-DD nor MM-YYYY", s)
+	}
+
+	return Period{From: t, To: monthEnd(t)}, nil
+}
+
+// NewPeriod builds a billing window from its bounds. A nil end means the
+// window is open and closes with the month of now.
+func NewPeriod(from time.Time, to *time.Time, now time.Time) Period {
+	p := Period{From: day(from), To: monthEnd(now)}
+	if to != nil {
+		p.To = day(*to)
+	}
+	return p
+}
+
+// Days returns the number of days covered by the period.
+func (p Period) Days() int {
+	if p.To.Before(p.From) {
+		return 0
+	}
+	return julianDay(p.To) - julianDay(p.From) + 1
+}
+
+// Overlap returns the intersection of two periods.
+func (p Period) Overlap(o Period) (Period, bool) {
+	res := Period{From: p.From, To: p.To}
+	if o.From.After(res.From) {
+		res.From = o.From
+	}
+	if o.To.Before(res.To) {
+		res.To = o.To
+	}
+	if res.To.Before(res.From) {
+		return Period{}, false
+	}
+	return res, true
+}
+
+// SubscriptionPeriod returns the days a subscription is active within the
+// window. Open-ended subscriptions are considered active until the window ends.
+func SubscriptionPeriod(sub *domain.UserSubscription, window Period) (Period, bool, error) {
+	start, err := ParseDate(sub.StartDate)
+	if err != nil {
+		return Period{}, false, fmt.Errorf("invalid start_date of subscription %s: %w", sub.ID, err)
+	}
+
+	end := window.To
+	if sub.EndDate != "" {
+		p, err := ParseDate(sub.EndDate)
+		if err != nil {
+			return Period{}, false, fmt.Errorf("invalid end_date of subscription %s: %w", sub.ID, err)
+		}
+		end = p.To
+	}
+
+	active, ok := Period{From: start.From, To: end}.Overlap(window)
+	return active, ok, nil
+}
+
+// cycle is the length of a billing period, either in days or in months.
+type cycle struct {
+	days   int
+	months int
+}
+
+var cycles = map[string]cycle{
+	domain.BillingWeekly:    {days: 7},
+	domain.BillingMonthly:   {months: 1},
+	domain.BillingQuarterly: {months: 3},
+	domain.BillingYearly:    {months: 12},
+}
+
+// start returns the first day of the n-th cycle after anchor. Month based
+// cycles keep the day of anchor, clamped to the length of shorter months.
+func (c cycle) start(anchor time.Time, n int) time.Time {
+	if c.days > 0 {
+		return anchor.AddDate(0, 0, n*c.days)
+	}
+
+	first := time.Date(anchor.Year(), anchor.Month()+time.Month(n*c.months), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, min(anchor.Day(), monthEnd(first).Day())-1)
+}
+
+// first returns the index of a cycle starting no later than t, so that
+// iterating from it doesn't walk every cycle since anchor.
+func (c cycle) first(anchor, t time.Time) int {
+	var n int
+	if c.days > 0 {
+		n = (julianDay(t) - julianDay(anchor)) / c.days
+	} else {
+		n = ((t.Year()-anchor.Year())*12 + int(t.Month()-anchor.Month())) / c.months
+	}
+	return max(n-1, 0)
+}
+
+// Charge returns what a subscription costs for the days of span and the
+// number of billing periods that covers. Cycles start on the start date of
+// the subscription; every cycle fully within span costs the price, a partial
+// one is prorated by days.
+func Charge(sub *domain.UserSubscription, span Period) (cost, periods decimal.Decimal, err error) {
+	c, ok := cycles[cmp.Or(sub.BillingPeriod, domain.DefaultBillingPeriod)]
+	if !ok {
+		return decimal.Decimal{}, decimal.Decimal{}, fmt.Errorf("unknown billing_period %q of subscription %s", sub.BillingPeriod, sub.ID)
+	}
+
+	anchor, err := ParseDate(sub.StartDate)
+	if err != nil {
+		return decimal.Decimal{}, decimal.Decimal{}, fmt.Errorf("invalid start_date of subscription %s: %w", sub.ID, err)
+	}
+
+	for n := c.first(anchor.From, span.From); ; n++ {
+		period := Period{From: c.start(anchor.From, n), To: c.start(anchor.From, n+1).AddDate(0, 0, -1)}
+		if period.From.After(span.To) {
+			break
+		}
+
+		billed, ok := period.Overlap(span)
+		if !ok {
+			continue
+		}
+
+		days, total := decimal.NewFromInt(int64(billed.Days())), decimal.NewFromInt(int64(period.Days()))
+		if days.Equal(total) {
+			cost = cost.Add(sub.Price)
+			periods = periods.Add(decimal.NewFromInt(1))
+			continue
+		}
+
+		cost = cost.Add(sub.Price.Mul(days).DivRound(total, domain.PricePlaces))
+		periods = periods.Add(days.DivRound(total, domain.PricePlaces))
+	}
+
+	return cost, periods, nil
+}
+
+// Renewal is a billing period of a subscription, cut to the days the
+// subscription is active, and its charge. Initial marks the first period.
+type Renewal struct {
+	Period  Period
+	Amount  decimal.Decimal
+	Initial bool
+}
+
+// Renewals returns the billing periods of a subscription that begin after
+// billedUntil and no later than today. A nil billedUntil means nothing was
+// billed yet and periods are returned from the start date on.
+func Renewals(sub *domain.UserSubscription, billedUntil *time.Time, today time.Time) ([]Renewal, error) {
+	c, ok := cycles[cmp.Or(sub.BillingPeriod, domain.DefaultBillingPeriod)]
+	if !ok {
+		return nil, fmt.Errorf("unknown billing_period %q of subscription %s", sub.BillingPeriod, sub.ID)
+	}
+
+	start, err := ParseDate(sub.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_date of subscription %s: %w", sub.ID, err)
+	}
+
+	active := Period{From: start.From, To: openEnd}
+	if sub.EndDate != "" {
+		end, err := ParseDate(sub.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_date of subscription %s: %w", sub.ID, err)
+		}
+		active.To = end.To
+	}
+	if billedUntil != nil {
+		if next := day(*billedUntil).AddDate(0, 0, 1); next.After(active.From) {
+			active.From = next
+		}
+	}
+
+	var renewals []Renewal
+	for n := c.first(start.From, active.From); ; n++ {
+		period := Period{From: c.start(start.From, n), To: c.start(start.From, n+1).AddDate(0, 0, -1)}
+		if period.From.After(active.To) {
+			break
+		}
+
+		billed, ok := period.Overlap(active)
+		if !ok {
+			continue
+		}
+		if billed.From.After(today) {
+			break
+		}
+
+		amount, _, err := Charge(sub, billed)
+		if err != nil {
+			return nil, err
+		}
+
+		renewals = append(renewals, Renewal{Period: billed, Amount: amount, Initial: n == 0})
+	}
+
+	return renewals, nil
+}
+
+// TotalCost charges every subscription for the days it is active within the
+// window and returns the per-subscription breakdown together with the sum
+// converted to currency.
+func TotalCost(subs []*domain.UserSubscription, window Period, rates *fx.Rates, currency string) (*domain.TotalCost, error) {
+	if months(window) > MaxWindowMonths {
+		return nil, ErrWindowTooLong
+	}
+
+	conv := newConversion(rates, currency)
+	total := &domain.TotalCost{
+		Currency:      currency,
+		Conversion:    conv.info,
+		Subscriptions: make([]*domain.SubscriptionCost, 0, len(subs)),
+	}
+
+	for _, sub := range subs {
+		active, ok, err := SubscriptionPeriod(sub, window)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		cost, periods, err := Charge(sub, active)
+		if err != nil {
+			return nil, err
+		}
+
+		converted, err := conv.convert(cost, sub.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		total.Subscriptions = append(total.Subscriptions, &domain.SubscriptionCost{
+			ID:            sub.ID,
+			ServiceName:   sub.ServiceName,
+			Price:         sub.Price,
+			Currency:      sub.Currency,
+			BillingPeriod: sub.BillingPeriod,
+			StartDate:     sub.StartDate,
+			EndDate:       sub.EndDate,
+			Periods:       periods,
+			Cost:          cost,
+			ConvertedCost: converted,
+		})
+		total.TotalCost = total.TotalCost.Add(converted)
+	}
+
+	return total, nil
+}
+
+const (
+	GroupByServiceName = "service_name"
+	GroupByMonth       = "month"
+	GroupByUserID      = "user_id"
+)
+
+// MaxWindowMonths bounds the window of total costs and analytics, which walk
+// every subscription period by period.
+const MaxWindowMonths = 120
+
+var ErrWindowTooLong = fmt.Errorf("window is longer than %d months", MaxWindowMonths)
+
+type bucketKey struct {
+	month       time.Time
+	serviceName string
+	userID      uuid.UUID
+}
+
+// Analyzer splits the spend of subscriptions over a window by calendar month
+// into buckets keyed by the requested group-by fields, one subscription at a
+// time, so that subscriptions can be streamed to it. Spend is converted to
+// currency.
+type Analyzer struct {
+	window                     Period
+	byMonth, byService, byUser bool
+	conv                       *conversion
+	buckets                    map[bucketKey]*domain.CostBucket
+}
+
+func NewAnalyzer(window Period, groupBy []string, rates *fx.Rates, currency string) (*Analyzer, error) {
+	if months(window) > MaxWindowMonths {
+		return nil, ErrWindowTooLong
+	}
+
+	a := &Analyzer{
+		window:  window,
+		conv:    newConversion(rates, currency),
+		buckets: make(map[bucketKey]*domain.CostBucket),
+	}
+	for _, g := range groupBy {
+		switch g {
+		case GroupByMonth:
+			a.byMonth = true
+		case GroupByServiceName:
+			a.byService = true
+		case GroupByUserID:
+			a.byUser = true
+		default:
+			return nil, fmt.Errorf("unknown group_by field %q", g)
+		}
+	}
+
+	return a, nil
+}
+
+// Add adds the spend of sub to the buckets. Every subscription must be added
+// once.
+func (a *Analyzer) Add(sub *domain.UserSubscription) error {
+	active, ok, err := SubscriptionPeriod(sub, a.window)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	counted := make(map[bucketKey]struct{})
+	for month := monthStart(active.From); !month.After(active.To); month = month.AddDate(0, 1, 0) {
+		span, _ := Period{From: month, To: monthEnd(month)}.Overlap(active)
+
+		cost, _, err := Charge(sub, span)
+		if err != nil {
+			return err
+		}
+
+		spend, err := a.conv.convert(cost, sub.Currency)
+		if err != nil {
+			return err
+		}
+
+		var key bucketKey
+		if a.byMonth {
+			key.month = month
+		}
+		if a.byService {
+			key.serviceName = sub.ServiceName
+		}
+		if a.byUser {
+			key.userID = sub.UserID
+		}
+
+		bucket, ok := a.buckets[key]
+		if !ok {
+			bucket = &domain.CostBucket{ServiceName: key.serviceName}
+			if a.byMonth {
+				bucket.Month = month.Format(MonthLayout)
+			}
+			if a.byUser {
+				userID := sub.UserID
+				bucket.UserID = &userID
+			}
+			a.buckets[key] = bucket
+		}
+
+		bucket.Spend = bucket.Spend.Add(spend)
+		if _, ok := counted[key]; !ok {
+			counted[key] = struct{}{}
+			bucket.ActiveSubscriptions++
+		}
+	}
+
+	return nil
+}
+
+// Buckets returns the buckets ordered by month, service name and user.
+func (a *Analyzer) Buckets() []*domain.CostBucket {
+	keys := make([]bucketKey, 0, len(a.buckets))
+	for key := range a.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].month.Equal(keys[j].month) {
+			return keys[i].month.Before(keys[j].month)
+		}
+		if keys[i].serviceName != keys[j].serviceName {
+			return keys[i].serviceName < keys[j].serviceName
+		}
+		return keys[i].userID.String() < keys[j].userID.String()
+	})
+
+	result := make([]*domain.CostBucket, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, a.buckets[key])
+	}
+
+	return result
+}
+
+// Analytics runs an Analyzer over subs.
+func Analytics(
+	subs []*domain.UserSubscription,
+	window Period,
+	groupBy []string,
+	rates *fx.Rates,
+	currency string,
+) ([]*domain.CostBucket, error) {
+	a, err := NewAnalyzer(window, groupBy, rates, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range subs {
+		if err := a.Add(sub); err != nil {
+			return nil, err
+		}
+	}
+
+	return a.Buckets(), nil
+}
+
+// conversion converts amounts to one currency and records the rates it used.
+type conversion struct {
+	rates    *fx.Rates
+	currency string
+	info     *domain.Conversion
+}
+
+func newConversion(rates *fx.Rates, currency string) *conversion {
+	return &conversion{
+		rates:    rates,
+		currency: currency,
+		info: &domain.Conversion{
+			Base:  rates.Base,
+			Date:  rates.Date.Format(fx.DateLayout),
+			Rates: make(map[string]decimal.Decimal),
+		},
+	}
+}
+
+func (c *conversion) convert(amount decimal.Decimal, from string) (decimal.Decimal, error) {
+	if from == "" {
+		from = domain.DefaultCurrency
+	}
+
+	converted, err := c.rates.Convert(amount, from, c.currency)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	if from != c.currency {
+		for _, currency := range []string{from, c.currency} {
+			if currency != c.rates.Base {
+				c.info.Rates[currency] = c.rates.Rates[currency]
+			}
+		}
+	}
+
+	return converted, nil
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// julianDay returns the Julian day number of the date of t. Days are counted
+// from calendar dates, a time.Duration can't span more than 292 years.
+func julianDay(t time.Time) int {
+	a := (14 - int(t.Month())) / 12
+	y := t.Year() + 4800 - a
+	m := int(t.Month()) + 12*a - 3
+	return t.Day() + (153*m+2)/5 + 365*y + y/4 - y/100 + y/400 - 32045
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func monthEnd(t time.Time) time.Time {
+	return monthStart(t).AddDate(0, 1, -1)
+}
+
+// months returns the number of calendar months p touches.
+func months(p Period) int {
+	return (p.To.Year()-p.From.Year())*12 + int(p.To.Month()-p.From.Month()) + 1
+}
@@ -0,0 +1,286 @@
+package billing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"subscription/internal/domain"
+	"subscription/internal/lib/fx"
+
+	"github.com/shopspring/decimal"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+
+	d, err := time.Parse(DateLayout, s)
+	if err != nil {
+		t.Fatalf("bad date %q: %v", s, err)
+	}
+	return d
+}
+
+func period(t *testing.T, from, to string) Period {
+	t.Helper()
+
+	return Period{From: date(t, from), To: date(t, to)}
+}
+
+func TestPeriodDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     int
+	}{
+		{"single day", "2025-01-01", "2025-01-01", 1},
+		{"month", "2025-01-01", "2025-01-31", 31},
+		{"february", "2025-02-01", "2025-02-28", 28},
+		{"leap february", "2024-02-01", "2024-02-29", 29},
+		{"year", "2025-01-01", "2025-12-31", 365},
+		{"leap year", "2024-01-01", "2024-12-31", 366},
+		{"across the leap day", "2023-03-01", "2024-02-29", 366},
+		{"century not leap", "1900-02-28", "1900-03-01", 2},
+		{"400 years", "2000-01-01", "2399-12-31", 146097},
+		{"whole calendar", "0001-01-01", "9999-12-31", 3652059},
+		{"reversed", "2025-01-02", "2025-01-01", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := period(t, tt.from, tt.to).Days(); got != tt.want {
+				t.Errorf("Days() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCycleFirst(t *testing.T) {
+	tests := []struct {
+		name      string
+		cycle     string
+		anchor, t string
+	}{
+		{"weekly", domain.BillingWeekly, "2025-01-01", "2025-03-15"},
+		{"weekly over centuries", domain.BillingWeekly, "0001-01-01", "9999-12-31"},
+		{"weekly before anchor", domain.BillingWeekly, "2025-01-01", "2024-12-01"},
+		{"monthly from month end", domain.BillingMonthly, "2025-01-31", "2025-03-01"},
+		{"quarterly", domain.BillingQuarterly, "2020-11-30", "2025-02-28"},
+		{"yearly from leap day", domain.BillingYearly, "2024-02-29", "2028-02-28"},
+		{"yearly over centuries", domain.BillingYearly, "0001-01-01", "9999-12-31"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, anchor, at := cycles[tt.cycle], date(t, tt.anchor), date(t, tt.t)
+
+			n := c.first(anchor, at)
+			if n > 0 && c.start(anchor, n).After(at) {
+				t.Fatalf("cycle %d starts on %s, after %s", n, c.start(anchor, n).Format(DateLayout), tt.t)
+			}
+			if next := c.start(anchor, n+2); !next.After(at) {
+				t.Errorf("cycle %d starts on %s, more than a cycle before %s", n, c.start(anchor, n).Format(DateLayout), tt.t)
+			}
+		})
+	}
+}
+
+func TestCharge(t *testing.T) {
+	tests := []struct {
+		name          string
+		billing       string
+		price         string
+		start         string
+		from, to      string
+		cost, periods string
+	}{
+		{"full month", domain.BillingMonthly, "100", "2025-01-01", "2025-01-01", "2025-01-31", "100", "1"},
+		{"default billing period", "", "100", "2025-01-01", "2025-01-01", "2025-03-31", "300", "3"},
+		{"partial month", domain.BillingMonthly, "100", "2025-01-01", "2025-01-01", "2025-01-15", "48.3871", "0.4839"},
+		{"mid-month start", domain.BillingMonthly, "100", "2025-01-15", "2025-01-15", "2025-02-14", "100", "1"},
+		{"two partial cycles", domain.BillingMonthly, "100", "2025-01-15", "2025-02-01", "2025-02-28", "95.1613", "0.9516"},
+		{"month-end start, short month", domain.BillingMonthly, "100", "2025-01-31", "2025-01-31", "2025-02-27", "100", "1"},
+		{"month-end start, two cycles", domain.BillingMonthly, "100", "2025-01-31", "2025-01-31", "2025-03-30", "200", "2"},
+		{"month-end start, calendar month", domain.BillingMonthly, "100", "2025-01-31", "2025-02-01", "2025-02-28", "99.6544", "0.9966"},
+		{"month-end start, leap february", domain.BillingMonthly, "100", "2024-01-31", "2024-01-31", "2024-02-28", "100", "1"},
+		{"leap day start, yearly", domain.BillingYearly, "365", "2024-02-29", "2024-02-29", "2025-02-27", "365", "1"},
+		{"leap day start, four years", domain.BillingYearly, "100", "2024-02-29", "2024-02-29", "2028-02-28", "400", "4"},
+		{"leap year, partial", domain.BillingYearly, "366", "2024-01-01", "2024-02-01", "2024-02-29", "29", "0.0792"},
+		{"quarterly, partial", domain.BillingQuarterly, "90", "2025-01-01", "2025-01-01", "2025-01-31", "31", "0.3444"},
+		{"weekly", domain.BillingWeekly, "7", "2025-01-01", "2025-01-01", "2025-01-14", "14", "2"},
+		{"weekly, partial", domain.BillingWeekly, "100", "2025-01-01", "2025-01-01", "2025-01-10", "142.8571", "1.4286"},
+		{"weekly, centuries after start", domain.BillingWeekly, "7", "0001-01-01", "9999-12-01", "9999-12-31", "31", "4.4286"},
+		{"yearly, centuries after start", domain.BillingYearly, "365", "0001-01-01", "9999-01-01", "9999-01-31", "31", "0.0849"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &domain.UserSubscription{
+				ID:            "1",
+				Price:         decimal.RequireFromString(tt.price),
+				BillingPeriod: tt.billing,
+				StartDate:     tt.start,
+			}
+
+			cost, periods, err := Charge(sub, period(t, tt.from, tt.to))
+			if err != nil {
+				t.Fatalf("Charge() error = %v", err)
+			}
+			if want := decimal.RequireFromString(tt.cost); !cost.Equal(want) {
+				t.Errorf("cost = %s, want %s", cost, want)
+			}
+			if want := decimal.RequireFromString(tt.periods); !periods.Equal(want) {
+				t.Errorf("periods = %s, want %s", periods, want)
+			}
+		})
+	}
+}
+
+func TestChargeUnknownBillingPeriod(t *testing.T) {
+	sub := &domain.UserSubscription{ID: "1", Price: decimal.NewFromInt(1), BillingPeriod: "daily", StartDate: "2025-01-01"}
+
+	if _, _, err := Charge(sub, period(t, "2025-01-01", "2025-01-31")); err == nil {
+		t.Fatal("Charge() error = nil, want an error")
+	}
+}
+
+func TestWindowTooLong(t *testing.T) {
+	rates := &fx.Rates{Base: "RUB"}
+
+	tests := []struct {
+		window Period
+		want   error
+	}{
+		{period(t, "2015-01-01", "2024-12-31"), nil},
+		{period(t, "2015-01-01", "2025-01-01"), ErrWindowTooLong},
+	}
+
+	for _, tt := range tests {
+		if _, err := TotalCost(nil, tt.window, rates, "RUB"); !errors.Is(err, tt.want) {
+			t.Errorf("TotalCost(%s - %s) error = %v, want %v", tt.window.From, tt.window.To, err, tt.want)
+		}
+		if _, err := NewAnalyzer(tt.window, []string{GroupByMonth}, rates, "RUB"); !errors.Is(err, tt.want) {
+			t.Errorf("NewAnalyzer(%s - %s) error = %v, want %v", tt.window.From, tt.window.To, err, tt.want)
+		}
+	}
+}
+
+func TestRenewals(t *testing.T) {
+	type renewal struct {
+		from, to, amount string
+		initial          bool
+	}
+
+	tests := []struct {
+		name        string
+		billing     string
+		start, end  string
+		billedUntil string
+		today       string
+		want        []renewal
+	}{
+		{
+			name:    "nothing billed yet",
+			billing: domain.BillingMonthly, start: "2025-01-15", today: "2025-03-14",
+			want: []renewal{
+				{"2025-01-15", "2025-02-14", "100", true},
+				{"2025-02-15", "2025-03-14", "100", false},
+			},
+		},
+		{
+			name:    "starts in the future",
+			billing: domain.BillingMonthly, start: "2025-04-01", today: "2025-03-31",
+		},
+		{
+			name:    "month-end start",
+			billing: domain.BillingMonthly, start: "2025-01-31", today: "2025-03-31",
+			want: []renewal{
+				{"2025-01-31", "2025-02-27", "100", true},
+				{"2025-02-28", "2025-03-30", "100", false},
+				{"2025-03-31", "2025-04-29", "100", false},
+			},
+		},
+		{
+			name:    "billed until the end of a cycle",
+			billing: domain.BillingMonthly, start: "2025-01-31", billedUntil: "2025-02-27", today: "2025-03-31",
+			want: []renewal{
+				{"2025-02-28", "2025-03-30", "100", false},
+				{"2025-03-31", "2025-04-29", "100", false},
+			},
+		},
+		{
+			name:    "billed into a cycle",
+			billing: domain.BillingMonthly, start: "2025-01-01", billedUntil: "2025-01-15", today: "2025-02-01",
+			want: []renewal{
+				{"2025-01-16", "2025-01-31", "51.6129", true},
+				{"2025-02-01", "2025-02-28", "100", false},
+			},
+		},
+		{
+			name:    "ends within a cycle",
+			billing: domain.BillingMonthly, start: "2025-01-31", end: "2025-03-15", today: "2025-12-31",
+			want: []renewal{
+				{"2025-01-31", "2025-02-27", "100", true},
+				{"2025-02-28", "2025-03-15", "51.6129", false},
+			},
+		},
+		{
+			name:    "leap day start",
+			billing: domain.BillingYearly, start: "2024-02-29", today: "2028-02-29",
+			want: []renewal{
+				{"2024-02-29", "2025-02-27", "100", true},
+				{"2025-02-28", "2026-02-27", "100", false},
+				{"2026-02-28", "2027-02-27", "100", false},
+				{"2027-02-28", "2028-02-28", "100", false},
+				{"2028-02-29", "2029-02-27", "100", false},
+			},
+		},
+		{
+			name:    "weekly, centuries billed",
+			billing: domain.BillingWeekly, start: "0001-01-01", billedUntil: "9999-12-20", today: "9999-12-31",
+			want: []renewal{
+				{"9999-12-21", "9999-12-26", "85.7143", false},
+				{"9999-12-27", "9999-12-31", "71.4286", false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &domain.UserSubscription{
+				ID:            "1",
+				Price:         decimal.NewFromInt(100),
+				BillingPeriod: tt.billing,
+				StartDate:     tt.start,
+				EndDate:       tt.end,
+			}
+
+			var billedUntil *time.Time
+			if tt.billedUntil != "" {
+				d := date(t, tt.billedUntil)
+				billedUntil = &d
+			}
+
+			got, err := Renewals(sub, billedUntil, date(t, tt.today))
+			if err != nil {
+				t.Fatalf("Renewals() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d renewals, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				r := got[i]
+				if r.Period != period(t, want.from, want.to) {
+					t.Errorf("renewal %d covers %s..%s, want %s..%s", i,
+						r.Period.From.Format(DateLayout), r.Period.To.Format(DateLayout), want.from, want.to)
+				}
+				if !r.Amount.Equal(decimal.RequireFromString(want.amount)) {
+					t.Errorf("renewal %d amount = %s, want %s", i, r.Amount, want.amount)
+				}
+				if r.Initial != want.initial {
+					t.Errorf("renewal %d initial = %t, want %t", i, r.Initial, want.initial)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"subscription/internal/domain"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderEventID   = "event-id"
+	HeaderEventType = "event-type"
+	HeaderTenantID  = "tenant-id"
+)
+
+// Kafka publishes events to a topic, keyed so that the events of a key land
+// on one partition and are consumed in order.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+func NewKafka(brokers []string, topic string, timeout time.Duration) *Kafka {
+	return &Kafka{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// The relay retries a failed batch as a whole, retrying
+			// single writes here could reorder a partition.
+			MaxAttempts:  1,
+			BatchTimeout: 10 * time.Millisecond,
+			WriteTimeout: timeout,
+		},
+	}
+}
+
+func (k *Kafka) Publish(ctx context.Context, events []*domain.OutboxEvent) error {
+	const op = "broker.Kafka.Publish"
+
+	msgs := make([]kafka.Message, len(events))
+	for i, ev := range events {
+		msgs[i] = kafka.Message{
+			Key:   []byte(ev.Key),
+			Value: ev.Payload,
+			Time:  ev.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: HeaderEventID, Value: []byte(ev.EventID)},
+				{Key: HeaderEventType, Value: []byte(ev.Type)},
+				{Key: HeaderTenantID, Value: []byte(ev.TenantID)},
+			},
+		}
+	}
+
+	if err := k.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
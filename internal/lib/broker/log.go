@@ -0,0 +1,33 @@
+package broker
+
+import (
+	"context"
+	"log/slog"
+	"subscription/internal/domain"
+)
+
+// Log publishes events to the log. It lets the relay drain the outbox when
+// no broker is configured.
+type Log struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *Log {
+	return &Log{log: log.With(slog.String("component", "broker"))}
+}
+
+func (l *Log) Publish(ctx context.Context, events []*domain.OutboxEvent) error {
+	for _, ev := range events {
+		l.log.Debug("published event",
+			slog.String("event_id", ev.EventID),
+			slog.String("type", ev.Type),
+			slog.String("key", ev.Key),
+			slog.String("tenant_id", ev.TenantID),
+		)
+	}
+	return nil
+}
+
+func (l *Log) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package broker
+
+import (
+	"context"
+	"slices"
+	"subscription/internal/domain"
+	"sync"
+)
+
+// Memory publishes events in process: it keeps every published event and
+// hands it to the subscribed handlers, in publishing order. It stands in for
+// a broker in tests and single-process setups.
+type Memory struct {
+	mu       sync.Mutex
+	events   []*domain.OutboxEvent
+	handlers []func(ev *domain.OutboxEvent)
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Subscribe registers a handler called synchronously for every event
+// published from now on.
+func (m *Memory) Subscribe(handler func(ev *domain.OutboxEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append(m.handlers, handler)
+}
+
+func (m *Memory) Publish(ctx context.Context, events []*domain.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ev := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		copied := *ev
+		m.events = append(m.events, &copied)
+
+		for _, handle := range m.handlers {
+			handle(&copied)
+		}
+	}
+
+	return nil
+}
+
+// Events returns the events published so far.
+func (m *Memory) Events() []*domain.OutboxEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.events)
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values under keys for a while. Caches may drop entries at
+// any time, callers treat a miss as a value to load again.
+//
+// LRU keeps the entries in process memory. A cache shared by the replicas
+// of the service, like Redis or memcached, plugs in by implementing Cache.
+type Cache interface {
+	// Get returns the value stored under key, ok is false when there is
+	// none or it expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
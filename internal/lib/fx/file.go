@@ -0,0 +1,60 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileProvider reads rates from a JSON file and rereads it whenever it
+// changes, so rates can be updated without a restart.
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	rates   *Rates
+	modTime time.Time
+}
+
+// NewFileProvider loads the file right away to fail early on a bad one.
+func NewFileProvider(path string) (*FileProvider, error) {
+	const op = "fx.NewFileProvider"
+
+	p := &FileProvider{path: path}
+	if _, err := p.Rates(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+func (p *FileProvider) Rates(ctx context.Context) (*Rates, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+	if p.rates != nil && info.ModTime().Equal(p.modTime) {
+		return p.rates, nil
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rates, err := decodeRates(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+
+	p.rates = rates
+	p.modTime = info.ModTime()
+
+	return rates, nil
+}
@@ -0,0 +1,123 @@
+// Package fx converts amounts between currencies with a table of exchange
+// rates loaded from a file or an HTTP API.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const DateLayout = "2006-01-02"
+
+// Places is the number of decimal places converted amounts are rounded to.
+const Places = 2
+
+// divisionPrecision bounds the digits kept when dividing by a rate, well
+// beyond what survives rounding to Places.
+const divisionPrecision = 16
+
+var ErrNoRate = errors.New("no exchange rate for currency")
+
+// Rates is a table of exchange rates on a date. Rates[c] is how much of
+// currency c one unit of Base buys.
+type Rates struct {
+	Base  string
+	Date  time.Time
+	Rates map[string]decimal.Decimal
+}
+
+// Provider returns the current rate table.
+type Provider interface {
+	Rates(ctx context.Context) (*Rates, error)
+}
+
+// Rate returns how much of currency one unit of the base buys.
+func (r *Rates) Rate(currency string) (decimal.Decimal, error) {
+	if currency == r.Base {
+		return decimal.NewFromInt(1), nil
+	}
+	rate, ok := r.Rates[currency]
+	if !ok {
+		return decimal.Decimal{}, fmt.Errorf("%w %s", ErrNoRate, currency)
+	}
+	return rate, nil
+}
+
+// Convert converts amount of currency from into to. Amounts in another
+// currency are rounded half away from zero to Places, amounts that need no
+// conversion are returned as is.
+func (r *Rates) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, err := r.Rate(from)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	toRate, err := r.Rate(to)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	return amount.Mul(toRate).DivRound(fromRate, divisionPrecision).Round(Places), nil
+}
+
+// ratesFile is the JSON representation of Rates shared by files and APIs:
+//
+//	{"base": "RUB", "date": "2025-01-31", "rates": {"USD": 0.0101, "EUR": 0.0097}}
+type ratesFile struct {
+	Base  string                     `json:"base"`
+	Date  string                     `json:"date"`
+	Rates map[string]decimal.Decimal `json:"rates"`
+}
+
+func decodeRates(r io.Reader) (*Rates, error) {
+	var file ratesFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid rates: %w", err)
+	}
+
+	if len(file.Base) != 3 {
+		return nil, fmt.Errorf("invalid rates: base must be a currency code, got %q", file.Base)
+	}
+
+	date, err := time.Parse(DateLayout, file.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rates date: %w", err)
+	}
+
+	rates := &Rates{
+		Base:  strings.ToUpper(file.Base),
+		Date:  date,
+		Rates: make(map[string]decimal.Decimal, len(file.Rates)),
+	}
+	for currency, rate := range file.Rates {
+		if len(currency) != 3 || !rate.IsPositive() {
+			return nil, fmt.Errorf("invalid rates: bad rate %v for %q", rate, currency)
+		}
+		rates.Rates[strings.ToUpper(currency)] = rate
+	}
+
+	return rates, nil
+}
+
+type static struct {
+	rates *Rates
+}
+
+// Static returns a provider of a fixed rate table.
+func Static(rates *Rates) Provider {
+	return static{rates: rates}
+}
+
+func (s static) Rates(context.Context) (*Rates, error) {
+	return s.rates, nil
+}
@@ -0,0 +1,168 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestConvert(t *testing.T) {
+	rates := &Rates{
+		Base: "RUB",
+		Rates: map[string]decimal.Decimal{
+			"USD": decimal.RequireFromString("0.0125"),
+			"EUR": decimal.RequireFromString("0.0100"),
+			"CNY": decimal.RequireFromString("0.03"),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		amount   string
+		from, to string
+		want     string
+		wantErr  error
+	}{
+		{"same currency kept as is", "10.12345", "USD", "USD", "10.12345", nil},
+		{"from base", "1000", "RUB", "USD", "12.5", nil},
+		{"to base", "12.5", "USD", "RUB", "1000", nil},
+		{"across the base", "10", "USD", "EUR", "8", nil},
+		{"rounded half away from zero", "0.5", "RUB", "EUR", "0.01", nil},
+		{"negative rounded away from zero", "-0.5", "RUB", "EUR", "-0.01", nil},
+		{"repeating division", "1", "CNY", "RUB", "33.33", nil},
+		{"repeating division across the base", "2", "CNY", "EUR", "0.67", nil},
+		{"unknown source", "1", "GBP", "RUB", "", ErrNoRate},
+		{"unknown target", "1", "RUB", "GBP", "", ErrNoRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(decimal.RequireFromString(tt.amount), tt.from, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if want := decimal.RequireFromString(tt.want); !got.Equal(want) {
+				t.Errorf("Convert() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"valid", `{"base": "rub", "date": "2025-01-31", "rates": {"usd": 0.0101}}`, false},
+		{"not json", `rates`, true},
+		{"no base", `{"date": "2025-01-31", "rates": {}}`, true},
+		{"bad date", `{"base": "RUB", "date": "31.01.2025", "rates": {}}`, true},
+		{"bad currency", `{"base": "RUB", "date": "2025-01-31", "rates": {"DOLLAR": 0.01}}`, true},
+		{"zero rate", `{"base": "RUB", "date": "2025-01-31", "rates": {"USD": 0}}`, true},
+		{"negative rate", `{"base": "RUB", "date": "2025-01-31", "rates": {"USD": -1}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates, err := decodeRates(strings.NewReader(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeRates() = %+v, want an error", rates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeRates() error = %v", err)
+			}
+			if rates.Base != "RUB" {
+				t.Errorf("Base = %q, want RUB", rates.Base)
+			}
+			if _, err := rates.Rate("USD"); err != nil {
+				t.Errorf("Rate(USD) error = %v", err)
+			}
+		})
+	}
+}
+
+func TestHTTPProviderKeepsRatesWhenRefreshFails(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"base": "RUB", "date": "2025-01-31", "rates": {"USD": 0.0101}}`))
+	}))
+	defer srv.Close()
+
+	p := NewHTTPProvider(srv.URL, time.Millisecond)
+	ctx := context.Background()
+
+	first, err := p.Rates(ctx)
+	if err != nil {
+		t.Fatalf("first Rates() error = %v", err)
+	}
+
+	fail.Store(true)
+	time.Sleep(2 * time.Millisecond)
+
+	// The refresh runs in the background, the previous rates are served
+	// meanwhile and after it failed.
+	for range 3 {
+		time.Sleep(2 * time.Millisecond)
+		rates, err := p.Rates(ctx)
+		if err != nil {
+			t.Fatalf("Rates() after a failed refresh error = %v", err)
+		}
+		if rates != first {
+			t.Fatalf("Rates() = %+v, want the previous rates", rates)
+		}
+	}
+}
+
+func TestHTTPProviderRetryDelay(t *testing.T) {
+	tests := []struct {
+		ttl      time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{time.Hour, 1, retryBase},
+		{time.Hour, 2, 2 * retryBase},
+		{time.Hour, 3, 4 * retryBase},
+		{time.Hour, 100, time.Hour},
+		{time.Second, 1, time.Second},
+	}
+
+	for _, tt := range tests {
+		p := NewHTTPProvider("", tt.ttl)
+		p.failures = tt.failures
+		if got := p.retryDelay(); got != tt.want {
+			t.Errorf("retryDelay() after %d failures with ttl %s = %s, want %s", tt.failures, tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPProviderFirstFetchFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if rates, err := NewHTTPProvider(srv.URL, time.Hour).Rates(context.Background()); err == nil {
+		t.Fatalf("Rates() = %+v, want an error", rates)
+	}
+}
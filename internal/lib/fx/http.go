@@ -0,0 +1,137 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// retryBase is how long a failed refresh is waited on before the next,
+// doubling after each next failure up to the ttl.
+const retryBase = 5 * time.Second
+
+// HTTPProvider fetches rates from an API answering in the rates file format
+// and caches them for ttl. When a refresh fails the previous rates are used,
+// their date tells how old they are.
+//
+// Refreshes run in the background of the requests, one at a time, and
+// requests meanwhile get the previous rates. Only the very first fetch is
+// waited on.
+type HTTPProvider struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	refreshes singleflight.Group
+
+	mu    sync.Mutex
+	rates *Rates
+	// err is the error of the last refresh, nil once one succeeded.
+	err       error
+	failures  int
+	nextFetch time.Time
+}
+
+func NewHTTPProvider(url string, ttl time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *HTTPProvider) Rates(ctx context.Context) (*Rates, error) {
+	p.mu.Lock()
+	rates, err := p.rates, p.err
+	due := !time.Now().Before(p.nextFetch)
+	p.mu.Unlock()
+
+	if !due {
+		if rates == nil {
+			return nil, err
+		}
+		return rates, nil
+	}
+
+	// The refresh outlives the request starting it, others may wait on it.
+	refreshed := p.refreshes.DoChan("rates", func() (any, error) {
+		return nil, p.refresh(context.WithoutCancel(ctx))
+	})
+	if rates != nil {
+		return rates, nil
+	}
+
+	select {
+	case <-refreshed:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rates == nil {
+		return nil, p.err
+	}
+	return p.rates, nil
+}
+
+func (p *HTTPProvider) refresh(ctx context.Context) error {
+	rates, err := p.fetch(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.err = err
+		p.failures++
+		p.nextFetch = time.Now().Add(p.retryDelay())
+		return err
+	}
+
+	p.rates = rates
+	p.err = nil
+	p.failures = 0
+	p.nextFetch = time.Now().Add(p.ttl)
+
+	return nil
+}
+
+func (p *HTTPProvider) retryDelay() time.Duration {
+	delay := retryBase
+	for i := 1; i < p.failures && delay < p.ttl; i++ {
+		delay *= 2
+	}
+	return min(delay, p.ttl)
+}
+
+func (p *HTTPProvider) fetch(ctx context.Context) (*Rates, error) {
+	const op = "fx.HTTPProvider.fetch"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", op, res.Status)
+	}
+
+	rates, err := decodeRates(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrDraining = errors.New("shutting down")
+
+// Check reports whether a dependency of the service is usable.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker decides whether the service is ready to take requests: it isn't
+// once it started draining, nor while any of its checks fails.
+type Checker struct {
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add registers check under name. Checks must be added before the checker
+// is used.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes the service unready for good, so that load balancers stop
+// sending requests before it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs the checks concurrently and returns the error of every failed
+// one by name, or nil when the service is ready.
+func (c *Checker) Ready(ctx context.Context) map[string]error {
+	if c.draining.Load() {
+		return map[string]error{"shutdown": ErrDraining}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed map[string]error
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := nc.check(ctx); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if failed == nil {
+					failed = make(map[string]error)
+				}
+				failed[nc.name] = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	return failed
+}
@@ -0,0 +1,62 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const HeaderKey = "Idempotency-Key"
+
+// MaxKeyLength bounds the Idempotency-Key header.
+const MaxKeyLength = 255
+
+// MaxBodySize bounds the body of requests sent with an Idempotency-Key, it
+// is buffered to be fingerprinted.
+const MaxBodySize = 1 << 20
+
+// ErrKeyBusy is returned when the record of a key changed while it was being
+// reserved, the request may be retried.
+var ErrKeyBusy = errors.New("idempotency key is busy")
+
+// Response is the first response to a request, replayed to its retries.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Record is what is stored under an idempotency key. Response is nil while
+// the first request is being handled.
+type Record struct {
+	Fingerprint string
+	Response    *Response
+	CreatedAt   time.Time
+}
+
+// Store keeps the idempotency keys. Keys are reserved by the first request
+// and hold its response once it's saved.
+type Store interface {
+	// ReserveIdempotencyKey reserves key for the request with fingerprint
+	// and returns nil, or returns the record of the request that reserved
+	// it before. Records created before expiredBefore and reservations made
+	// before staleBefore don't count, the key is reserved anew. It returns
+	// ErrKeyBusy when the record changed under it.
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, now, expiredBefore, staleBefore time.Time) (*Record, error)
+	// SaveIdempotentResponse stores the response under a reserved key.
+	SaveIdempotentResponse(ctx context.Context, key string, resp Response) error
+	// ReleaseIdempotencyKey drops a reservation without response, so the
+	// request may be retried.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// Fingerprint identifies a request by its method, path and body, so that a
+// key can't be reused for another one.
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
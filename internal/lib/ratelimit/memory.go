@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Memory forgets buckets that refilled.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// full is when the bucket will have refilled completely.
+	full time.Time
+}
+
+// Memory keeps buckets in process memory, each replica enforces the limits
+// on its own.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket)}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	tokens := float64(limit.Requests)
+	if b, ok := m.buckets[key]; ok {
+		tokens = Refill(b.tokens, now.Sub(b.updatedAt), limit)
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	res := NewResult(allowed, tokens, limit)
+	m.buckets[key] = &bucket{tokens: tokens, updatedAt: now, full: now.Add(res.Reset)}
+
+	return res, nil
+}
+
+// sweep drops the buckets that refilled, a missing bucket is a full one.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
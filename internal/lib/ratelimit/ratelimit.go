@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period. Buckets hold up to Requests tokens and
+// refill evenly over the period, so a full bucket absorbs a burst of that
+// size.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads limits written as requests/period, like 100/1m. A period
+// without number, like 10/s, is a single unit.
+func ParseLimit(s string) (Limit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want requests/period", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Rate returns how many tokens the bucket regains per second.
+func (l Limit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the state of a bucket after a request took a token from it, or
+// tried to.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// RetryAfter is how long a rejected request has to wait for a token.
+	RetryAfter time.Duration
+	// Reset is how long the bucket takes to refill completely.
+	Reset time.Duration
+}
+
+// Store keeps the buckets. Replicas sharing a store enforce one combined
+// limit.
+type Store interface {
+	// Take removes a token from the bucket of key, refilled up to now,
+	// unless it is empty.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// StoreFunc adapts a function to the Store interface.
+type StoreFunc func(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+
+func (f StoreFunc) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	return f(ctx, key, limit, now)
+}
+
+// Refill returns the tokens of a bucket that held tokens elapsed ago.
+func Refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Requests), tokens+elapsed.Seconds()*limit.Rate())
+}
+
+// NewResult describes a bucket left with tokens after a request was allowed
+// or not.
+func NewResult(allowed bool, tokens float64, limit Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Requests) - tokens) / limit.Rate()),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate())
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "100/1m", want: Limit{Requests: 100, Period: time.Minute}},
+		{in: "10/s", want: Limit{Requests: 10, Period: time.Second}},
+		{in: " 5/30s ", want: Limit{Requests: 5, Period: 30 * time.Second}},
+		{in: "100", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "-1/1m", wantErr: true},
+		{in: "x/1m", wantErr: true},
+		{in: "10/", wantErr: true},
+		{in: "10/0s", wantErr: true},
+		{in: "10/fortnight", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseLimit(%q) = %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLimit(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLimit(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryTake(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+
+	// A full bucket absorbs a burst of its size.
+	for i := range limit.Requests {
+		res, err := m.Take(ctx, "a", limit, now)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !res.Allowed || res.Remaining != limit.Requests-i-1 {
+			t.Fatalf("request %d: allowed %t, remaining %d", i+1, res.Allowed, res.Remaining)
+		}
+	}
+
+	res, _ := m.Take(ctx, "a", limit, now)
+	if res.Allowed {
+		t.Fatal("request over the burst allowed")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("RetryAfter = %s, want 1s", res.RetryAfter)
+	}
+	if res.Reset != limit.Period {
+		t.Errorf("Reset = %s, want %s", res.Reset, limit.Period)
+	}
+
+	// Other keys have buckets of their own.
+	if res, _ := m.Take(ctx, "b", limit, now); !res.Allowed {
+		t.Error("request of another key rejected")
+	}
+
+	// The bucket refills evenly over the period.
+	if res, _ := m.Take(ctx, "a", limit, now.Add(500*time.Millisecond)); res.Allowed {
+		t.Error("request allowed before a token refilled")
+	}
+	if res, _ := m.Take(ctx, "a", limit, now.Add(time.Second)); !res.Allowed {
+		t.Error("request rejected after a token refilled")
+	}
+
+	// Buckets don't fill past their size.
+	later := now.Add(time.Hour)
+	for i := range limit.Requests {
+		if res, _ := m.Take(ctx, "a", limit, later); !res.Allowed {
+			t.Fatalf("request %d after an idle hour rejected", i+1)
+		}
+	}
+	if res, _ := m.Take(ctx, "a", limit, later); res.Allowed {
+		t.Error("bucket refilled past its size")
+	}
+}
@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default owns the rows written before tenants were introduced.
+const Default = "default"
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type ctxKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to. Background jobs work
+// across tenants and scope their context to the tenant of each row.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Valid reports whether id is a well-formed tenant id: up to 64 lowercase
+// letters, digits, dashes and underscores.
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"subscription/internal/domain"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte(`{"id":"1"}`)
+
+	// HMAC-SHA256 of "1735689600.{"id":"1"}" keyed with whsec_test.
+	const want = "sha256=9a48024deee395fa314d5f5e9962e4172a73fa7e7bff102853578ecd2179d795"
+	if got := Sign("whsec_test", at, body); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+
+	if Sign("whsec_other", at, body) == want {
+		t.Error("signature doesn't depend on the secret")
+	}
+	if Sign("whsec_test", at.Add(time.Second), body) == want {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, time.Minute
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{1000, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt, base, max); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	hook := &domain.Webhook{Secret: "whsec_test"}
+	delivery := &domain.WebhookDelivery{ID: 7, Event: domain.EventSubscriptionCreated, Payload: []byte(`{"id":"1"}`)}
+
+	t.Run("signed delivery", func(t *testing.T) {
+		var got *http.Request
+		var body []byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+		hook.URL = srv.URL
+
+		code, err := NewSender(time.Second).Send(context.Background(), hook, delivery)
+		if err != nil || code != http.StatusNoContent {
+			t.Fatalf("Send() = %d, %v, want %d", code, err, http.StatusNoContent)
+		}
+
+		if got.Header.Get(HeaderEvent) != delivery.Event || got.Header.Get(HeaderDelivery) != "7" {
+			t.Errorf("event headers = %q, %q", got.Header.Get(HeaderEvent), got.Header.Get(HeaderDelivery))
+		}
+		unix, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %s: %v", HeaderTimestamp, err)
+		}
+		if want := Sign(hook.Secret, time.Unix(unix, 0), body); got.Header.Get(HeaderSignature) != want {
+			t.Errorf("%s = %s, want %s", HeaderSignature, got.Header.Get(HeaderSignature), want)
+		}
+	})
+
+	t.Run("rejected delivery", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		hook.URL = srv.URL
+
+		code, err := NewSender(time.Second).Send(context.Background(), hook, delivery)
+		if err == nil || code != http.StatusServiceUnavailable {
+			t.Errorf("Send() = %d, %v, want %d and an error", code, err, http.StatusServiceUnavailable)
+		}
+	})
+
+	t.Run("redirects aren't followed", func(t *testing.T) {
+		followed := false
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			followed = true
+		}))
+		defer target.Close()
+		srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		defer srv.Close()
+		hook.URL = srv.URL
+
+		code, err := NewSender(time.Second).Send(context.Background(), hook, delivery)
+		if err == nil || code != http.StatusTemporaryRedirect {
+			t.Errorf("Send() = %d, %v, want %d and an error", code, err, http.StatusTemporaryRedirect)
+		}
+		if followed {
+			t.Error("the payload was sent to the redirect target")
+		}
+	})
+
+	t.Run("unreachable endpoint", func(t *testing.T) {
+		hook.URL = "http://unreachable.invalid"
+
+		if code, err := NewSender(time.Second).Send(context.Background(), hook, delivery); err == nil || code != 0 {
+			t.Errorf("Send() = %d, %v, want 0 and an error", code, err)
+		}
+	})
+}
@@ -0,0 +1,392 @@
+package cached
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/cache"
+	"subscription/internal/lib/fx"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/metrics"
+	"subscription/internal/storage"
+	"subscription/internal/usecases"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionStorage wraps a subscription storage, caching subscriptions
+// by id, the pages listed and the costs computed.
+//
+// Entries aren't deleted on writes. Their keys hold the generation of the
+// subscription or user they depend on, and writes move it on, so that
+// entries cached before are never read again. A read racing with a write
+// caches under the generation it started with, and can't bring back what
+// the write replaced. Pages and costs not filtered by user depend on the
+// generation of the whole tenant, moved on by every write.
+//
+// Reads made in a transaction bypass the cache, they may see uncommitted
+// changes, and so do reads pinned to the primary, which want what is
+// committed right now. Generations written in a transaction move on once it ends, so
+// entries cached before the commit don't outlive it. Misses are read from
+// the primary, a replica lagging behind would cache what a write replaced.
+type SubscriptionStorage struct {
+	next    usecases.SubscriptionStorage
+	cache   cache.Cache
+	ttl     time.Duration
+	metrics *metrics.Metrics
+	log     *slog.Logger
+}
+
+func NewSubscriptionStorage(
+	next usecases.SubscriptionStorage,
+	c cache.Cache,
+	ttl time.Duration,
+	m *metrics.Metrics,
+	log *slog.Logger,
+) *SubscriptionStorage {
+	return &SubscriptionStorage{
+		next:    next,
+		cache:   c,
+		ttl:     ttl,
+		metrics: m,
+		log:     log.With(slog.String("component", "storage/cached")),
+	}
+}
+
+// txState is carried by the context of a transaction.
+type txState struct {
+	mu     sync.Mutex
+	scopes map[string]struct{}
+	// owners are the users of the subscriptions read in the transaction.
+	owners map[int]uuid.UUID
+}
+
+type txKey struct{}
+
+func txFrom(ctx context.Context) (*txState, bool) {
+	tx, ok := ctx.Value(txKey{}).(*txState)
+	return tx, ok
+}
+
+func (s *SubscriptionStorage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFrom(ctx); ok {
+		return s.next.WithinTx(ctx, fn)
+	}
+
+	tx := &txState{scopes: make(map[string]struct{}), owners: make(map[int]uuid.UUID)}
+	err := s.next.WithinTx(context.WithValue(ctx, txKey{}, tx), fn)
+
+	// Rolled back changes leave the cache right, moving on costs misses.
+	scopes := make([]string, 0, len(tx.scopes))
+	for scope := range tx.scopes {
+		scopes = append(scopes, scope)
+	}
+	s.bump(context.WithoutCancel(ctx), scopes...)
+
+	return err
+}
+
+func (s *SubscriptionStorage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
+	id, err := s.next.AddUserSubscription(ctx, dto)
+	if err != nil {
+		return 0, err
+	}
+
+	if tenantID, err := storage.Tenant(ctx); err == nil {
+		s.invalidate(ctx, userScope(tenantID, dto.UserID), userScope(tenantID, uuid.Nil))
+	}
+
+	return id, nil
+}
+
+func (s *SubscriptionStorage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	if tx, ok := txFrom(ctx); ok {
+		sub, err := s.next.GetUserSubscriptionById(ctx, id)
+		if err == nil {
+			tx.mu.Lock()
+			tx.owners[id] = sub.UserID
+			tx.mu.Unlock()
+		}
+		return sub, err
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return s.next.GetUserSubscriptionById(ctx, id)
+	}
+
+	sub, err := read(ctx, s, "subscription", subScope(tenantID, id), nil,
+		func(ctx context.Context) (*domain.UserSubscription, error) {
+			return s.next.GetUserSubscriptionById(ctx, id)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	sub.TenantID = tenantID
+	return sub, nil
+}
+
+func (s *SubscriptionStorage) ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return s.next.ListUserSubscriptions(ctx, dto)
+	}
+
+	page, err := read(ctx, s, "list", userScope(tenantID, dto.UserID), []any{dto},
+		func(ctx context.Context) (*domain.UserSubscriptionPage, error) {
+			return s.next.ListUserSubscriptions(ctx, dto)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range page.Items {
+		sub.TenantID = tenantID
+	}
+	return page, nil
+}
+
+// ExportUserSubscriptions streams every subscription, it isn't cached.
+func (s *SubscriptionStorage) ExportUserSubscriptions(ctx context.Context, dto dto.ListUserSubs, fn func(sub *domain.UserSubscription) error) error {
+	return s.next.ExportUserSubscriptions(ctx, dto, fn)
+}
+
+func (s *SubscriptionStorage) DeleteUserSubscriptionByID(ctx context.Context, id int) error {
+	tenantID, tenantErr := storage.Tenant(ctx)
+	var owner uuid.UUID
+	if tenantErr == nil {
+		owner = s.owner(ctx, id)
+	}
+
+	if err := s.next.DeleteUserSubscriptionByID(ctx, id); err != nil {
+		return err
+	}
+
+	if tenantErr == nil {
+		s.invalidate(ctx, subScope(tenantID, id), userScope(tenantID, owner), userScope(tenantID, uuid.Nil))
+	}
+
+	return nil
+}
+
+func (s *SubscriptionStorage) RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	sub, err := s.next.RestoreUserSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenantID, err := storage.Tenant(ctx); err == nil {
+		s.invalidate(ctx, subScope(tenantID, id), userScope(tenantID, sub.UserID), userScope(tenantID, uuid.Nil))
+	}
+
+	return sub, nil
+}
+
+// PurgeDeletedSubscriptions removes subscriptions no read returns anymore,
+// nothing cached depends on them.
+func (s *SubscriptionStorage) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error) {
+	return s.next.PurgeDeletedSubscriptions(ctx, retention)
+}
+
+func (s *SubscriptionStorage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
+	tenantID, tenantErr := storage.Tenant(ctx)
+	var owner uuid.UUID
+	if tenantErr == nil {
+		owner = s.owner(ctx, dto.ID)
+	}
+
+	sub, err := s.next.UpdateUserSubscription(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenantErr == nil {
+		// The subscription may have moved to another user.
+		s.invalidate(ctx,
+			subScope(tenantID, dto.ID),
+			userScope(tenantID, owner),
+			userScope(tenantID, sub.UserID),
+			userScope(tenantID, uuid.Nil),
+		)
+	}
+
+	return sub, nil
+}
+
+func (s *SubscriptionStorage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error) {
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return s.next.CalculateTotalCost(ctx, dto, rates)
+	}
+
+	return read(ctx, s, "total_cost", userScope(tenantID, dto.UserID), []any{dto, rates, openPeriodEnd()},
+		func(ctx context.Context) (*domain.TotalCost, error) {
+			return s.next.CalculateTotalCost(ctx, dto, rates)
+		})
+}
+
+func (s *SubscriptionStorage) CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error) {
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return s.next.CostAnalytics(ctx, dto, rates)
+	}
+
+	return read(ctx, s, "analytics", userScope(tenantID, dto.UserID), []any{dto, rates, openPeriodEnd()},
+		func(ctx context.Context) ([]*domain.CostBucket, error) {
+			return s.next.CostAnalytics(ctx, dto, rates)
+		})
+}
+
+// read returns the value of the read of kind with params cached for the
+// current generation of scope, or loads and caches it. Failures of the
+// cache fall back to load.
+func read[T any](ctx context.Context, s *SubscriptionStorage, kind, scope string, params []any, load func(ctx context.Context) (T, error)) (T, error) {
+	if _, ok := txFrom(ctx); ok || storage.OnPrimary(ctx) {
+		return load(ctx)
+	}
+
+	hash, err := fingerprint(params)
+	if err != nil {
+		s.log.Warn("can't hash read parameters", slog.String("kind", kind), sl.Err(err))
+		return load(ctx)
+	}
+	gen, err := s.generation(ctx, scope)
+	if err != nil {
+		s.log.Warn("can't read cache generation", slog.String("scope", scope), sl.Err(err))
+		return load(ctx)
+	}
+	key := kind + ":" + scope + ":" + hash + ":" + gen
+
+	data, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		s.log.Warn("can't read cache", slog.String("key", key), sl.Err(err))
+	}
+	if ok {
+		var v T
+		if err := json.Unmarshal(data, &v); err == nil {
+			s.metrics.ObserveCache(kind, true)
+			return v, nil
+		}
+		s.log.Warn("can't decode cached value", slog.String("key", key), sl.Err(err))
+	}
+	s.metrics.ObserveCache(kind, false)
+
+	v, err := load(storage.WithPrimary(ctx))
+	if err != nil {
+		return v, err
+	}
+
+	data, err = json.Marshal(v)
+	if err == nil {
+		err = s.cache.Set(ctx, key, data, s.ttl)
+	}
+	if err != nil {
+		s.log.Warn("can't cache value", slog.String("key", key), sl.Err(err))
+	}
+
+	return v, nil
+}
+
+// generation returns the current generation of scope, starting one when
+// the cache has none.
+func (s *SubscriptionStorage) generation(ctx context.Context, scope string) (string, error) {
+	gen, ok, err := s.cache.Get(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return string(gen), nil
+	}
+
+	next := newGeneration()
+	if err := s.cache.Set(ctx, scope, []byte(next), s.ttl); err != nil {
+		return "", err
+	}
+	return next, nil
+}
+
+// invalidate moves the generations of scopes on, once the transaction of
+// ctx ends when there is one.
+func (s *SubscriptionStorage) invalidate(ctx context.Context, scopes ...string) {
+	if tx, ok := txFrom(ctx); ok {
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+		for _, scope := range scopes {
+			tx.scopes[scope] = struct{}{}
+		}
+		return
+	}
+
+	s.bump(ctx, scopes...)
+}
+
+func (s *SubscriptionStorage) bump(ctx context.Context, scopes ...string) {
+	for _, scope := range scopes {
+		if err := s.cache.Set(ctx, scope, []byte(newGeneration()), s.ttl); err != nil {
+			// Entries of the old generation live until they expire.
+			s.log.Error("can't invalidate cache", slog.String("scope", scope), sl.Err(err))
+		}
+	}
+}
+
+// owner returns the user of the subscription about to be changed, uuid.Nil
+// when it can't be read. The read of the transaction is reused if any.
+func (s *SubscriptionStorage) owner(ctx context.Context, id int) uuid.UUID {
+	if tx, ok := txFrom(ctx); ok {
+		tx.mu.Lock()
+		owner, ok := tx.owners[id]
+		tx.mu.Unlock()
+		if ok {
+			return owner
+		}
+	}
+
+	sub, err := s.next.GetUserSubscriptionById(storage.WithPrimary(ctx), id)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			// The pages of the user stay cached until they expire.
+			s.log.Warn("can't read subscription owner", slog.Int("id", id), sl.Err(err))
+		}
+		return uuid.Nil
+	}
+	return sub.UserID
+}
+
+func subScope(tenantID string, id int) string {
+	return "gen:sub:" + tenantID + ":" + strconv.Itoa(id)
+}
+
+// userScope is the scope of the reads of user, of the whole tenant for
+// uuid.Nil.
+func userScope(tenantID string, user uuid.UUID) string {
+	return "gen:user:" + tenantID + ":" + user.String()
+}
+
+func newGeneration() string {
+	return strconv.FormatUint(rand.Uint64(), 36)
+}
+
+// fingerprint hashes the parameters of a read.
+func fingerprint(params []any) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// openPeriodEnd is the month open periods end with, costs change with it.
+func openPeriodEnd() string {
+	return time.Now().Format("2006-01")
+}
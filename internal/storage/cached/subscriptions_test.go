@@ -0,0 +1,189 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/cache"
+	"subscription/internal/lib/metrics"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/storage"
+	"subscription/internal/storage/memory"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// newStorage returns the cache in front of a memory storage, and the memory
+// storage, written directly to change data behind the cache.
+func newStorage() (*SubscriptionStorage, *memory.Storage) {
+	next := memory.New()
+	return NewSubscriptionStorage(next, cache.NewLRU(100), time.Hour, metrics.New(), slog.New(slog.DiscardHandler)), next
+}
+
+func update(id int, user uuid.UUID, price int64) dto.UpdateUserSubDTO {
+	return dto.UpdateUserSubDTO{
+		ID:            id,
+		ServiceName:   "Netflix",
+		Price:         decimal.NewFromInt(price),
+		Currency:      "RUB",
+		BillingPeriod: "monthly",
+		UserID:        user,
+		StartDate:     "2025-01-01",
+	}
+}
+
+func add(t *testing.T, ctx context.Context, s interface {
+	AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error)
+}, user uuid.UUID, service string) int {
+	t.Helper()
+
+	id, err := s.AddUserSubscription(ctx, dto.CreateUserSubDTO{
+		ServiceName:   service,
+		Price:         decimal.NewFromInt(100),
+		Currency:      "RUB",
+		BillingPeriod: "monthly",
+		UserID:        user,
+		StartDate:     "2025-01-01",
+	})
+	if err != nil {
+		t.Fatalf("AddUserSubscription() error = %v", err)
+	}
+	return int(id)
+}
+
+func price(t *testing.T, ctx context.Context, s *SubscriptionStorage, id int) int64 {
+	t.Helper()
+
+	sub, err := s.GetUserSubscriptionById(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserSubscriptionById() error = %v", err)
+	}
+	return sub.Price.IntPart()
+}
+
+func count(t *testing.T, ctx context.Context, s *SubscriptionStorage, user uuid.UUID) int {
+	t.Helper()
+
+	page, err := s.ListUserSubscriptions(ctx, dto.ListUserSubs{UserID: user, SortBy: "id", Order: "asc", Limit: 10})
+	if err != nil {
+		t.Fatalf("ListUserSubscriptions() error = %v", err)
+	}
+	return len(page.Items)
+}
+
+func TestSubscriptionInvalidation(t *testing.T) {
+	s, next := newStorage()
+	ctx := tenant.WithTenant(context.Background(), "default")
+	user := uuid.New()
+	id := add(t, ctx, s, user, "Netflix")
+
+	if got := price(t, ctx, s, id); got != 100 {
+		t.Fatalf("price = %d, want 100", got)
+	}
+
+	// Changes made behind the cache aren't seen until it's invalidated.
+	if _, err := next.UpdateUserSubscription(ctx, update(id, user, 200)); err != nil {
+		t.Fatalf("UpdateUserSubscription() error = %v", err)
+	}
+	if got := price(t, ctx, s, id); got != 100 {
+		t.Fatalf("price = %d, want the cached 100", got)
+	}
+
+	if _, err := s.UpdateUserSubscription(ctx, update(id, user, 300)); err != nil {
+		t.Fatalf("UpdateUserSubscription() error = %v", err)
+	}
+	if got := price(t, ctx, s, id); got != 300 {
+		t.Errorf("price after an update = %d, want 300", got)
+	}
+
+	// Another tenant doesn't read the cached subscription.
+	if _, err := s.GetUserSubscriptionById(tenant.WithTenant(ctx, "acme"), id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetUserSubscriptionById() in another tenant error = %v, want %v", err, storage.ErrNotFound)
+	}
+
+	if err := s.DeleteUserSubscriptionByID(ctx, id); err != nil {
+		t.Fatalf("DeleteUserSubscriptionByID() error = %v", err)
+	}
+	if _, err := s.GetUserSubscriptionById(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetUserSubscriptionById() after a delete error = %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestPageInvalidation(t *testing.T) {
+	s, next := newStorage()
+	ctx := tenant.WithTenant(context.Background(), "default")
+	user, other := uuid.New(), uuid.New()
+	id := add(t, ctx, s, user, "Netflix")
+
+	if got := count(t, ctx, s, user); got != 1 {
+		t.Fatalf("user lists %d subscriptions, want 1", got)
+	}
+	if got := count(t, ctx, s, uuid.Nil); got != 1 {
+		t.Fatalf("tenant lists %d subscriptions, want 1", got)
+	}
+
+	add(t, ctx, next, user, "Spotify")
+	if got := count(t, ctx, s, user); got != 1 {
+		t.Fatalf("user lists %d subscriptions, want the cached 1", got)
+	}
+
+	// A write for another user leaves the pages of user cached, not the
+	// pages of the whole tenant.
+	add(t, ctx, s, other, "Spotify")
+	if got := count(t, ctx, s, user); got != 1 {
+		t.Errorf("user lists %d subscriptions, want the cached 1", got)
+	}
+	if got := count(t, ctx, s, uuid.Nil); got != 3 {
+		t.Errorf("tenant lists %d subscriptions, want 3", got)
+	}
+
+	// Moving a subscription to another user invalidates the pages of both.
+	if _, err := s.UpdateUserSubscription(ctx, update(id, other, 100)); err != nil {
+		t.Fatalf("UpdateUserSubscription() error = %v", err)
+	}
+	if got := count(t, ctx, s, user); got != 1 {
+		t.Errorf("user lists %d subscriptions after the move, want 1", got)
+	}
+	if got := count(t, ctx, s, other); got != 2 {
+		t.Errorf("other user lists %d subscriptions after the move, want 2", got)
+	}
+}
+
+func TestReadsBypassingTheCache(t *testing.T) {
+	s, next := newStorage()
+	ctx := tenant.WithTenant(context.Background(), "default")
+	user := uuid.New()
+	id := add(t, ctx, s, user, "Netflix")
+
+	price(t, ctx, s, id)
+	if _, err := next.UpdateUserSubscription(ctx, update(id, user, 200)); err != nil {
+		t.Fatalf("UpdateUserSubscription() error = %v", err)
+	}
+
+	if got := price(t, storage.WithPrimary(ctx), s, id); got != 200 {
+		t.Errorf("price read from the primary = %d, want 200", got)
+	}
+
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		if got := price(t, ctx, s, id); got != 200 {
+			t.Errorf("price read in a transaction = %d, want 200", got)
+		}
+		// Written in the transaction, invalidated once it ends.
+		if _, err := s.UpdateUserSubscription(ctx, update(id, user, 300)); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithinTx() error = %v", err)
+	}
+
+	if got := price(t, ctx, s, id); got != 300 {
+		t.Errorf("price after the transaction = %d, want 300", got)
+	}
+}
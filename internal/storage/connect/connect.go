@@ -0,0 +1,221 @@
+package connect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"subscription/internal/config"
+	"time"
+
+	_ "github.com/lib/pq"  // init postgres driver
+	_ "modernc.org/sqlite" // init sqlite driver
+)
+
+// pingTimeout bounds every connection attempt made at startup.
+const pingTimeout = 5 * time.Second
+
+// Open returns the connection pool of the configured storage driver, once
+// the database answers. It's shared by the service and the migrator.
+func Open(cfg *config.Config) (*sql.DB, error) {
+	const op = "storage.connect.Open"
+
+	var db *sql.DB
+	var err error
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		db, err = openPostgres(cfg.DbConfig)
+	case config.StorageSQLite:
+		db, err = openSQLite(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("%s: storage driver %q has no database", op, cfg.StorageDriver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return db, nil
+}
+
+func openPostgres(cfg config.DbConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", PostgresDSN(cfg))
+	if err != nil {
+		return nil, err
+	}
+	configurePool(db, cfg)
+
+	if err := ping(db, cfg.ConnectAttempts, cfg.ConnectBackoff); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenReplicas returns the pools of the read replicas with the session and
+// pool settings of the primary. They aren't pinged, replicas may come and go.
+func OpenReplicas(cfg config.DbConfig) ([]*sql.DB, error) {
+	const op = "storage.connect.OpenReplicas"
+
+	var dbs []*sql.DB
+	for _, url := range cfg.ReplicaURLs {
+		replica := cfg
+		replica.URL = url
+
+		db, err := sql.Open("postgres", PostgresDSN(replica))
+		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		configurePool(db, cfg)
+		dbs = append(dbs, db)
+	}
+
+	return dbs, nil
+}
+
+func configurePool(db *sql.DB, cfg config.DbConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", SQLiteDSN(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ping(db, 1, 0); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// PostgresDSN returns the URL of cfg, or builds a key=value DSN of the
+// other settings. Either way the DSN carries the session settings of cfg.
+func PostgresDSN(cfg config.DbConfig) string {
+	if cfg.URL != "" {
+		return withSessionParams(cfg.URL, cfg)
+	}
+
+	params := []param{
+		{"host", cfg.Host},
+		{"port", cfg.Port},
+		{"user", cfg.Username},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"sslmode", cfg.SSLMode},
+		{"sslrootcert", cfg.SSLRootCert},
+		{"sslcert", cfg.SSLCert},
+		{"sslkey", cfg.SSLKey},
+	}
+	params = append(params, sessionParams(cfg)...)
+
+	var dsn []string
+	for _, p := range params {
+		if p.value != "" {
+			dsn = append(dsn, p.key+"="+quote(p.value))
+		}
+	}
+	return strings.Join(dsn, " ")
+}
+
+type param struct{ key, value string }
+
+// sessionParams are the settings of cfg sent along with whatever DSN the
+// connection is opened with.
+func sessionParams(cfg config.DbConfig) []param {
+	var params []param
+	if cfg.ApplicationName != "" {
+		params = append(params, param{"application_name", cfg.ApplicationName})
+	}
+	if cfg.StatementTimeout > 0 {
+		// Unknown keys are sent to the server as run-time parameters.
+		params = append(params, param{
+			"statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10),
+		})
+	}
+	return params
+}
+
+// withSessionParams adds the session settings of cfg to dsn, a postgres://
+// URL or a key=value DSN. Settings dsn has already are left as they are.
+func withSessionParams(dsn string, cfg config.DbConfig) string {
+	params := sessionParams(cfg)
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// Opening the connection reports it.
+			return dsn
+		}
+		query := u.Query()
+		for _, p := range params {
+			if !query.Has(p.key) {
+				query.Set(p.key, p.value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	for _, p := range params {
+		if !dsnKey(p.key).MatchString(dsn) {
+			dsn += " " + p.key + "=" + quote(p.value)
+		}
+	}
+	return dsn
+}
+
+func dsnKey(key string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(key) + `\s*=`)
+}
+
+// SQLiteDSN opens the database at path the way the storage expects it.
+//
+// Write transactions take the database lock up front, so the uniqueness and
+// overlap checks can't race with a concurrent writer. Foreign keys are off
+// by default in SQLite, they cascade the deletion of webhooks to their
+// deliveries.
+func SQLiteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate", path)
+}
+
+// quote quotes DSN values holding spaces, quotes or backslashes.
+func quote(v string) string {
+	if !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// ping pings db up to attempts times, doubling backoff between them, so that
+// the service may start before the database is up.
+func ping(db *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("database unreachable after %d attempts: %w", attempt, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
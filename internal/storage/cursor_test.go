@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		value  string
+		valid  bool
+	}{
+		{"id", "20", true},
+		{"id", "00000000000000000020", true},
+		{"id", "20 OR 1=1", false},
+		{"price", "100.00", true},
+		{"price", "10000", true},
+		{"price", "0000000000000000000100.00", true},
+		{"price", "ten", false},
+		{"user_id", "0b4c2a41-5ad4-4b8a-a0f5-0b9ef9d5e1b4", true},
+		{"user_id", "user", false},
+		{"start_date", "2025-01-01", true},
+		{"start_date", "2025-13-01", false},
+		{"end_date", "9999-12-31", true},
+		{"end_date", "tomorrow", false},
+		{"created_at", "2025-01-01 10:00:00", true},
+		{"created_at", "2025-01-01 10:00:00.123456", true},
+		{"updated_at", "2025-01-01T10:00:00.123456789Z", true},
+		{"updated_at", "2025-01-01", false},
+		{"service_name", "Yandex Plus", true},
+		{"service_name", "Yandex\x00Plus", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy+" "+tt.value, func(t *testing.T) {
+			s := EncodeCursor(Cursor{SortBy: tt.sortBy, Order: "asc", Value: tt.value, ID: 1})
+
+			c, err := DecodeCursor(s, tt.sortBy, "asc")
+			if tt.valid {
+				if err != nil || c.Value != tt.value {
+					t.Errorf("DecodeCursor() = %+v, %v, want value %q", c, err, tt.value)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
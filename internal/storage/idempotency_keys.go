@@ -0,0 +1,5 @@
+package storage
+
+// IdempotencyKeySweepOdds makes one in so many reservations drop the
+// expired idempotency keys.
+const IdempotencyKeySweepOdds = 100
@@ -0,0 +1,117 @@
+package instrumented
+
+import (
+	"context"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/fx"
+	"subscription/internal/lib/metrics"
+	"subscription/internal/lib/tracing"
+	"subscription/internal/usecases"
+	"time"
+)
+
+const tracerName = "subscription/internal/storage/instrumented"
+
+// SubscriptionStorage wraps a subscription storage, timing every call,
+// counting the failed ones and tracing them.
+type SubscriptionStorage struct {
+	next    usecases.SubscriptionStorage
+	metrics *metrics.Metrics
+}
+
+func NewSubscriptionStorage(next usecases.SubscriptionStorage, m *metrics.Metrics) *SubscriptionStorage {
+	return &SubscriptionStorage{next: next, metrics: m}
+}
+
+// start starts the span of a call, the returned function ends it and
+// records the outcome.
+func (s *SubscriptionStorage) start(ctx context.Context, method string) (context.Context, func(err error)) {
+	ctx, span := tracing.Start(ctx, tracerName, "SubscriptionStorage."+method)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		s.metrics.ObserveStorage(method, time.Since(start), err)
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}
+}
+
+func (s *SubscriptionStorage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, done := s.start(ctx, "WithinTx")
+	err := s.next.WithinTx(ctx, fn)
+	done(err)
+	return err
+}
+
+func (s *SubscriptionStorage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
+	ctx, done := s.start(ctx, "AddUserSubscription")
+	id, err := s.next.AddUserSubscription(ctx, dto)
+	done(err)
+	return id, err
+}
+
+func (s *SubscriptionStorage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	ctx, done := s.start(ctx, "GetUserSubscriptionById")
+	sub, err := s.next.GetUserSubscriptionById(ctx, id)
+	done(err)
+	return sub, err
+}
+
+func (s *SubscriptionStorage) ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	ctx, done := s.start(ctx, "ListUserSubscriptions")
+	page, err := s.next.ListUserSubscriptions(ctx, dto)
+	done(err)
+	return page, err
+}
+
+func (s *SubscriptionStorage) ExportUserSubscriptions(ctx context.Context, dto dto.ListUserSubs, fn func(sub *domain.UserSubscription) error) error {
+	ctx, done := s.start(ctx, "ExportUserSubscriptions")
+	err := s.next.ExportUserSubscriptions(ctx, dto, fn)
+	done(err)
+	return err
+}
+
+func (s *SubscriptionStorage) DeleteUserSubscriptionByID(ctx context.Context, id int) error {
+	ctx, done := s.start(ctx, "DeleteUserSubscriptionByID")
+	err := s.next.DeleteUserSubscriptionByID(ctx, id)
+	done(err)
+	return err
+}
+
+func (s *SubscriptionStorage) RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	ctx, done := s.start(ctx, "RestoreUserSubscription")
+	sub, err := s.next.RestoreUserSubscription(ctx, id)
+	done(err)
+	return sub, err
+}
+
+func (s *SubscriptionStorage) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error) {
+	ctx, done := s.start(ctx, "PurgeDeletedSubscriptions")
+	subs, err := s.next.PurgeDeletedSubscriptions(ctx, retention)
+	done(err)
+	return subs, err
+}
+
+func (s *SubscriptionStorage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
+	ctx, done := s.start(ctx, "UpdateUserSubscription")
+	sub, err := s.next.UpdateUserSubscription(ctx, dto)
+	done(err)
+	return sub, err
+}
+
+func (s *SubscriptionStorage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error) {
+	ctx, done := s.start(ctx, "CalculateTotalCost")
+	total, err := s.next.CalculateTotalCost(ctx, dto, rates)
+	done(err)
+	return total, err
+}
+
+func (s *SubscriptionStorage) CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error) {
+	ctx, done := s.start(ctx, "CostAnalytics")
+	buckets, err := s.next.CostAnalytics(ctx, dto, rates)
+	done(err)
+	return buckets, err
+}
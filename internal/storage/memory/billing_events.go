@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+)
+
+// TryLockRenewals always succeeds: the storage serves a single process, and
+// billing events are unique per period, so concurrent runs can't bill a
+// period twice.
+func (s *Storage) TryLockRenewals(ctx context.Context) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+// DueRenewals looks at the subscriptions of every tenant.
+func (s *Storage) DueRenewals(ctx context.Context, today time.Time) ([]*domain.DueRenewal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	billed := make(map[int64]time.Time)
+	for _, ev := range s.events {
+		end, err := time.Parse(dateLayout, ev.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		if end.After(billed[ev.SubscriptionID]) {
+			billed[ev.SubscriptionID] = end
+		}
+	}
+
+	recs := slices.SortedFunc(maps.Values(s.subs), func(a, b *record) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	var due []*domain.DueRenewal
+	for _, rec := range recs {
+		if rec.deleted() || rec.startDate.After(today) {
+			continue
+		}
+
+		renewal := &domain.DueRenewal{Subscription: rec.toDomain()}
+		if until, ok := billed[rec.id]; ok {
+			if !until.Before(today) || !until.Before(rec.end()) {
+				continue
+			}
+			renewal.BilledUntil = &until
+		}
+		due = append(due, renewal)
+	}
+
+	return due, nil
+}
+
+// AddBillingEvent stores the event unless its period is billed already, in
+// which case it reports false.
+func (s *Storage) AddBillingEvent(ctx context.Context, ev *domain.BillingEvent) (bool, error) {
+	const op = "storage.memory.AddBillingEvent"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	for _, stored := range s.events {
+		if stored.SubscriptionID == ev.SubscriptionID && stored.PeriodStart == ev.PeriodStart {
+			return false, nil
+		}
+	}
+
+	s.lastEventID++
+	ev.ID = s.lastEventID
+	ev.CreatedAt = now()
+
+	stored := *ev
+	stored.TenantID = tenantID
+	s.events = append(s.events, &stored)
+
+	return true, nil
+}
+
+func (s *Storage) GetBillingEvents(ctx context.Context, subscriptionID int) ([]*domain.BillingEvent, error) {
+	const op = "storage.memory.GetBillingEvents"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []*domain.BillingEvent
+	for _, ev := range s.events {
+		if ev.TenantID == tenantID && ev.SubscriptionID == int64(subscriptionID) {
+			copied := *ev
+			events = append(events, &copied)
+		}
+	}
+
+	slices.SortFunc(events, func(a, b *domain.BillingEvent) int {
+		return strings.Compare(a.PeriodStart, b.PeriodStart)
+	})
+
+	return events, nil
+}
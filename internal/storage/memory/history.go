@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+)
+
+func (s *Storage) AddHistoryRecord(ctx context.Context, rec *domain.HistoryRecord) error {
+	const op = "storage.memory.AddHistoryRecord"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	s.lastHistoryID++
+	rec.ID = s.lastHistoryID
+	rec.CreatedAt = now()
+
+	stored := *rec
+	stored.TenantID = tenantID
+	s.history = append(s.history, &stored)
+
+	return nil
+}
+
+func (s *Storage) GetHistory(ctx context.Context, subscriptionID int) ([]*domain.HistoryRecord, error) {
+	const op = "storage.memory.GetHistory"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []*domain.HistoryRecord
+	for _, rec := range s.history {
+		if rec.TenantID == tenantID && rec.SubscriptionID == int64(subscriptionID) {
+			copied := *rec
+			records = append(records, &copied)
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"subscription/internal/lib/idempotency"
+	"time"
+)
+
+// ReserveIdempotencyKey implements idempotency.Store, dropping the expired
+// keys as it goes.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, now, expiredBefore, staleBefore time.Time) (*idempotency.Record, error) {
+	defer s.lock(ctx)()
+
+	for k, rec := range s.idempotencyKeys {
+		if rec.CreatedAt.Before(expiredBefore) {
+			delete(s.idempotencyKeys, k)
+		}
+	}
+
+	if rec, ok := s.idempotencyKeys[key]; ok && (rec.Response != nil || !rec.CreatedAt.Before(staleBefore)) {
+		copied := *rec
+		return &copied, nil
+	}
+
+	s.idempotencyKeys[key] = &idempotency.Record{Fingerprint: fingerprint, CreatedAt: now}
+
+	return nil, nil
+}
+
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, key string, resp idempotency.Response) error {
+	defer s.lock(ctx)()
+
+	if rec, ok := s.idempotencyKeys[key]; ok && rec.Response == nil {
+		resp.Body = slices.Clone(resp.Body)
+		rec.Response = &resp
+	}
+
+	return nil
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	defer s.lock(ctx)()
+
+	if rec, ok := s.idempotencyKeys[key]; ok && rec.Response == nil {
+		delete(s.idempotencyKeys, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,707 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/fx"
+	"subscription/internal/lib/idempotency"
+	"subscription/internal/storage"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const dateLayout = "2006-01-02"
+
+// openEnd mirrors the upper bound the no_overlap constraint uses for
+// subscriptions without end date.
+var openEnd = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+type record struct {
+	id            int64
+	tenantID      string
+	serviceName   string
+	price         decimal.Decimal
+	currency      string
+	billingPeriod string
+	userID        uuid.UUID
+	startDate     time.Time
+	endDate       *time.Time
+	createdAt     time.Time
+	updatedAt     time.Time
+	deletedAt     *time.Time
+}
+
+// Storage keeps user subscriptions in process memory. It enforces the same
+// unique_subscription, no_overlap and valid_period rules as the Postgres schema.
+//
+// Writers and transactions are serialized by txMu, mu guards the data itself.
+// Reads don't wait for transactions and may observe their uncommitted changes.
+type Storage struct {
+	txMu sync.Mutex
+	mu   sync.RWMutex
+
+	lastID int64
+	subs   map[int64]*record
+
+	lastHistoryID int64
+	history       []*domain.HistoryRecord
+
+	lastEventID int64
+	events      []*domain.BillingEvent
+
+	lastWebhookID int64
+	webhooks      map[int64]*domain.Webhook
+
+	lastDeliveryID int64
+	deliveries     map[int64]*domain.WebhookDelivery
+
+	lastAttemptID int64
+	attempts      []*domain.WebhookAttempt
+
+	expiryNotices map[expiryNotice]struct{}
+
+	lastOutboxID int64
+	outbox       []*domain.OutboxEvent
+	// outboxClaims holds the claimed_until of the claimed outbox events.
+	outboxClaims map[int64]time.Time
+
+	idempotencyKeys map[string]*idempotency.Record
+}
+
+func New() *Storage {
+	return &Storage{
+		subs:          make(map[int64]*record),
+		webhooks:      make(map[int64]*domain.Webhook),
+		deliveries:    make(map[int64]*domain.WebhookDelivery),
+		expiryNotices: make(map[expiryNotice]struct{}),
+		outboxClaims:  make(map[int64]time.Time),
+
+		idempotencyKeys: make(map[string]*idempotency.Record),
+	}
+}
+
+// Close does nothing, the data is gone with the process.
+func (s *Storage) Close() error {
+	return nil
+}
+
+type txKey struct{}
+
+type snapshot struct {
+	subs          map[int64]*record
+	history       []*domain.HistoryRecord
+	events        []*domain.BillingEvent
+	webhooks      map[int64]*domain.Webhook
+	deliveries    map[int64]*domain.WebhookDelivery
+	attempts      []*domain.WebhookAttempt
+	expiryNotices map[expiryNotice]struct{}
+	outbox        []*domain.OutboxEvent
+}
+
+// WithinTx runs fn with exclusive write access and rolls back every change
+// it made when it fails.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if inTx(ctx) {
+		return fn(ctx)
+	}
+
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	snap := snapshot{
+		subs:          maps.Clone(s.subs),
+		history:       s.history,
+		events:        s.events,
+		webhooks:      maps.Clone(s.webhooks),
+		deliveries:    maps.Clone(s.deliveries),
+		attempts:      s.attempts,
+		expiryNotices: maps.Clone(s.expiryNotices),
+		outbox:        s.outbox,
+	}
+	s.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, txKey{}, struct{}{})); err != nil {
+		s.mu.Lock()
+		s.subs = snap.subs
+		s.history = snap.history
+		s.events = snap.events
+		s.webhooks = snap.webhooks
+		s.deliveries = snap.deliveries
+		s.attempts = snap.attempts
+		s.expiryNotices = snap.expiryNotices
+		s.outbox = snap.outbox
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{}) != nil
+}
+
+// lock takes the write locks unless the caller already runs in a transaction
+// holding txMu and returns the matching unlock.
+func (s *Storage) lock(ctx context.Context) func() {
+	tx := inTx(ctx)
+	if !tx {
+		s.txMu.Lock()
+	}
+	s.mu.Lock()
+
+	return func() {
+		s.mu.Unlock()
+		if !tx {
+			s.txMu.Unlock()
+		}
+	}
+}
+
+func (s *Storage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
+	const op = "storage.memory.AddUserSubscription"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	rec := &record{
+		tenantID:      tenantID,
+		serviceName:   dto.ServiceName,
+		price:         dto.Price,
+		currency:      dto.Currency,
+		billingPeriod: dto.BillingPeriod,
+		userID:        dto.UserID,
+		startDate:     startDate,
+		endDate:       endDate,
+	}
+
+	if err := s.checkConstraints(rec); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.lastID++
+	rec.id = s.lastID
+	rec.createdAt = now()
+	rec.updatedAt = rec.createdAt
+	s.subs[rec.id] = rec
+
+	return rec.id, nil
+}
+
+func (s *Storage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "storage.memory.GetUserSubscriptionById"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.subs[int64(id)]
+	if !ok || rec.tenantID != tenantID || rec.deleted() {
+		return nil, storage.ErrNotFound
+	}
+
+	return rec.toDomain(), nil
+}
+
+func (s *Storage) ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	const op = "storage.memory.ListUserSubscriptions"
+
+	sortKey, ok := sortKeys[dto.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	match, err := listFilter(tenantID, dto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var after func(*record) bool
+	if dto.Cursor != "" {
+		cursor, err := storage.DecodeCursor(dto.Cursor, dto.SortBy, dto.Order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		after = func(rec *record) bool {
+			c := cmp.Compare(sortKey(rec), cursor.Value)
+			if c == 0 {
+				c = cmp.Compare(rec.id, cursor.ID)
+			}
+			if dto.Order == "desc" {
+				return c < 0
+			}
+			return c > 0
+		}
+	}
+
+	recs := s.selectRecords(func(rec *record) bool {
+		return match(rec) && (after == nil || after(rec))
+	}, sortKey, dto.Order)
+
+	page := &domain.UserSubscriptionPage{Items: make([]*domain.UserSubscription, 0, dto.Limit)}
+
+	for i, rec := range recs {
+		if i == dto.Limit {
+			last := recs[i-1]
+			page.NextCursor = storage.EncodeCursor(storage.Cursor{
+				SortBy: dto.SortBy,
+				Order:  dto.Order,
+				Value:  sortKey(last),
+				ID:     last.id,
+			})
+			break
+		}
+		page.Items = append(page.Items, rec.toDomain())
+	}
+
+	return page, nil
+}
+
+// ExportUserSubscriptions calls fn for every subscription matching the
+// listing filters in sort order.
+func (s *Storage) ExportUserSubscriptions(
+	ctx context.Context,
+	dto dto.ListUserSubs,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const op = "storage.memory.ExportUserSubscriptions"
+
+	sortKey, ok := sortKeys[dto.SortBy]
+	if !ok {
+		return fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	match, err := listFilter(tenantID, dto)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, rec := range s.selectRecords(match, sortKey, dto.Order) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(rec.toDomain()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectRecords returns the live records matching match ordered by sortKey
+// and id. Records are never modified in place, so they can be read after
+// the lock is released.
+func (s *Storage) selectRecords(match func(*record) bool, sortKey func(*record) string, order string) []*record {
+	s.mu.RLock()
+	var recs []*record
+	for _, rec := range s.subs {
+		if !rec.deleted() && match(rec) {
+			recs = append(recs, rec)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(recs, func(a, b *record) int {
+		c := cmp.Compare(sortKey(a), sortKey(b))
+		if c == 0 {
+			c = cmp.Compare(a.id, b.id)
+		}
+		if order == "desc" {
+			return -c
+		}
+		return c
+	})
+
+	return recs
+}
+
+func (s *Storage) DeleteUserSubscriptionByID(ctx context.Context, id int) error {
+	const op = "storage.memory.DeleteUserSubscriptionByID"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	current, ok := s.subs[int64(id)]
+	if !ok || current.tenantID != tenantID || current.deleted() {
+		return storage.ErrNotFound
+	}
+
+	rec := *current
+	rec.updatedAt = now()
+	rec.deletedAt = &rec.updatedAt
+	s.subs[rec.id] = &rec
+
+	return nil
+}
+
+func (s *Storage) RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "storage.memory.RestoreUserSubscription"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	current, ok := s.subs[int64(id)]
+	if !ok || current.tenantID != tenantID || !current.deleted() {
+		return nil, storage.ErrNotFound
+	}
+
+	rec := *current
+	rec.deletedAt = nil
+	rec.updatedAt = now()
+
+	if err := s.checkConstraints(&rec); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.subs[rec.id] = &rec
+
+	return rec.toDomain(), nil
+}
+
+// PurgeDeletedSubscriptions removes the subscriptions of every tenant
+// soft-deleted more than retention ago and returns them.
+func (s *Storage) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error) {
+	defer s.lock(ctx)()
+
+	deletedBefore := now().Add(-retention)
+
+	var purged []*record
+	for id, rec := range s.subs {
+		if rec.deleted() && rec.deletedAt.Before(deletedBefore) {
+			delete(s.subs, id)
+			purged = append(purged, rec)
+		}
+	}
+	slices.SortFunc(purged, func(a, b *record) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	subs := make([]*domain.UserSubscription, 0, len(purged))
+	for _, rec := range purged {
+		subs = append(subs, rec.toDomain())
+	}
+
+	return subs, nil
+}
+
+func (s *Storage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
+	const op = "storage.memory.UpdateUserSubscription"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	current, ok := s.subs[int64(dto.ID)]
+	if !ok || current.tenantID != tenantID || current.deleted() {
+		return nil, storage.ErrNotFound
+	}
+
+	if dto.Version != nil && !current.updatedAt.Equal(*dto.Version) {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrVersionMismatch)
+	}
+
+	rec := *current
+	rec.serviceName = dto.ServiceName
+	rec.price = dto.Price
+	rec.currency = dto.Currency
+	rec.billingPeriod = dto.BillingPeriod
+	rec.userID = dto.UserID
+	rec.startDate = startDate
+	rec.endDate = endDate
+	rec.updatedAt = now()
+
+	if err := s.checkConstraints(&rec); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.subs[rec.id] = &rec
+
+	return rec.toDomain(), nil
+}
+
+func (s *Storage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error) {
+	const op = "storage.memory.CalculateTotalCost"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	window, err := parseWindow(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	totalCost, err := billing.TotalCost(s.activeSubscriptions(tenantID, dto.UserID, dto.ServiceName, window), window, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totalCost, nil
+}
+
+func (s *Storage) CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error) {
+	const op = "storage.memory.CostAnalytics"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	window, err := parseWindow(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	buckets, err := billing.Analytics(s.activeSubscriptions(tenantID, dto.UserID, dto.ServiceName, window), window, dto.GroupBy, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buckets, nil
+}
+
+// activeSubscriptions returns subscriptions active at least one day of the
+// window ordered by start date. A nil user or an empty service name match all
+// subscriptions of the tenant.
+func (s *Storage) activeSubscriptions(tenantID string, userID uuid.UUID, serviceName string, window billing.Period) []*domain.UserSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var recs []*record
+	for _, rec := range s.subs {
+		if rec.tenantID != tenantID || rec.deleted() {
+			continue
+		}
+		if userID != uuid.Nil && rec.userID != userID {
+			continue
+		}
+		if serviceName != "" && rec.serviceName != serviceName {
+			continue
+		}
+		if rec.startDate.After(window.To) || rec.end().Before(window.From) {
+			continue
+		}
+		recs = append(recs, rec)
+	}
+
+	slices.SortFunc(recs, func(a, b *record) int {
+		if c := a.startDate.Compare(b.startDate); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+
+	subs := make([]*domain.UserSubscription, 0, len(recs))
+	for _, rec := range recs {
+		subs = append(subs, rec.toDomain())
+	}
+
+	return subs
+}
+
+// checkConstraints compares rec with the subscriptions of its tenant. It must
+// be called with the write lock held.
+func (s *Storage) checkConstraints(rec *record) error {
+	if rec.endDate != nil && rec.endDate.Before(rec.startDate) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+
+	for _, other := range s.subs {
+		if other.id == rec.id || other.tenantID != rec.tenantID || other.deleted() || other.userID != rec.userID || other.serviceName != rec.serviceName {
+			continue
+		}
+		if other.startDate.Equal(rec.startDate) &&
+			other.endDate != nil && rec.endDate != nil && other.endDate.Equal(*rec.endDate) {
+			return storage.ErrUserSubExists
+		}
+	}
+
+	for _, other := range s.subs {
+		if other.id == rec.id || other.tenantID != rec.tenantID || other.deleted() || other.userID != rec.userID || other.serviceName != rec.serviceName {
+			continue
+		}
+		if !other.startDate.After(rec.end()) && !rec.startDate.After(other.end()) {
+			return storage.ErrOverlap
+		}
+	}
+
+	return nil
+}
+
+func (r *record) deleted() bool {
+	return r.deletedAt != nil
+}
+
+func (r *record) end() time.Time {
+	if r.endDate == nil {
+		return openEnd
+	}
+	return *r.endDate
+}
+
+func (r *record) toDomain() *domain.UserSubscription {
+	sub := &domain.UserSubscription{
+		ID:            strconv.FormatInt(r.id, 10),
+		ServiceName:   r.serviceName,
+		Price:         r.price,
+		Currency:      r.currency,
+		BillingPeriod: r.billingPeriod,
+		UserID:        r.userID,
+		StartDate:     r.startDate.Format(dateLayout),
+		UpdatedAt:     r.updatedAt,
+		TenantID:      r.tenantID,
+	}
+	if r.endDate != nil {
+		sub.EndDate = r.endDate.Format(dateLayout)
+	}
+	return sub
+}
+
+// sortKeys render a sortable string for every column the listing can be
+// ordered by. Numbers are zero padded so they compare like strings.
+var sortKeys = map[string]func(*record) string{
+	"id":           func(r *record) string { return fmt.Sprintf("%020d", r.id) },
+	"service_name": func(r *record) string { return r.serviceName },
+	"price":        func(r *record) string { return fmt.Sprintf("%025s", r.price.StringFixed(domain.PricePlaces)) },
+	"user_id":      func(r *record) string { return r.userID.String() },
+	"start_date":   func(r *record) string { return r.startDate.Format(dateLayout) },
+	"end_date":     func(r *record) string { return r.end().Format(dateLayout) },
+	"created_at":   func(r *record) string { return r.createdAt.Format(time.RFC3339Nano) },
+	"updated_at":   func(r *record) string { return r.updatedAt.Format(time.RFC3339Nano) },
+}
+
+func listFilter(tenantID string, dto dto.ListUserSubs) (func(*record) bool, error) {
+	conds := []func(*record) bool{
+		func(r *record) bool { return r.tenantID == tenantID },
+	}
+
+	if dto.UserID != uuid.Nil {
+		conds = append(conds, func(r *record) bool { return r.userID == dto.UserID })
+	}
+	if dto.ServiceName != "" {
+		conds = append(conds, func(r *record) bool { return r.serviceName == dto.ServiceName })
+	}
+	if dto.ServiceNamePrefix != "" {
+		conds = append(conds, func(r *record) bool { return strings.HasPrefix(r.serviceName, dto.ServiceNamePrefix) })
+	}
+	if dto.MinPrice != nil {
+		conds = append(conds, func(r *record) bool { return r.price.GreaterThanOrEqual(*dto.MinPrice) })
+	}
+	if dto.MaxPrice != nil {
+		conds = append(conds, func(r *record) bool { return r.price.LessThanOrEqual(*dto.MaxPrice) })
+	}
+
+	for _, f := range []struct {
+		value string
+		cond  func(r *record, d billing.Period) bool
+	}{
+		{dto.ActiveOn, func(r *record, d billing.Period) bool { return !r.startDate.After(d.To) && !r.end().Before(d.From) }},
+		{dto.StartFrom, func(r *record, d billing.Period) bool { return !r.startDate.Before(d.From) }},
+		{dto.StartTo, func(r *record, d billing.Period) bool { return !r.startDate.After(d.To) }},
+		{dto.EndFrom, func(r *record, d billing.Period) bool { return r.endDate != nil && !r.endDate.Before(d.From) }},
+		{dto.EndTo, func(r *record, d billing.Period) bool { return r.endDate != nil && !r.endDate.After(d.To) }},
+	} {
+		if f.value == "" {
+			continue
+		}
+		date, err := billing.ParseDate(f.value)
+		if err != nil {
+			return nil, err
+		}
+		cond := f.cond
+		conds = append(conds, func(r *record) bool { return cond(r, date) })
+	}
+
+	return func(r *record) bool {
+		for _, cond := range conds {
+			if !cond(r) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func parseWindow(startDateStr, endDateStr string) (billing.Period, error) {
+	startDate, endDate, err := parseDates(startDateStr, endDateStr)
+	if err != nil {
+		return billing.Period{}, err
+	}
+	return billing.NewPeriod(startDate, endDate, time.Now()), nil
+}
+
+// parseDates returns the first and the last day of a subscription. A month
+// starts on its first day and ends on its last.
+func parseDates(startDateStr, endDateStr string) (time.Time, *time.Time, error) {
+	startDate, err := billing.ParseDate(startDateStr)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if endDateStr == "" {
+		return startDate.From, nil, nil
+	}
+
+	endDate, err := billing.ParseDate(endDateStr)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return startDate.From, &endDate.To, nil
+}
+
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+)
+
+func (s *Storage) AddOutboxEvent(ctx context.Context, ev *domain.OutboxEvent) error {
+	const op = "storage.memory.AddOutboxEvent"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	s.lastOutboxID++
+	ev.ID = s.lastOutboxID
+	ev.CreatedAt = now()
+	ev.TenantID = tenantID
+
+	stored := *ev
+	s.outbox = append(s.outbox, &stored)
+
+	return nil
+}
+
+// TryLockOutbox always succeeds: relays run in a transaction, which already
+// excludes every other writer.
+func (s *Storage) TryLockOutbox(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// ClaimOutboxEvents claims up to limit of the oldest events of every tenant
+// until now plus lease. It claims nothing while an earlier claim is in
+// force, so batches are published one after another.
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxEvent, error) {
+	defer s.lock(ctx)()
+
+	for _, until := range s.outboxClaims {
+		if until.After(now) {
+			return nil, nil
+		}
+	}
+
+	claims := make(map[int64]time.Time)
+	var events []*domain.OutboxEvent
+	for _, ev := range s.outbox[:min(limit, len(s.outbox))] {
+		claims[ev.ID] = now.Add(lease)
+		copied := *ev
+		events = append(events, &copied)
+	}
+	s.outboxClaims = claims
+
+	return events, nil
+}
+
+// ReleaseOutboxEvents drops the claim on events that couldn't be published.
+func (s *Storage) ReleaseOutboxEvents(ctx context.Context, ids []int64) error {
+	defer s.lock(ctx)()
+
+	for _, id := range ids {
+		delete(s.outboxClaims, id)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	defer s.lock(ctx)()
+
+	// Filtered into a new slice, the old one may back a WithinTx snapshot.
+	var outbox []*domain.OutboxEvent
+	for _, ev := range s.outbox {
+		if !slices.Contains(ids, ev.ID) {
+			outbox = append(outbox, ev)
+		}
+	}
+	s.outbox = outbox
+
+	for _, id := range ids {
+		delete(s.outboxClaims, id)
+	}
+
+	return nil
+}
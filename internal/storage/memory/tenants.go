@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"subscription/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TenantReports sums up the data of every tenant, or of tenantID only when
+// it isn't empty.
+func (s *Storage) TenantReports(ctx context.Context, tenantID string, today time.Time) ([]*domain.TenantReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byTenant := make(map[string]*domain.TenantReport)
+	users := make(map[string]map[uuid.UUID]struct{})
+
+	report := func(id string) *domain.TenantReport {
+		r, ok := byTenant[id]
+		if !ok {
+			r = &domain.TenantReport{TenantID: id}
+			byTenant[id] = r
+			users[id] = make(map[uuid.UUID]struct{})
+		}
+		return r
+	}
+
+	for _, rec := range s.subs {
+		if tenantID != "" && rec.tenantID != tenantID {
+			continue
+		}
+		r := report(rec.tenantID)
+		if rec.deleted() {
+			r.DeletedSubscriptions++
+			continue
+		}
+		r.Subscriptions++
+		if !rec.startDate.After(today) && !rec.end().Before(today) {
+			r.ActiveSubscriptions++
+		}
+		users[rec.tenantID][rec.userID] = struct{}{}
+	}
+
+	for _, hook := range s.webhooks {
+		if tenantID != "" && hook.TenantID != tenantID {
+			continue
+		}
+		report(hook.TenantID).Webhooks++
+	}
+
+	reports := make([]*domain.TenantReport, 0, len(byTenant))
+	for id, r := range byTenant {
+		r.Users = int64(len(users[id]))
+		reports = append(reports, r)
+	}
+	slices.SortFunc(reports, func(a, b *domain.TenantReport) int {
+		return cmp.Compare(a.TenantID, b.TenantID)
+	})
+
+	return reports, nil
+}
@@ -0,0 +1,338 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/storage"
+	"time"
+)
+
+type expiryNotice struct {
+	subscriptionID int64
+	event          string
+	endDate        string
+}
+
+func (s *Storage) AddWebhook(ctx context.Context, hook *domain.Webhook) error {
+	const op = "storage.memory.AddWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	s.lastWebhookID++
+	hook.ID = s.lastWebhookID
+	hook.CreatedAt = now()
+	hook.TenantID = tenantID
+
+	s.webhooks[hook.ID] = copyWebhook(hook)
+
+	return nil
+}
+
+func (s *Storage) ListWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	const op = "storage.memory.ListWebhooks"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hooks := []*domain.Webhook{}
+	for _, hook := range s.webhooks {
+		if hook.TenantID == tenantID {
+			hooks = append(hooks, copyWebhook(hook))
+		}
+	}
+	slices.SortFunc(hooks, func(a, b *domain.Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return hooks, nil
+}
+
+func (s *Storage) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	const op = "storage.memory.GetWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hook, ok := s.webhooks[int64(id)]
+	if !ok || hook.TenantID != tenantID {
+		return nil, storage.ErrWebhookNotFound
+	}
+
+	return copyWebhook(hook), nil
+}
+
+// DeleteWebhook removes the webhook together with its deliveries.
+func (s *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	const op = "storage.memory.DeleteWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	if hook, ok := s.webhooks[int64(id)]; !ok || hook.TenantID != tenantID {
+		return storage.ErrWebhookNotFound
+	}
+
+	delete(s.webhooks, int64(id))
+	maps.DeleteFunc(s.deliveries, func(_ int64, d *domain.WebhookDelivery) bool {
+		return d.WebhookID == int64(id)
+	})
+
+	// Filtered into a new slice, the old one may back a WithinTx snapshot.
+	var attempts []*domain.WebhookAttempt
+	for _, a := range s.attempts {
+		if _, ok := s.deliveries[a.DeliveryID]; ok {
+			attempts = append(attempts, a)
+		}
+	}
+	s.attempts = attempts
+
+	return nil
+}
+
+func (s *Storage) WebhooksFor(ctx context.Context, event string) ([]*domain.Webhook, error) {
+	const op = "storage.memory.WebhooksFor"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hooks []*domain.Webhook
+	for _, hook := range s.webhooks {
+		if hook.TenantID == tenantID && slices.Contains(hook.Events, event) {
+			hooks = append(hooks, copyWebhook(hook))
+		}
+	}
+	slices.SortFunc(hooks, func(a, b *domain.Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return hooks, nil
+}
+
+func (s *Storage) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	const op = "storage.memory.AddWebhookDelivery"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	if hook, ok := s.webhooks[delivery.WebhookID]; !ok || hook.TenantID != tenantID {
+		return storage.ErrWebhookNotFound
+	}
+
+	s.lastDeliveryID++
+	delivery.ID = s.lastDeliveryID
+	delivery.Status = domain.DeliveryPending
+	delivery.CreatedAt = now()
+	delivery.NextAttemptAt = delivery.CreatedAt
+	delivery.TenantID = tenantID
+
+	s.deliveries[delivery.ID] = copyDelivery(delivery)
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns the oldest pending deliveries of every
+// tenant that are due and postpones them by lease.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	defer s.lock(ctx)()
+
+	var due []*domain.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == domain.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	slices.SortFunc(due, func(a, b *domain.WebhookDelivery) int {
+		return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*domain.WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d = copyDelivery(d)
+		d.NextAttemptAt = now.Add(lease)
+		s.deliveries[d.ID] = d
+		claimed = append(claimed, copyDelivery(d))
+	}
+
+	return claimed, nil
+}
+
+func (s *Storage) RecordWebhookAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	defer s.lock(ctx)()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return storage.ErrDeliveryNotFound
+	}
+
+	s.deliveries[delivery.ID] = copyDelivery(delivery)
+
+	s.lastAttemptID++
+	attempt.ID = s.lastAttemptID
+
+	stored := *attempt
+	s.attempts = append(s.attempts, &stored)
+
+	return nil
+}
+
+func (s *Storage) ListWebhookDeliveries(ctx context.Context, webhookID int, filter dto.ListWebhookDeliveries) ([]*domain.WebhookDelivery, error) {
+	const op = "storage.memory.ListWebhookDeliveries"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []*domain.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.TenantID != tenantID || d.WebhookID != int64(webhookID) || (filter.Status != "" && d.Status != filter.Status) {
+			continue
+		}
+		deliveries = append(deliveries, copyDelivery(d))
+	}
+	slices.SortFunc(deliveries, func(a, b *domain.WebhookDelivery) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) GetWebhookDelivery(ctx context.Context, id int) (*domain.WebhookDelivery, error) {
+	const op = "storage.memory.GetWebhookDelivery"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.deliveries[int64(id)]
+	if !ok || d.TenantID != tenantID {
+		return nil, storage.ErrDeliveryNotFound
+	}
+
+	delivery := copyDelivery(d)
+	delivery.Log = []*domain.WebhookAttempt{}
+	for _, a := range s.attempts {
+		if a.DeliveryID == delivery.ID {
+			copied := *a
+			delivery.Log = append(delivery.Log, &copied)
+		}
+	}
+
+	return delivery, nil
+}
+
+func (s *Storage) RedeliverWebhookDelivery(ctx context.Context, id int, now time.Time) (*domain.WebhookDelivery, error) {
+	const op = "storage.memory.RedeliverWebhookDelivery"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer s.lock(ctx)()
+
+	d, ok := s.deliveries[int64(id)]
+	if !ok || d.TenantID != tenantID {
+		return nil, storage.ErrDeliveryNotFound
+	}
+
+	d = copyDelivery(d)
+	d.Status = domain.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	s.deliveries[d.ID] = d
+
+	return copyDelivery(d), nil
+}
+
+// ExpiringSubscriptions looks at the subscriptions of every tenant.
+func (s *Storage) ExpiringSubscriptions(ctx context.Context, from, to time.Time) ([]*domain.UserSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	recs := slices.SortedFunc(maps.Values(s.subs), func(a, b *record) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	var subs []*domain.UserSubscription
+	for _, rec := range recs {
+		if rec.deleted() || rec.endDate == nil || rec.endDate.Before(from) || rec.endDate.After(to) {
+			continue
+		}
+		subs = append(subs, rec.toDomain())
+	}
+
+	return subs, nil
+}
+
+// AddExpiryNotice records that event was sent for the subscription ending on
+// endDate. It reports false when it was sent already.
+func (s *Storage) AddExpiryNotice(ctx context.Context, subscriptionID int64, event, endDate string) (bool, error) {
+	defer s.lock(ctx)()
+
+	key := expiryNotice{subscriptionID: subscriptionID, event: event, endDate: endDate}
+	if _, ok := s.expiryNotices[key]; ok {
+		return false, nil
+	}
+
+	s.expiryNotices[key] = struct{}{}
+
+	return true, nil
+}
+
+func copyWebhook(hook *domain.Webhook) *domain.Webhook {
+	copied := *hook
+	copied.Events = slices.Clone(hook.Events)
+	return &copied
+}
+
+func copyDelivery(d *domain.WebhookDelivery) *domain.WebhookDelivery {
+	copied := *d
+	copied.Log = nil
+	return &copied
+}
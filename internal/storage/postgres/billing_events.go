@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+)
+
+// renewalLockKey identifies the advisory lock held by the replica running
+// renewals.
+const renewalLockKey = 0x72656e6577616c
+
+// TryLockRenewals takes the renewal lock on a connection of its own, so that
+// it outlives the transactions of the run, until unlock is called. It reports
+// false without waiting when another replica holds it.
+func (s *Storage) TryLockRenewals(ctx context.Context) (func(), bool, error) {
+	const op = "storage.postgres.TryLockRenewals"
+
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", renewalLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !locked {
+		conn.Close()
+		return func() {}, false, nil
+	}
+
+	unlock := func() {
+		// A connection that can't unlock is discarded, which releases
+		// the lock along with the session.
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", renewalLockKey); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	return unlock, true, nil
+}
+
+// DueRenewals looks at the subscriptions of every tenant.
+func (s *Storage) DueRenewals(ctx context.Context, today time.Time) ([]*domain.DueRenewal, error) {
+	const op = "storage.postgres.DueRenewals"
+
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id,
+			billed.billed_until
+		FROM user_subscriptions
+		LEFT JOIN (
+			SELECT subscription_id, MAX(period_end) AS billed_until
+			FROM billing_events
+			GROUP BY subscription_id
+		) billed ON billed.subscription_id = user_subscriptions.id
+		WHERE deleted_at IS NULL
+		  AND start_date <= $1
+		  AND (
+			billed.billed_until IS NULL
+			OR (billed.billed_until < $1 AND (end_date IS NULL OR billed.billed_until < end_date))
+		  )
+		ORDER BY id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, today)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var due []*domain.DueRenewal
+
+	for rows.Next() {
+		var billedUntil sql.NullTime
+
+		sub, err := scanSubscription(rows, &billedUntil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		renewal := &domain.DueRenewal{Subscription: sub}
+		if billedUntil.Valid {
+			renewal.BilledUntil = &billedUntil.Time
+		}
+		due = append(due, renewal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return due, nil
+}
+
+// AddBillingEvent stores the event unless its period is billed already, in
+// which case it reports false.
+func (s *Storage) AddBillingEvent(ctx context.Context, ev *domain.BillingEvent) (bool, error) {
+	const op = "storage.postgres.AddBillingEvent"
+
+	const query = `
+		INSERT INTO billing_events (
+			subscription_id,
+			type,
+			period_start,
+			period_end,
+			amount,
+			currency,
+			tenant_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT ON CONSTRAINT unique_billing_period DO NOTHING
+		RETURNING id, created_at
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		ev.SubscriptionID,
+		ev.Type,
+		ev.PeriodStart,
+		ev.PeriodEnd,
+		ev.Amount,
+		ev.Currency,
+		tenantID,
+	).Scan(&ev.ID, &ev.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) GetBillingEvents(ctx context.Context, subscriptionID int) ([]*domain.BillingEvent, error) {
+	const op = "storage.postgres.GetBillingEvents"
+
+	const query = `
+		SELECT
+			id,
+			subscription_id,
+			type,
+			TO_CHAR(period_start, 'YYYY-MM-DD'),
+			TO_CHAR(period_end, 'YYYY-MM-DD'),
+			amount,
+			currency,
+			created_at
+		FROM billing_events
+		WHERE tenant_id = $1
+		  AND subscription_id = $2
+		ORDER BY period_start
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, tenantID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []*domain.BillingEvent
+
+	for rows.Next() {
+		var ev domain.BillingEvent
+		if err := rows.Scan(
+			&ev.ID,
+			&ev.SubscriptionID,
+			&ev.Type,
+			&ev.PeriodStart,
+			&ev.PeriodEnd,
+			&ev.Amount,
+			&ev.Currency,
+			&ev.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, &ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
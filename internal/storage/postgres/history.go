@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+)
+
+func (s *Storage) AddHistoryRecord(ctx context.Context, rec *domain.HistoryRecord) error {
+	const op = "storage.postgres.AddHistoryRecord"
+
+	const query = `
+		INSERT INTO subscription_history (
+			subscription_id,
+			action,
+			before,
+			after,
+			actor,
+			request_id,
+			tenant_id
+		)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	before, err := marshalSnapshot(rec.Before)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	after, err := marshalSnapshot(rec.After)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		rec.SubscriptionID,
+		rec.Action,
+		before,
+		after,
+		rec.Actor,
+		rec.RequestID,
+		tenantID,
+	).Scan(&rec.ID, &rec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetHistory(ctx context.Context, subscriptionID int) ([]*domain.HistoryRecord, error) {
+	const op = "storage.postgres.GetHistory"
+
+	const query = `
+		SELECT
+			id,
+			subscription_id,
+			action,
+			before,
+			after,
+			actor,
+			COALESCE(request_id, ''),
+			created_at
+		FROM subscription_history
+		WHERE tenant_id = $1
+		  AND subscription_id = $2
+		ORDER BY id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, tenantID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var records []*domain.HistoryRecord
+
+	for rows.Next() {
+		var rec domain.HistoryRecord
+		var before, after []byte
+
+		if err := rows.Scan(
+			&rec.ID,
+			&rec.SubscriptionID,
+			&rec.Action,
+			&before,
+			&after,
+			&rec.Actor,
+			&rec.RequestID,
+			&rec.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if rec.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if rec.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		records = append(records, &rec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+func marshalSnapshot(sub *domain.UserSubscription) (sql.NullString, error) {
+	if sub == nil {
+		return sql.NullString{}, nil
+	}
+	raw, err := json.Marshal(sub)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+func unmarshalSnapshot(raw []byte) (*domain.UserSubscription, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var sub domain.UserSubscription
+	if err := json.Unmarshal(raw, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
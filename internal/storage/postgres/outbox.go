@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// outboxLockKey identifies the advisory lock held by the replica relaying
+// the outbox.
+const outboxLockKey = 0x6f7574626f78
+
+func (s *Storage) AddOutboxEvent(ctx context.Context, ev *domain.OutboxEvent) error {
+	const op = "storage.postgres.AddOutboxEvent"
+
+	const query = `
+		INSERT INTO outbox (event_id, type, partition_key, payload, tenant_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.conn(ctx).QueryRowContext(ctx, query, ev.EventID, ev.Type, ev.Key, []byte(ev.Payload), tenantID).
+		Scan(&ev.ID, &ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ev.TenantID = tenantID
+
+	return nil
+}
+
+// TryLockOutbox takes the relay lock until the transaction ends, so that
+// replicas claim batches one at a time. It reports false without waiting
+// when another replica holds it.
+func (s *Storage) TryLockOutbox(ctx context.Context) (bool, error) {
+	const op = "storage.postgres.TryLockOutbox"
+
+	var locked bool
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey).Scan(&locked); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return locked, nil
+}
+
+// ClaimOutboxEvents claims up to limit of the oldest events of every tenant
+// until now plus lease. It claims nothing while an earlier claim is in
+// force, so batches are published one after another.
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxEvent, error) {
+	const op = "storage.postgres.ClaimOutboxEvents"
+
+	const query = `
+		WITH claimed AS (
+			UPDATE outbox
+			SET claimed_until = $2
+			WHERE id IN (SELECT id FROM outbox ORDER BY id LIMIT $3)
+			  AND NOT EXISTS (SELECT 1 FROM outbox WHERE claimed_until > $1)
+			RETURNING id, event_id, type, partition_key, payload, created_at, tenant_id
+		)
+		SELECT * FROM claimed ORDER BY id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+
+	for rows.Next() {
+		var ev domain.OutboxEvent
+		var payload []byte
+
+		if err := rows.Scan(&ev.ID, &ev.EventID, &ev.Type, &ev.Key, &payload, &ev.CreatedAt, &ev.TenantID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ev.Payload = payload
+		events = append(events, &ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// ReleaseOutboxEvents drops the claim on events that couldn't be published.
+func (s *Storage) ReleaseOutboxEvents(ctx context.Context, ids []int64) error {
+	const op = "storage.postgres.ReleaseOutboxEvents"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "UPDATE outbox SET claimed_until = NULL WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	const op = "storage.postgres.DeleteOutboxEvents"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM outbox WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"subscription/internal/config"
+	"strconv"
+	"strings"
 	"subscription/internal/domain"
 	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/fx"
 	"subscription/internal/storage"
 	"time"
 
@@ -21,21 +24,39 @@ const (
 )
 
 type Storage struct {
-	DB *sql.DB
+	DB       *sql.DB
+	replicas *storage.Replicas
 }
 
-func New(dbConfig config.DbConfig) (*Storage, error) {
-	const op = "storage.postgresql.New"
+// New wraps a pool opened by connect.Open. Reads tolerating replication lag
+// go to the replicas, when there are any.
+func New(db *sql.DB, replicas *storage.Replicas) *Storage {
+	return &Storage{DB: db, replicas: replicas}
+}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbConfig.Host, dbConfig.Port, dbConfig.Username, dbConfig.Password, dbConfig.DBName)
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
+func (s *Storage) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	return storage.MigrationVersion(ctx, s.DB)
+}
+
+func (s *Storage) Close() error {
+	return errors.Join(s.DB.Close(), s.replicas.Close())
+}
+
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return storage.WithinTx(ctx, s.DB, fn)
+}
 
-	return &Storage{DB: db}, nil
+func (s *Storage) conn(ctx context.Context) storage.Querier {
+	return storage.Conn(ctx, s.DB)
+}
+
+// readConn is conn for the reads that may be served by a replica.
+func (s *Storage) readConn(ctx context.Context) storage.Querier {
+	return storage.ReadConn(ctx, s.DB, s.replicas)
 }
 
 func (s *Storage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
@@ -45,37 +66,39 @@ func (s *Storage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSub
 		INSERT INTO user_subscriptions (
 			service_name,
 			price,
+			currency,
+			billing_period,
 			user_id,
 			start_date,
-			end_date
+			end_date,
+			tenant_id
 		)
-		VALUES ($1, $2, $3, $4, $5)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`
 
-	startDate, err := time.Parse("01-2006", dto.StartDate)
+	tenantID, err := storage.Tenant(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var endDatePtr *time.Time
-	if dto.EndDate != "" {
-		endDate, err := time.Parse("01-2006", dto.EndDate)
-		if err != nil {
-			return 0, fmt.Errorf("%s: %w", op, err)
-		}
-		endDatePtr = &endDate
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate, op)
+	if err != nil {
+		return 0, err
 	}
 
 	var id int64
-	err = s.DB.QueryRowContext(
+	err = s.conn(ctx).QueryRowContext(
 		ctx,
 		query,
 		dto.ServiceName,
 		dto.Price,
+		dto.Currency,
+		dto.BillingPeriod,
 		dto.UserID,
 		startDate,
-		endDatePtr,
+		endDate,
+		tenantID,
 	).Scan(&id)
 	if err != nil {
 		var pgErr *pq.Error
@@ -101,24 +124,25 @@ func (s *Storage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.
 			id,
 			service_name,
 			price,
+			currency,
+			billing_period,
 			user_id,
-			TO_CHAR(start_date, 'MM-YYYY') AS start_date,
-			TO_CHAR(end_date, 'MM-YYYY')   AS end_date
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD')   AS end_date,
+			updated_at,
+			tenant_id
 		FROM user_subscriptions
 		WHERE id = $1
+		  AND tenant_id = $2
+		  AND deleted_at IS NULL
 	`
 
-	var sub domain.UserSubscription
-	var endDate sql.NullString
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	err := s.DB.QueryRowContext(ctx, query, id).Scan(
-		&sub.ID,
-		&sub.ServiceName,
-		&sub.Price,
-		&sub.UserID,
-		&sub.StartDate,
-		&endDate,
-	)
+	sub, err := scanSubscription(s.readConn(ctx).QueryRowContext(ctx, query, id, tenantID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrNotFound
@@ -126,80 +150,272 @@ func (s *Storage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if endDate.Valid {
-		sub.EndDate = endDate.String
-	}
+	return sub, nil
+}
 
-	return &sub, nil
+type sortColumn struct {
+	expr string
+	cast string
 }
 
-func (s *Storage) GetUserSubscriptionsListByUUID(ctx context.Context, userID uuid.UUID) ([]*domain.UserSubscription, error) {
-	const op = "storage.postgresql.GetUserSubscriptionsListByUUID"
+var sortColumns = map[string]sortColumn{
+	"id":           {expr: "id", cast: "int"},
+	"service_name": {expr: "service_name", cast: "text"},
+	"price":        {expr: "price", cast: "numeric"},
+	"user_id":      {expr: "user_id", cast: "uuid"},
+	"start_date":   {expr: "user_subscriptions.start_date", cast: "date"},
+	"end_date":     {expr: "COALESCE(user_subscriptions.end_date, DATE '9999-12-31')", cast: "date"},
+	"created_at":   {expr: "COALESCE(created_at, TIMESTAMP 'epoch')", cast: "timestamp"},
+	"updated_at":   {expr: "COALESCE(updated_at, TIMESTAMP 'epoch')", cast: "timestamp"},
+}
 
-	const query = `
+func (s *Storage) ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	const op = "storage.postgres.ListUserSubscriptions"
+
+	column, ok := sortColumns[dto.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conds, args, err := listConditions(tenantID, dto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	cmp, order := ">", "ASC"
+	if dto.Order == "desc" {
+		cmp, order = "<", "DESC"
+	}
+
+	if dto.Cursor != "" {
+		cursor, err := storage.DecodeCursor(dto.Cursor, dto.SortBy, dto.Order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		conds = append(conds, fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			column.expr, cmp, arg(cursor.Value), column.cast, arg(cursor.ID)))
+	}
+
+	where := "WHERE " + strings.Join(conds, " AND ")
+
+	query := fmt.Sprintf(`
 		SELECT
 			id,
 			service_name,
 			price,
+			currency,
+			billing_period,
 			user_id,
-			TO_CHAR(start_date, 'MM-YYYY') AS start_date,
-			TO_CHAR(end_date, 'MM-YYYY') AS end_date
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id,
+			(%s)::text AS sort_key
 		FROM user_subscriptions
-		WHERE user_id = $1
-	`
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT %s
+	`, column.expr, where, column.expr, order, order, arg(dto.Limit+1))
 
-	rows, err := s.DB.QueryContext(ctx, query, userID)
+	rows, err := s.readConn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var subscriptions []*domain.UserSubscription
+	page := &domain.UserSubscriptionPage{Items: make([]*domain.UserSubscription, 0, dto.Limit)}
+	var sortKey string
 
 	for rows.Next() {
-		var sub domain.UserSubscription
-		var endDate sql.NullString
-
-		if err := rows.Scan(
-			&sub.ID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.UserID,
-			&sub.StartDate,
-			&endDate,
-		); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		if len(page.Items) == dto.Limit {
+			id, err := strconv.ParseInt(page.Items[len(page.Items)-1].ID, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			page.NextCursor = storage.EncodeCursor(storage.Cursor{
+				SortBy: dto.SortBy,
+				Order:  dto.Order,
+				Value:  sortKey,
+				ID:     id,
+			})
+			break
 		}
 
-		if endDate.Valid {
-			sub.EndDate = endDate.String
-		} else {
-			sub.EndDate = ""
+		sub, err := scanSubscription(rows, &sortKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 
-		subscriptions = append(subscriptions, &sub)
+		page.Items = append(page.Items, sub)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if len(subscriptions) == 0 {
-		return nil, storage.ErrUserNotFound
+	return page, nil
+}
+
+// ExportUserSubscriptions calls fn for every subscription matching the
+// listing filters in sort order. Rows are read as fn consumes them, so the
+// result set is never held in memory.
+func (s *Storage) ExportUserSubscriptions(
+	ctx context.Context,
+	dto dto.ListUserSubs,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const op = "storage.postgres.ExportUserSubscriptions"
+
+	column, ok := sortColumns[dto.SortBy]
+	if !ok {
+		return fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return subscriptions, nil
+	conds, args, err := listConditions(tenantID, dto)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order := "ASC"
+	if dto.Order == "desc" {
+		order = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE %s
+		ORDER BY %s %s, id %s
+	`, strings.Join(conds, " AND "), column.expr, order, order)
+
+	rows, err := s.readConn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// listConditions translates the listing filters into WHERE conditions of
+// live subscriptions of the tenant and their arguments.
+func listConditions(tenantID string, dto dto.ListUserSubs) ([]string, []any, error) {
+	var (
+		conds = []string{"tenant_id = $1", "deleted_at IS NULL"}
+		args  = []any{tenantID}
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if dto.UserID != uuid.Nil {
+		conds = append(conds, "user_id = "+arg(dto.UserID))
+	}
+	if dto.ServiceName != "" {
+		conds = append(conds, "service_name = "+arg(dto.ServiceName))
+	}
+	if dto.ServiceNamePrefix != "" {
+		conds = append(conds, "service_name LIKE "+arg(escapeLike(dto.ServiceNamePrefix)+"%"))
+	}
+	if dto.MinPrice != nil {
+		conds = append(conds, "price >= "+arg(*dto.MinPrice))
+	}
+	if dto.MaxPrice != nil {
+		conds = append(conds, "price <= "+arg(*dto.MaxPrice))
+	}
+	if dto.ActiveOn != "" {
+		activeOn, err := billing.ParseDate(dto.ActiveOn)
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, fmt.Sprintf("start_date <= %s AND (end_date IS NULL OR end_date >= %s)", arg(activeOn.To), arg(activeOn.From)))
+	}
+	for _, f := range []struct {
+		value string
+		cond  string
+		upper bool
+	}{
+		{dto.StartFrom, "start_date >= ", false},
+		{dto.StartTo, "start_date <= ", true},
+		{dto.EndFrom, "end_date >= ", false},
+		{dto.EndTo, "end_date <= ", true},
+	} {
+		if f.value == "" {
+			continue
+		}
+		date, err := billing.ParseDate(f.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		bound := date.From
+		if f.upper {
+			bound = date.To
+		}
+		conds = append(conds, f.cond+arg(bound))
+	}
+
+	return conds, args, nil
 }
 
 func (s *Storage) DeleteUserSubscriptionByID(ctx context.Context, id int) error {
 	const op = "storage.postgresql.DeleteUserSubscriptionByID"
 
-	stmt, err := s.DB.Prepare("DELETE FROM user_subscriptions WHERE id = $1")
+	const query = `
+		UPDATE user_subscriptions
+		SET
+			deleted_at = NOW(),
+			updated_at = NOW()
+		WHERE id = $1
+		  AND tenant_id = $2
+		  AND deleted_at IS NULL
+	`
+
+	tenantID, err := storage.Tenant(ctx)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	result, err := stmt.ExecContext(ctx, id)
+	result, err := s.conn(ctx).ExecContext(ctx, query, id, tenantID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -216,6 +432,103 @@ func (s *Storage) DeleteUserSubscriptionByID(ctx context.Context, id int) error
 	return nil
 }
 
+func (s *Storage) RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "storage.postgres.RestoreUserSubscription"
+
+	const query = `
+		UPDATE user_subscriptions
+		SET
+			deleted_at = NULL,
+			updated_at = NOW()
+		WHERE id = $1
+		  AND tenant_id = $2
+		  AND deleted_at IS NOT NULL
+		RETURNING
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub, err := scanSubscription(s.conn(ctx).QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+
+		var pgErr *pq.Error
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case ErrExistsCode:
+				return nil, fmt.Errorf("%s: %w", op, storage.ErrUserSubExists)
+			case ErrOverLapCode:
+				return nil, fmt.Errorf("%s: %w", op, storage.ErrOverlap)
+			}
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+// PurgeDeletedSubscriptions removes the subscriptions of every tenant
+// soft-deleted more than retention ago and returns them.
+func (s *Storage) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error) {
+	const op = "storage.postgres.PurgeDeletedSubscriptions"
+
+	const query = `
+		DELETE FROM user_subscriptions
+		WHERE deleted_at IS NOT NULL
+		  AND deleted_at < NOW() - $1::interval
+		RETURNING
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id
+	`
+
+	interval := fmt.Sprintf("%d microseconds", retention.Microseconds())
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, interval)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subs []*domain.UserSubscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
 func (s *Storage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
 	const op = "storage.postgres.UpdateUserSubscription"
 
@@ -224,46 +537,58 @@ func (s *Storage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUser
 		SET
 			service_name = $2,
 			price = $3,
-			user_id = $4,
-			start_date = $5,
-			end_date = $6,
+			currency = $4,
+			billing_period = $5,
+			user_id = $6,
+			start_date = $7,
+			end_date = $8,
 			updated_at = NOW()
 		WHERE id = $1
+		  AND tenant_id = $10
+		  AND deleted_at IS NULL
+		  AND ($9::timestamp IS NULL OR updated_at = $9)
 		RETURNING
 			id,
 			service_name,
 			price,
+			currency,
+			billing_period,
 			user_id,
-			TO_CHAR(start_date, 'MM-YYYY') AS start_date,
-			TO_CHAR(end_date, 'MM-YYYY') AS end_date
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id
 	`
 
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate, op)
 	if err != nil {
 		return nil, err
 	}
 
-	var sub domain.UserSubscription
-
-	err = s.DB.QueryRowContext(
+	sub, err := scanSubscription(s.conn(ctx).QueryRowContext(
 		ctx,
 		query,
 		dto.ID,
 		dto.ServiceName,
 		dto.Price,
+		dto.Currency,
+		dto.BillingPeriod,
 		dto.UserID,
 		startDate,
 		endDate,
-	).Scan(
-		&sub.ID,
-		&sub.ServiceName,
-		&sub.Price,
-		&sub.UserID,
-		&sub.StartDate,
-		&sub.EndDate,
-	)
+		dto.Version,
+		tenantID,
+	))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			if dto.Version != nil {
+				return nil, s.versionMismatchOrNotFound(ctx, op, tenantID, dto.ID)
+			}
 			return nil, storage.ErrNotFound
 		}
 
@@ -279,62 +604,211 @@ func (s *Storage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUser
 
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return &sub, nil
+	return sub, nil
 }
 
-func (s *Storage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost) (int64, error) {
-	const op = "storage.postgres.CalculateTotalCost"
+// versionMismatchOrNotFound tells apart a conditional update that lost the
+// race from one that targeted a missing row.
+func (s *Storage) versionMismatchOrNotFound(ctx context.Context, op string, tenantID string, id int) error {
+	var exists bool
+	err := s.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM user_subscriptions WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL)", id, tenantID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return storage.ErrNotFound
+	}
+	return fmt.Errorf("%s: %w", op, storage.ErrVersionMismatch)
+}
 
-	const query = `
-		SELECT COALESCE(SUM(price), 0)
-		FROM user_subscriptions
-		WHERE user_id = $1
-		  AND service_name = $2
-		  AND start_date >= $3
-		  AND end_date <= $4
-	`
+func (s *Storage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error) {
+	const op = "storage.postgres.CalculateTotalCost"
 
 	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate, op)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	var totalCost int64
-	err = s.DB.QueryRowContext(
-		ctx,
-		query,
-		dto.UserID,
-		dto.ServiceName,
-		startDate,
-		endDate,
-	).Scan(&totalCost)
+	window := billing.NewPeriod(startDate, endDate, time.Now())
+
+	tenantID, err := storage.Tenant(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscriptions, err := s.activeSubscriptions(ctx, tenantID, uuid.NullUUID{UUID: dto.UserID, Valid: true}, dto.ServiceName, window)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	totalCost, err := billing.TotalCost(subscriptions, window, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return totalCost, nil
 }
 
-func parseDates(startDateStr, endDateStr string, op string) (time.Time, *time.Time, error) {
-	var (
-		startDate  time.Time
-		endDate    time.Time
-		endDatePtr *time.Time
-		err        error
-	)
+func (s *Storage) CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error) {
+	const op = "storage.postgres.CostAnalytics"
 
-	startDate, err = time.Parse("01-2006", startDateStr)
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate, op)
 	if err != nil {
-		return time.Time{}, nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+
+	window := billing.NewPeriod(startDate, endDate, time.Now())
+
+	userID := uuid.NullUUID{UUID: dto.UserID, Valid: dto.UserID != uuid.Nil}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	analyzer, err := billing.NewAnalyzer(window, dto.GroupBy, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Rows are streamed, the subscriptions of the tenant may not fit in memory.
+	if err := s.eachActiveSubscription(ctx, tenantID, userID, dto.ServiceName, window, analyzer.Add); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return analyzer.Buckets(), nil
+}
+
+// activeSubscriptions returns subscriptions of the tenant active at least
+// one day of the window, optionally narrowed to a user and a service.
+func (s *Storage) activeSubscriptions(
+	ctx context.Context,
+	tenantID string,
+	userID uuid.NullUUID,
+	serviceName string,
+	window billing.Period,
+) ([]*domain.UserSubscription, error) {
+	var subscriptions []*domain.UserSubscription
+	err := s.eachActiveSubscription(ctx, tenantID, userID, serviceName, window, func(sub *domain.UserSubscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// eachActiveSubscription calls fn with the subscriptions activeSubscriptions
+// returns, as they are read.
+func (s *Storage) eachActiveSubscription(
+	ctx context.Context,
+	tenantID string,
+	userID uuid.NullUUID,
+	serviceName string,
+	window billing.Period,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			TO_CHAR(start_date, 'YYYY-MM-DD') AS start_date,
+			TO_CHAR(end_date, 'YYYY-MM-DD') AS end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE tenant_id = $5
+		  AND deleted_at IS NULL
+		  AND ($1::uuid IS NULL OR user_id = $1)
+		  AND ($2 = '' OR service_name = $2)
+		  AND start_date <= $4
+		  AND (end_date IS NULL OR end_date >= $3)
+		ORDER BY user_subscriptions.start_date, id
+	`
+
+	rows, err := s.readConn(ctx).QueryContext(ctx, query, userID, serviceName, window.From, window.To, tenantID)
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	if endDateStr != "" {
-		endDate, err = time.Parse("01-2006", endDateStr)
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
 		if err != nil {
-			return time.Time{}, nil, fmt.Errorf("%s: %w", op, err)
+			return err
 		}
-		endDatePtr = &endDate
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSubscription reads the common subscription columns followed by extra
+// destinations selected after them.
+func scanSubscription(row scanner, extra ...any) (*domain.UserSubscription, error) {
+	var sub domain.UserSubscription
+	var endDate sql.NullString
+	var updatedAt sql.NullTime
+
+	dest := append([]any{
+		&sub.ID,
+		&sub.ServiceName,
+		&sub.Price,
+		&sub.Currency,
+		&sub.BillingPeriod,
+		&sub.UserID,
+		&sub.StartDate,
+		&endDate,
+		&updatedAt,
+		&sub.TenantID,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	if endDate.Valid {
+		sub.EndDate = endDate.String
+	}
+	if updatedAt.Valid {
+		sub.UpdatedAt = updatedAt.Time
+	}
+
+	return &sub, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// parseDates returns the first and the last day of a subscription. A month
+// starts on its first day and ends on its last.
+func parseDates(startDateStr, endDateStr string, op string) (time.Time, *time.Time, error) {
+	startDate, err := billing.ParseDate(startDateStr)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if endDateStr == "" {
+		return startDate.From, nil, nil
+	}
+
+	endDate, err := billing.ParseDate(endDateStr)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return startDate, endDatePtr, nil
+	return startDate.From, &endDate.To, nil
 }
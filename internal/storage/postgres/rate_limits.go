@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"subscription/internal/lib/ratelimit"
+	"subscription/internal/storage"
+	"time"
+)
+
+// refilled is the token count of bucket b refilled up to $3, capped at $2
+// tokens, at $4 tokens per second.
+const refilled = `LEAST(
+	$2::double precision,
+	b.tokens + GREATEST(EXTRACT(EPOCH FROM $3::timestamp - b.updated_at)::double precision, 0) * $4::double precision
+)`
+
+// TakeRateLimitToken implements ratelimit.Store on the buckets shared by
+// every replica. Once in a while it drops the buckets idle for longer than
+// storage.RateLimitIdle.
+func (s *Storage) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	const op = "storage.postgres.TakeRateLimitToken"
+
+	const query = `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2::double precision - 1, TRUE, $3::timestamp)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1 ELSE ` + refilled + ` END,
+			allowed = ` + refilled + ` >= 1,
+			updated_at = GREATEST(b.updated_at, $3::timestamp)
+		RETURNING tokens, allowed
+	`
+
+	now = now.UTC()
+
+	var tokens float64
+	var allowed bool
+	err := s.conn(ctx).QueryRowContext(ctx, query, key, float64(limit.Requests), now, limit.Rate()).
+		Scan(&tokens, &allowed)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rand.IntN(storage.RateLimitSweepOdds) == 0 {
+		if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < $1", now.Add(-storage.RateLimitIdle)); err != nil {
+			return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return ratelimit.NewResult(allowed, tokens, limit), nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"subscription/internal/domain"
+	"time"
+)
+
+// TenantReports sums up the data of every tenant, or of tenantID only when
+// it isn't empty.
+func (s *Storage) TenantReports(ctx context.Context, tenantID string, today time.Time) ([]*domain.TenantReport, error) {
+	const op = "storage.postgres.TenantReports"
+
+	const query = `
+		SELECT
+			tenants.tenant_id,
+			COALESCE(subs.subscriptions, 0),
+			COALESCE(subs.active, 0),
+			COALESCE(subs.deleted, 0),
+			COALESCE(subs.users, 0),
+			COALESCE(hooks.webhooks, 0)
+		FROM (
+			SELECT tenant_id FROM user_subscriptions
+			UNION
+			SELECT tenant_id FROM webhooks
+		) tenants
+		LEFT JOIN (
+			SELECT
+				tenant_id,
+				COUNT(*) FILTER (WHERE deleted_at IS NULL) AS subscriptions,
+				COUNT(*) FILTER (
+					WHERE deleted_at IS NULL
+					  AND start_date <= $2
+					  AND (end_date IS NULL OR end_date >= $2)
+				) AS active,
+				COUNT(*) FILTER (WHERE deleted_at IS NOT NULL) AS deleted,
+				COUNT(DISTINCT user_id) FILTER (WHERE deleted_at IS NULL) AS users
+			FROM user_subscriptions
+			GROUP BY tenant_id
+		) subs ON subs.tenant_id = tenants.tenant_id
+		LEFT JOIN (
+			SELECT tenant_id, COUNT(*) AS webhooks
+			FROM webhooks
+			GROUP BY tenant_id
+		) hooks ON hooks.tenant_id = tenants.tenant_id
+		WHERE $1::text = '' OR tenants.tenant_id = $1::text
+		ORDER BY tenants.tenant_id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, tenantID, today)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	reports := []*domain.TenantReport{}
+
+	for rows.Next() {
+		var r domain.TenantReport
+		if err := rows.Scan(
+			&r.TenantID,
+			&r.Subscriptions,
+			&r.ActiveSubscriptions,
+			&r.DeletedSubscriptions,
+			&r.Users,
+			&r.Webhooks,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		reports = append(reports, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "time"
+
+const (
+	// RateLimitIdle is how long a rate limit bucket is kept without
+	// requests. A dropped bucket starts full again, so limits with longer
+	// periods are enforced over RateLimitIdle at most.
+	RateLimitIdle = 24 * time.Hour
+
+	// RateLimitSweepOdds makes one in so many requests drop idle buckets.
+	RateLimitSweepOdds = 1000
+)
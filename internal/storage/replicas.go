@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"subscription/internal/lib/logger/sl"
+	"sync/atomic"
+	"time"
+)
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Replicas balances reads round robin over the read replicas that passed
+// their last health check. Replicas are only used once Run checked them.
+type Replicas struct {
+	log      *slog.Logger
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+}
+
+func NewReplicas(dbs []*sql.DB, interval time.Duration, log *slog.Logger) *Replicas {
+	r := &Replicas{
+		log:      log.With(slog.String("component", "storage/replicas")),
+		interval: interval,
+	}
+	for _, db := range dbs {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// Run pings the replicas every interval until ctx is done.
+func (r *Replicas) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Replicas) check(ctx context.Context) {
+	for i, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, r.interval)
+		err := rep.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				r.log.Info("replica is up", slog.Int("replica", i))
+			} else {
+				r.log.Warn("replica is down, reading from the others", slog.Int("replica", i), sl.Err(err))
+			}
+		}
+	}
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (r *Replicas) pick() *sql.DB {
+	if r == nil || len(r.replicas) == 0 {
+		return nil
+	}
+
+	start := r.next.Add(1)
+	for i := range uint64(len(r.replicas)) {
+		rep := r.replicas[(start+i)%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return nil
+}
+
+func (r *Replicas) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes the reads of ctx go to the primary, so that they see
+// the writes replicas may not have caught up with yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// OnPrimary reports whether the reads of ctx are pinned to the primary.
+func OnPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// ReadConn is Conn for reads tolerating replication lag: outside of a
+// transaction and unless ctx is pinned to the primary, they go to a healthy
+// replica when there is one.
+func ReadConn(ctx context.Context, db *sql.DB, replicas *Replicas) Querier {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return Conn(ctx, db)
+	}
+	if OnPrimary(ctx) {
+		return Conn(ctx, db)
+	}
+	if replica := replicas.pick(); replica != nil {
+		return tracedQuerier{replica}
+	}
+	return Conn(ctx, db)
+}
@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+)
+
+// TryLockRenewals always succeeds: write transactions already lock the whole
+// database, and unique_billing_period keeps concurrent runs from billing a
+// period twice.
+func (s *Storage) TryLockRenewals(ctx context.Context) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+// DueRenewals looks at the subscriptions of every tenant.
+func (s *Storage) DueRenewals(ctx context.Context, today time.Time) ([]*domain.DueRenewal, error) {
+	const op = "storage.sqlite.DueRenewals"
+
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id,
+			billed.billed_until
+		FROM user_subscriptions
+		LEFT JOIN (
+			SELECT subscription_id, MAX(period_end) AS billed_until
+			FROM billing_events
+			GROUP BY subscription_id
+		) billed ON billed.subscription_id = user_subscriptions.id
+		WHERE deleted_at IS NULL
+		  AND start_date <= ?1
+		  AND (
+			billed.billed_until IS NULL
+			OR (billed.billed_until < ?1 AND (end_date IS NULL OR billed.billed_until < end_date))
+		  )
+		ORDER BY id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, today.Format(dateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var due []*domain.DueRenewal
+
+	for rows.Next() {
+		var billedUntil sql.NullString
+
+		sub, err := scanSubscription(rows, &billedUntil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		renewal := &domain.DueRenewal{Subscription: sub}
+		if billedUntil.Valid {
+			t, err := time.Parse(dateLayout, billedUntil.String)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			renewal.BilledUntil = &t
+		}
+		due = append(due, renewal)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return due, nil
+}
+
+// AddBillingEvent stores the event unless its period is billed already, in
+// which case it reports false.
+func (s *Storage) AddBillingEvent(ctx context.Context, ev *domain.BillingEvent) (bool, error) {
+	const op = "storage.sqlite.AddBillingEvent"
+
+	const query = `
+		INSERT INTO billing_events (
+			subscription_id,
+			type,
+			period_start,
+			period_end,
+			amount,
+			currency,
+			created_at,
+			tenant_id
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (subscription_id, period_start) DO NOTHING
+		RETURNING id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		ev.SubscriptionID,
+		ev.Type,
+		ev.PeriodStart,
+		ev.PeriodEnd,
+		toPriceUnits(ev.Amount),
+		ev.Currency,
+		createdAt.Format(timestampLayout),
+		tenantID,
+	).Scan(&ev.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	ev.CreatedAt = createdAt
+
+	return true, nil
+}
+
+func (s *Storage) GetBillingEvents(ctx context.Context, subscriptionID int) ([]*domain.BillingEvent, error) {
+	const op = "storage.sqlite.GetBillingEvents"
+
+	const query = `
+		SELECT
+			id,
+			subscription_id,
+			type,
+			period_start,
+			period_end,
+			amount,
+			currency,
+			created_at
+		FROM billing_events
+		WHERE tenant_id = ?
+		  AND subscription_id = ?
+		ORDER BY period_start
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, tenantID, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []*domain.BillingEvent
+
+	for rows.Next() {
+		var ev domain.BillingEvent
+		var amount int64
+		var createdAt string
+
+		if err := rows.Scan(
+			&ev.ID,
+			&ev.SubscriptionID,
+			&ev.Type,
+			&ev.PeriodStart,
+			&ev.PeriodEnd,
+			&amount,
+			&ev.Currency,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		ev.Amount = fromPriceUnits(amount)
+		if ev.CreatedAt, err = time.Parse(timestampLayout, createdAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		events = append(events, &ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"subscription/internal/lib/idempotency"
+	"subscription/internal/storage"
+	"time"
+)
+
+// ReserveIdempotencyKey implements idempotency.Store. The insert takes the
+// write lock, the record found can't change until it's either returned or
+// taken over. Once in a while it drops the expired keys.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, now, expiredBefore, staleBefore time.Time) (*idempotency.Record, error) {
+	const op = "storage.sqlite.ReserveIdempotencyKey"
+
+	created := now.UTC().Format(timestampLayout)
+	expired := expiredBefore.UTC().Format(timestampLayout)
+
+	var rec *idempotency.Record
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		var reserved string
+		err := s.conn(ctx).QueryRowContext(ctx, `
+			INSERT INTO idempotency_keys (key, fingerprint, created_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (key) DO NOTHING
+			RETURNING key
+		`, key, fingerprint, created).Scan(&reserved)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		var found idempotency.Record
+		var status sql.NullInt64
+		var contentType sql.NullString
+		var body []byte
+		var createdAt string
+		err = s.conn(ctx).QueryRowContext(ctx,
+			"SELECT fingerprint, status, content_type, body, created_at FROM idempotency_keys WHERE key = ?",
+			key,
+		).Scan(&found.Fingerprint, &status, &contentType, &body, &createdAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return idempotency.ErrKeyBusy
+		}
+		if err != nil {
+			return err
+		}
+		if found.CreatedAt, err = time.Parse(timestampLayout, createdAt); err != nil {
+			return err
+		}
+
+		if found.CreatedAt.Before(expiredBefore) || (!status.Valid && found.CreatedAt.Before(staleBefore)) {
+			_, err := s.conn(ctx).ExecContext(ctx, `
+				UPDATE idempotency_keys
+				SET fingerprint = ?2, status = NULL, content_type = NULL, body = NULL, created_at = ?3
+				WHERE key = ?1
+			`, key, fingerprint, created)
+			return err
+		}
+
+		if status.Valid {
+			found.Response = &idempotency.Response{
+				Status:      int(status.Int64),
+				ContentType: contentType.String,
+				Body:        body,
+			}
+		}
+		rec = &found
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rec == nil && rand.IntN(storage.IdempotencyKeySweepOdds) == 0 {
+		if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?", expired); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return rec, nil
+}
+
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, key string, resp idempotency.Response) error {
+	const op = "storage.sqlite.SaveIdempotentResponse"
+
+	const query = `
+		UPDATE idempotency_keys
+		SET status = ?2, content_type = ?3, body = ?4
+		WHERE key = ?1 AND status IS NULL
+	`
+
+	if _, err := s.conn(ctx).ExecContext(ctx, query, key, resp.Status, resp.ContentType, resp.Body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	const op = "storage.sqlite.ReleaseIdempotencyKey"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = ? AND status IS NULL", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/storage"
+	"time"
+)
+
+func (s *Storage) AddOutboxEvent(ctx context.Context, ev *domain.OutboxEvent) error {
+	const op = "storage.sqlite.AddOutboxEvent"
+
+	const query = `
+		INSERT INTO outbox (event_id, type, partition_key, payload, created_at, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		ev.EventID,
+		ev.Type,
+		ev.Key,
+		string(ev.Payload),
+		createdAt.Format(timestampLayout),
+		tenantID,
+	).Scan(&ev.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ev.CreatedAt = createdAt
+	ev.TenantID = tenantID
+
+	return nil
+}
+
+// TryLockOutbox always succeeds: write transactions already lock the whole
+// database, so relays never claim concurrently.
+func (s *Storage) TryLockOutbox(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+// ClaimOutboxEvents claims up to limit of the oldest events of every tenant
+// until now plus lease. It claims nothing while an earlier claim is in
+// force, so batches are published one after another.
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxEvent, error) {
+	const op = "storage.sqlite.ClaimOutboxEvents"
+
+	const query = `
+		UPDATE outbox
+		SET claimed_until = ?2
+		WHERE id IN (SELECT id FROM outbox ORDER BY id LIMIT ?3)
+		  AND NOT EXISTS (SELECT 1 FROM outbox WHERE claimed_until > ?1)
+		RETURNING id, event_id, type, partition_key, payload, created_at, tenant_id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(
+		ctx,
+		query,
+		now.UTC().Format(timestampLayout),
+		now.UTC().Add(lease).Format(timestampLayout),
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+
+	for rows.Next() {
+		var ev domain.OutboxEvent
+		var payload, createdAt string
+
+		if err := rows.Scan(&ev.ID, &ev.EventID, &ev.Type, &ev.Key, &payload, &createdAt, &ev.TenantID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ev.Payload = json.RawMessage(payload)
+		if ev.CreatedAt, err = time.Parse(timestampLayout, createdAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, &ev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// RETURNING yields rows in no particular order.
+	slices.SortFunc(events, func(a, b *domain.OutboxEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return events, nil
+}
+
+// ReleaseOutboxEvents drops the claim on events that couldn't be published.
+func (s *Storage) ReleaseOutboxEvents(ctx context.Context, ids []int64) error {
+	const op = "storage.sqlite.ReleaseOutboxEvents"
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := inIDs("UPDATE outbox SET claimed_until = NULL WHERE id IN", ids)
+	if _, err := s.conn(ctx).ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	const op = "storage.sqlite.DeleteOutboxEvents"
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args := inIDs("DELETE FROM outbox WHERE id IN", ids)
+	if _, err := s.conn(ctx).ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// inIDs appends a list of placeholders for ids to query.
+func inIDs(query string, ids []int64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return query + " (?" + strings.Repeat(", ?", len(ids)-1) + ")", args
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"subscription/internal/lib/ratelimit"
+	"subscription/internal/storage"
+	"time"
+)
+
+// refilled is the token count of a bucket refilled up to ?3, capped at ?2
+// tokens, at ?4 tokens per second.
+const refilled = `MIN(?2, tokens + MAX(?3 - updated_at, 0) * ?4)`
+
+// TakeRateLimitToken implements ratelimit.Store on the buckets shared by
+// every process using the database. Once in a while it drops the buckets
+// idle for longer than storage.RateLimitIdle.
+func (s *Storage) TakeRateLimitToken(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	const op = "storage.sqlite.TakeRateLimitToken"
+
+	const query = `
+		INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+		VALUES (?1, ?2 - 1, 1, ?3)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN ` + refilled + ` >= 1 THEN ` + refilled + ` - 1 ELSE ` + refilled + ` END,
+			allowed = ` + refilled + ` >= 1,
+			updated_at = MAX(updated_at, ?3)
+		RETURNING tokens, allowed
+	`
+
+	seconds := float64(now.UnixMicro()) / 1e6
+
+	var tokens float64
+	var allowed bool
+	err := s.conn(ctx).QueryRowContext(ctx, query, key, float64(limit.Requests), seconds, limit.Rate()).
+		Scan(&tokens, &allowed)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rand.IntN(storage.RateLimitSweepOdds) == 0 {
+		idleBefore := seconds - storage.RateLimitIdle.Seconds()
+		if _, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < ?", idleBefore); err != nil {
+			return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return ratelimit.NewResult(allowed, tokens, limit), nil
+}
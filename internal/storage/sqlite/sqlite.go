@@ -0,0 +1,959 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/fx"
+	"subscription/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05.000000"
+
+	// openEnd mirrors the upper bound the Postgres no_overlap constraint
+	// uses for subscriptions without end date.
+	openEnd = "9999-12-31"
+)
+
+type Storage struct {
+	DB *sql.DB
+}
+
+// New wraps a pool opened by connect.Open.
+func New(db *sql.DB) *Storage {
+	return &Storage{DB: db}
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
+func (s *Storage) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	return storage.MigrationVersion(ctx, s.DB)
+}
+
+func (s *Storage) Close() error {
+	return s.DB.Close()
+}
+
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return storage.WithinTx(ctx, s.DB, fn)
+}
+
+func (s *Storage) conn(ctx context.Context) storage.Querier {
+	return storage.Conn(ctx, s.DB)
+}
+
+func (s *Storage) AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
+	const op = "storage.sqlite.AddUserSubscription"
+
+	const query = `
+		INSERT INTO user_subscriptions (
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			created_at,
+			updated_at,
+			tenant_id
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		tx := s.conn(ctx)
+
+		if err := checkConstraints(ctx, tx, tenantID, 0, dto.UserID, dto.ServiceName, startDate, endDate); err != nil {
+			return err
+		}
+
+		now := timestamp()
+		result, err := tx.ExecContext(ctx, query, dto.ServiceName, toPriceUnits(dto.Price), dto.Currency, dto.BillingPeriod, dto.UserID, startDate, endDate, now, now, tenantID)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetUserSubscriptionById(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "storage.sqlite.GetUserSubscriptionById"
+
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE id = ?
+		  AND tenant_id = ?
+		  AND deleted_at IS NULL
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub, err := scanSubscription(s.conn(ctx).QueryRowContext(ctx, query, id, tenantID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+type sortColumn struct {
+	expr    string
+	numeric bool
+}
+
+var sortColumns = map[string]sortColumn{
+	"id":           {expr: "id", numeric: true},
+	"service_name": {expr: "service_name"},
+	"price":        {expr: "price", numeric: true},
+	"user_id":      {expr: "user_id"},
+	"start_date":   {expr: "user_subscriptions.start_date"},
+	"end_date":     {expr: "COALESCE(user_subscriptions.end_date, '" + openEnd + "')"},
+	"created_at":   {expr: "created_at"},
+	"updated_at":   {expr: "updated_at"},
+}
+
+func (s *Storage) ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	const op = "storage.sqlite.ListUserSubscriptions"
+
+	column, ok := sortColumns[dto.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conds, args, err := listConditions(tenantID, dto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cmp, order := ">", "ASC"
+	if dto.Order == "desc" {
+		cmp, order = "<", "DESC"
+	}
+
+	if dto.Cursor != "" {
+		cursor, err := storage.DecodeCursor(dto.Cursor, dto.SortBy, dto.Order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var value any = cursor.Value
+		if column.numeric {
+			n, err := strconv.ParseInt(cursor.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w: %w", op, storage.ErrInvalidCursor, err)
+			}
+			value = n
+		}
+
+		conds = append(conds, fmt.Sprintf("(%s, id) %s (?, ?)", column.expr, cmp))
+		args = append(args, value, cursor.ID)
+	}
+
+	where := "WHERE " + strings.Join(conds, " AND ")
+
+	query := fmt.Sprintf(`
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id,
+			CAST(%s AS TEXT) AS sort_key
+		FROM user_subscriptions
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT ?
+	`, column.expr, where, column.expr, order, order)
+	args = append(args, dto.Limit+1)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	page := &domain.UserSubscriptionPage{Items: make([]*domain.UserSubscription, 0, dto.Limit)}
+	var sortKey string
+
+	for rows.Next() {
+		if len(page.Items) == dto.Limit {
+			id, err := strconv.ParseInt(page.Items[len(page.Items)-1].ID, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			page.NextCursor = storage.EncodeCursor(storage.Cursor{
+				SortBy: dto.SortBy,
+				Order:  dto.Order,
+				Value:  sortKey,
+				ID:     id,
+			})
+			break
+		}
+
+		sub, err := scanSubscription(rows, &sortKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		page.Items = append(page.Items, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+// ExportUserSubscriptions calls fn for every subscription matching the
+// listing filters in sort order. Rows are read as fn consumes them, so the
+// result set is never held in memory.
+func (s *Storage) ExportUserSubscriptions(
+	ctx context.Context,
+	dto dto.ListUserSubs,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const op = "storage.sqlite.ExportUserSubscriptions"
+
+	column, ok := sortColumns[dto.SortBy]
+	if !ok {
+		return fmt.Errorf("%s: unknown sort column %q", op, dto.SortBy)
+	}
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	conds, args, err := listConditions(tenantID, dto)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order := "ASC"
+	if dto.Order == "desc" {
+		order = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE %s
+		ORDER BY %s %s, id %s
+	`, strings.Join(conds, " AND "), column.expr, order, order)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// listConditions translates the listing filters into WHERE conditions of
+// live subscriptions of the tenant and their arguments.
+func listConditions(tenantID string, dto dto.ListUserSubs) ([]string, []any, error) {
+	var (
+		conds = []string{"tenant_id = ?", "deleted_at IS NULL"}
+		args  = []any{tenantID}
+	)
+
+	if dto.UserID != uuid.Nil {
+		conds = append(conds, "user_id = ?")
+		args = append(args, dto.UserID)
+	}
+	if dto.ServiceName != "" {
+		conds = append(conds, "service_name = ?")
+		args = append(args, dto.ServiceName)
+	}
+	if dto.ServiceNamePrefix != "" {
+		conds = append(conds, `service_name LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(dto.ServiceNamePrefix)+"%")
+	}
+	if dto.MinPrice != nil {
+		conds = append(conds, "price >= ?")
+		args = append(args, toPriceUnits(*dto.MinPrice))
+	}
+	if dto.MaxPrice != nil {
+		conds = append(conds, "price <= ?")
+		args = append(args, toPriceUnits(*dto.MaxPrice))
+	}
+	if dto.ActiveOn != "" {
+		activeOn, err := billing.ParseDate(dto.ActiveOn)
+		if err != nil {
+			return nil, nil, err
+		}
+		conds = append(conds, "start_date <= ? AND (end_date IS NULL OR end_date >= ?)")
+		args = append(args, activeOn.To.Format(dateLayout), activeOn.From.Format(dateLayout))
+	}
+	for _, f := range []struct {
+		value string
+		cond  string
+		upper bool
+	}{
+		{dto.StartFrom, "start_date >= ?", false},
+		{dto.StartTo, "start_date <= ?", true},
+		{dto.EndFrom, "end_date >= ?", false},
+		{dto.EndTo, "end_date <= ?", true},
+	} {
+		if f.value == "" {
+			continue
+		}
+		date, err := billing.ParseDate(f.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		bound := date.From
+		if f.upper {
+			bound = date.To
+		}
+		conds = append(conds, f.cond)
+		args = append(args, bound.Format(dateLayout))
+	}
+
+	return conds, args, nil
+}
+
+func (s *Storage) DeleteUserSubscriptionByID(ctx context.Context, id int) error {
+	const op = "storage.sqlite.DeleteUserSubscriptionByID"
+
+	const query = `
+		UPDATE user_subscriptions
+		SET
+			deleted_at = ?,
+			updated_at = ?
+		WHERE id = ?
+		  AND tenant_id = ?
+		  AND deleted_at IS NULL
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := timestamp()
+
+	result, err := s.conn(ctx).ExecContext(ctx, query, now, now, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "storage.sqlite.RestoreUserSubscription"
+
+	const selectQuery = `
+		SELECT user_id, service_name, start_date, end_date
+		FROM user_subscriptions
+		WHERE id = ?
+		  AND tenant_id = ?
+		  AND deleted_at IS NOT NULL
+	`
+
+	const query = `
+		UPDATE user_subscriptions
+		SET
+			deleted_at = NULL,
+			updated_at = ?
+		WHERE id = ?
+		RETURNING
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var sub *domain.UserSubscription
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		tx := s.conn(ctx)
+
+		var (
+			userID      uuid.UUID
+			serviceName string
+			startDate   string
+			endDate     sql.NullString
+		)
+		err := tx.QueryRowContext(ctx, selectQuery, id, tenantID).Scan(&userID, &serviceName, &startDate, &endDate)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrNotFound
+			}
+			return err
+		}
+
+		var end *string
+		if endDate.Valid {
+			end = &endDate.String
+		}
+
+		if err := checkConstraints(ctx, tx, tenantID, id, userID, serviceName, startDate, end); err != nil {
+			return err
+		}
+
+		sub, err = scanSubscription(tx.QueryRowContext(ctx, query, timestamp(), id))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+// PurgeDeletedSubscriptions removes the subscriptions of every tenant
+// soft-deleted more than retention ago and returns them.
+func (s *Storage) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error) {
+	const op = "storage.sqlite.PurgeDeletedSubscriptions"
+
+	const query = `
+		DELETE FROM user_subscriptions
+		WHERE deleted_at IS NOT NULL
+		  AND deleted_at < ?
+		RETURNING
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+	`
+
+	deletedBefore := time.Now().UTC().Add(-retention).Format(timestampLayout)
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, deletedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subs []*domain.UserSubscription
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+func (s *Storage) UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
+	const op = "storage.sqlite.UpdateUserSubscription"
+
+	const query = `
+		UPDATE user_subscriptions
+		SET
+			service_name = ?,
+			price = ?,
+			currency = ?,
+			billing_period = ?,
+			user_id = ?,
+			start_date = ?,
+			end_date = ?,
+			updated_at = ?
+		WHERE id = ?
+		  AND tenant_id = ?
+		  AND deleted_at IS NULL
+		  AND (? IS NULL OR updated_at = ?)
+		RETURNING
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	startDate, endDate, err := parseDates(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version *string
+	if dto.Version != nil {
+		v := dto.Version.UTC().Format(timestampLayout)
+		version = &v
+	}
+
+	var sub *domain.UserSubscription
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		tx := s.conn(ctx)
+
+		if err := checkConstraints(ctx, tx, tenantID, dto.ID, dto.UserID, dto.ServiceName, startDate, endDate); err != nil {
+			return err
+		}
+
+		sub, err = scanSubscription(tx.QueryRowContext(
+			ctx,
+			query,
+			dto.ServiceName,
+			toPriceUnits(dto.Price),
+			dto.Currency,
+			dto.BillingPeriod,
+			dto.UserID,
+			startDate,
+			endDate,
+			timestamp(),
+			dto.ID,
+			tenantID,
+			version,
+			version,
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			if version != nil {
+				return versionMismatchOrNotFound(ctx, tx, tenantID, dto.ID)
+			}
+			return storage.ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+func (s *Storage) CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error) {
+	const op = "storage.sqlite.CalculateTotalCost"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	window, err := parseWindow(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subscriptions, err := s.activeSubscriptions(ctx, tenantID, dto.UserID, dto.ServiceName, window)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	totalCost, err := billing.TotalCost(subscriptions, window, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totalCost, nil
+}
+
+func (s *Storage) CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error) {
+	const op = "storage.sqlite.CostAnalytics"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	window, err := parseWindow(dto.StartDate, dto.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	analyzer, err := billing.NewAnalyzer(window, dto.GroupBy, rates, dto.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Rows are streamed, the subscriptions of the tenant may not fit in memory.
+	if err := s.eachActiveSubscription(ctx, tenantID, dto.UserID, dto.ServiceName, window, analyzer.Add); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return analyzer.Buckets(), nil
+}
+
+// activeSubscriptions returns subscriptions active at least one day of the
+// window. A nil user or an empty service name match all subscriptions of
+// the tenant.
+func (s *Storage) activeSubscriptions(
+	ctx context.Context,
+	tenantID string,
+	userID uuid.UUID,
+	serviceName string,
+	window billing.Period,
+) ([]*domain.UserSubscription, error) {
+	var subscriptions []*domain.UserSubscription
+	err := s.eachActiveSubscription(ctx, tenantID, userID, serviceName, window, func(sub *domain.UserSubscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// eachActiveSubscription calls fn with the subscriptions activeSubscriptions
+// returns, as they are read.
+func (s *Storage) eachActiveSubscription(
+	ctx context.Context,
+	tenantID string,
+	userID uuid.UUID,
+	serviceName string,
+	window billing.Period,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE tenant_id = ?
+		  AND deleted_at IS NULL
+		  AND (? OR user_id = ?)
+		  AND (? = '' OR service_name = ?)
+		  AND start_date <= ?
+		  AND (end_date IS NULL OR end_date >= ?)
+		ORDER BY user_subscriptions.start_date, id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query,
+		tenantID,
+		userID == uuid.Nil, userID,
+		serviceName, serviceName,
+		window.To.Format(dateLayout), window.From.Format(dateLayout),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// checkConstraints reproduces the unique_subscription and no_overlap
+// constraints of the Postgres schema, both scoped to the tenant. It has to
+// run in the same write transaction as the change it guards.
+func checkConstraints(
+	ctx context.Context,
+	tx storage.Querier,
+	tenantID string,
+	id int,
+	userID uuid.UUID,
+	serviceName string,
+	startDate string,
+	endDate *string,
+) error {
+	const uniqueQuery = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_subscriptions
+			WHERE id != ?
+			  AND tenant_id = ?
+			  AND deleted_at IS NULL
+			  AND user_id = ?
+			  AND service_name = ?
+			  AND start_date = ?
+			  AND end_date = ?
+		)
+	`
+
+	const overlapQuery = `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_subscriptions
+			WHERE id != ?
+			  AND tenant_id = ?
+			  AND deleted_at IS NULL
+			  AND user_id = ?
+			  AND service_name = ?
+			  AND start_date <= COALESCE(?, '` + openEnd + `')
+			  AND COALESCE(end_date, '` + openEnd + `') >= ?
+		)
+	`
+
+	var exists bool
+
+	if endDate != nil {
+		err := tx.QueryRowContext(ctx, uniqueQuery, id, tenantID, userID, serviceName, startDate, *endDate).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return storage.ErrUserSubExists
+		}
+	}
+
+	err := tx.QueryRowContext(ctx, overlapQuery, id, tenantID, userID, serviceName, endDate, startDate).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrOverlap
+	}
+
+	return nil
+}
+
+// versionMismatchOrNotFound tells apart a conditional update that lost the
+// race from one that targeted a missing row.
+func versionMismatchOrNotFound(ctx context.Context, tx storage.Querier, tenantID string, id int) error {
+	const query = "SELECT EXISTS (SELECT 1 FROM user_subscriptions WHERE id = ? AND tenant_id = ? AND deleted_at IS NULL)"
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, query, id, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrNotFound
+	}
+	return storage.ErrVersionMismatch
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSubscription reads the common subscription columns followed by extra
+// destinations selected after them.
+func scanSubscription(row scanner, extra ...any) (*domain.UserSubscription, error) {
+	var sub domain.UserSubscription
+	var price int64
+	var endDate sql.NullString
+	var updatedAt string
+
+	dest := append([]any{
+		&sub.ID,
+		&sub.ServiceName,
+		&price,
+		&sub.Currency,
+		&sub.BillingPeriod,
+		&sub.UserID,
+		&sub.StartDate,
+		&endDate,
+		&updatedAt,
+		&sub.TenantID,
+	}, extra...)
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	sub.Price = fromPriceUnits(price)
+	if endDate.Valid {
+		sub.EndDate = endDate.String
+	}
+
+	t, err := time.Parse(timestampLayout, updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sub.UpdatedAt = t
+
+	return &sub, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func parseWindow(startDateStr, endDateStr string) (billing.Period, error) {
+	startDate, err := billing.ParseDate(startDateStr)
+	if err != nil {
+		return billing.Period{}, err
+	}
+
+	var endDate *time.Time
+	if endDateStr != "" {
+		p, err := billing.ParseDate(endDateStr)
+		if err != nil {
+			return billing.Period{}, err
+		}
+		endDate = &p.To
+	}
+
+	return billing.NewPeriod(startDate.From, endDate, time.Now()), nil
+}
+
+// parseDates converts API dates into the ISO dates stored by SQLite. A month
+// starts on its first day and ends on its last.
+func parseDates(startDateStr, endDateStr string) (string, *string, error) {
+	startDate, err := billing.ParseDate(startDateStr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if endDateStr == "" {
+		return startDate.From.Format(dateLayout), nil, nil
+	}
+
+	endDate, err := billing.ParseDate(endDateStr)
+	if err != nil {
+		return "", nil, err
+	}
+	end := endDate.To.Format(dateLayout)
+
+	return startDate.From.Format(dateLayout), &end, nil
+}
+
+// toPriceUnits converts a price to the integer count of 10^-PricePlaces
+// units it is stored as, SQLite has no exact decimal type.
+func toPriceUnits(price decimal.Decimal) int64 {
+	return price.Shift(domain.PricePlaces).IntPart()
+}
+
+func fromPriceUnits(units int64) decimal.Decimal {
+	return decimal.New(units, -domain.PricePlaces)
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(timestampLayout)
+}
@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/idempotency"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/storage"
+	"subscription/internal/storage/connect"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// newStorage returns a storage kept in a fresh database migrated to the last
+// version.
+func newStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", connect.SQLiteDSN(filepath.Join(t.TempDir(), "subscriptions.db")))
+	if err != nil {
+		t.Fatalf("can't open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := migratesqlite.WithInstance(db, &migratesqlite.Config{})
+	if err != nil {
+		t.Fatalf("can't create migration driver: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../../migrations/sqlite", "sqlite", driver)
+	if err != nil {
+		t.Fatalf("can't read migrations: %v", err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("can't migrate: %v", err)
+	}
+
+	return New(db)
+}
+
+func subscription(user uuid.UUID, service, start, end string) dto.CreateUserSubDTO {
+	return dto.CreateUserSubDTO{
+		ServiceName:   service,
+		Price:         decimal.NewFromInt(100),
+		Currency:      "RUB",
+		BillingPeriod: "monthly",
+		UserID:        user,
+		StartDate:     start,
+		EndDate:       end,
+	}
+}
+
+func TestSubscriptionConstraints(t *testing.T) {
+	s := newStorage(t)
+	ctx := tenant.WithTenant(context.Background(), "default")
+	user, other := uuid.New(), uuid.New()
+
+	first, err := s.AddUserSubscription(ctx, subscription(user, "Netflix", "2025-01-01", "2025-06-30"))
+	if err != nil {
+		t.Fatalf("AddUserSubscription() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		sub    dto.CreateUserSubDTO
+		want   error
+	}{
+		{"same subscription", "", subscription(user, "Netflix", "2025-01-01", "2025-06-30"), storage.ErrUserSubExists},
+		{"overlapping", "", subscription(user, "Netflix", "2025-03-01", "2025-12-31"), storage.ErrOverlap},
+		{"within", "", subscription(user, "Netflix", "2025-02-01", "2025-02-28"), storage.ErrOverlap},
+		{"around", "", subscription(user, "Netflix", "2024-12-01", "2025-07-31"), storage.ErrOverlap},
+		{"open-ended overlapping", "", subscription(user, "Netflix", "2024-01-01", ""), storage.ErrOverlap},
+		{"sharing the last day", "", subscription(user, "Netflix", "2025-06-30", "2025-08-31"), storage.ErrOverlap},
+		{"another service", "", subscription(user, "Spotify", "2025-01-01", "2025-06-30"), nil},
+		{"another user", "", subscription(other, "Netflix", "2025-01-01", "2025-06-30"), nil},
+		{"another tenant", "acme", subscription(user, "Netflix", "2025-01-01", "2025-06-30"), nil},
+		{"one day", "", subscription(user, "Hulu", "2025-01-01", "2025-01-01"), nil},
+		{"right after", "", subscription(user, "Netflix", "2025-07-01", "2025-12-31"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := ctx
+			if tt.tenant != "" {
+				ctx = tenant.WithTenant(ctx, tt.tenant)
+			}
+
+			_, err := s.AddUserSubscription(ctx, tt.sub)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("AddUserSubscription() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("ending before it starts", func(t *testing.T) {
+		if _, err := s.AddUserSubscription(ctx, subscription(user, "Kinopoisk", "2025-01-02", "2025-01-01")); err == nil {
+			t.Error("AddUserSubscription() error = nil, want an error")
+		}
+	})
+
+	t.Run("update into an overlap", func(t *testing.T) {
+		id, err := s.AddUserSubscription(ctx, subscription(user, "Spotify", "2027-01-01", "2027-12-31"))
+		if err != nil {
+			t.Fatalf("AddUserSubscription() error = %v", err)
+		}
+
+		sub := subscription(user, "Spotify", "2026-01-01", "2026-12-31")
+		update := dto.UpdateUserSubDTO{
+			ID:            int(id),
+			ServiceName:   sub.ServiceName,
+			Price:         sub.Price,
+			Currency:      sub.Currency,
+			BillingPeriod: sub.BillingPeriod,
+			UserID:        sub.UserID,
+			StartDate:     sub.StartDate,
+			EndDate:       sub.EndDate,
+		}
+		if _, err := s.UpdateUserSubscription(ctx, update); err != nil {
+			t.Fatalf("moving to free days: %v", err)
+		}
+
+		update.StartDate = "2025-06-01"
+		if _, err := s.UpdateUserSubscription(ctx, update); !errors.Is(err, storage.ErrOverlap) {
+			t.Errorf("moving into an overlap: error = %v, want %v", err, storage.ErrOverlap)
+		}
+	})
+
+	t.Run("deleted subscriptions free their days", func(t *testing.T) {
+		if err := s.DeleteUserSubscriptionByID(ctx, int(first)); err != nil {
+			t.Fatalf("DeleteUserSubscriptionByID() error = %v", err)
+		}
+
+		if _, err := s.AddUserSubscription(ctx, subscription(user, "Netflix", "2025-01-01", "2025-06-30")); err != nil {
+			t.Fatalf("AddUserSubscription() over a deleted one: %v", err)
+		}
+
+		if _, err := s.RestoreUserSubscription(ctx, int(first)); !errors.Is(err, storage.ErrUserSubExists) {
+			t.Errorf("restoring over the replacement: error = %v, want %v", err, storage.ErrUserSubExists)
+		}
+	})
+}
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	s := newStorage(t)
+	ctx := context.Background()
+	now := time.Now()
+	const ttl, lock = time.Hour, time.Minute
+
+	reserve := func(fingerprint string, at time.Time) *idempotency.Record {
+		t.Helper()
+
+		rec, err := s.ReserveIdempotencyKey(ctx, "key", fingerprint, at, at.Add(-ttl), at.Add(-lock))
+		if err != nil {
+			t.Fatalf("ReserveIdempotencyKey() error = %v", err)
+		}
+		return rec
+	}
+
+	if rec := reserve("a", now); rec != nil {
+		t.Fatalf("first reservation returned %+v", rec)
+	}
+	if rec := reserve("b", now.Add(time.Second)); rec == nil || rec.Fingerprint != "a" || rec.Response != nil {
+		t.Fatalf("reservation in progress returned %+v, want the one of a", rec)
+	}
+
+	// A reservation left behind by a request that never finished is taken
+	// over.
+	if rec := reserve("b", now.Add(2*lock)); rec != nil {
+		t.Fatalf("stale reservation returned %+v, want it taken over", rec)
+	}
+
+	if err := s.SaveIdempotentResponse(ctx, "key", idempotency.Response{Status: 201, ContentType: "application/json", Body: []byte("{}")}); err != nil {
+		t.Fatalf("SaveIdempotentResponse() error = %v", err)
+	}
+	rec := reserve("b", now.Add(3*lock))
+	if rec == nil || rec.Fingerprint != "b" || rec.Response == nil || rec.Response.Status != 201 || string(rec.Response.Body) != "{}" {
+		t.Fatalf("completed reservation returned %+v, want the response of b", rec)
+	}
+
+	// Responses are kept until they expire, however old.
+	if rec := reserve("c", now.Add(ttl)); rec == nil || rec.Fingerprint != "b" {
+		t.Fatalf("unexpired response returned %+v, want the one of b", rec)
+	}
+	if rec := reserve("c", now.Add(2*ttl)); rec != nil {
+		t.Fatalf("expired response returned %+v, want it taken over", rec)
+	}
+}
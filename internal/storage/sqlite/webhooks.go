@@ -0,0 +1,535 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/storage"
+	"time"
+)
+
+const deliveryColumns = `
+	id,
+	webhook_id,
+	event_id,
+	event,
+	payload,
+	status,
+	attempts,
+	next_attempt_at,
+	COALESCE(last_status_code, 0),
+	COALESCE(last_error, ''),
+	created_at,
+	delivered_at,
+	tenant_id
+`
+
+func (s *Storage) AddWebhook(ctx context.Context, hook *domain.Webhook) error {
+	const op = "storage.sqlite.AddWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	events, err := json.Marshal(hook.Events)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		"INSERT INTO webhooks (url, events, secret, created_at, tenant_id) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		hook.URL,
+		string(events),
+		hook.Secret,
+		createdAt.Format(timestampLayout),
+		tenantID,
+	).Scan(&hook.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	hook.CreatedAt = createdAt
+
+	return nil
+}
+
+func (s *Storage) ListWebhooks(ctx context.Context) ([]*domain.Webhook, error) {
+	const op = "storage.sqlite.ListWebhooks"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hooks, err := s.selectWebhooks(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE tenant_id = ? ORDER BY id", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hooks, nil
+}
+
+func (s *Storage) GetWebhook(ctx context.Context, id int) (*domain.Webhook, error) {
+	const op = "storage.sqlite.GetWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hooks, err := s.selectWebhooks(ctx, "SELECT id, url, events, secret, created_at FROM webhooks WHERE id = ? AND tenant_id = ?", id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	return hooks[0], nil
+}
+
+// DeleteWebhook removes the webhook, its deliveries go with it.
+func (s *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	const op = "storage.sqlite.DeleteWebhook"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := s.conn(ctx).ExecContext(ctx, "DELETE FROM webhooks WHERE id = ? AND tenant_id = ?", id, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebhookNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebhooksFor(ctx context.Context, event string) ([]*domain.Webhook, error) {
+	const op = "storage.sqlite.WebhooksFor"
+
+	const query = `
+		SELECT id, url, events, secret, created_at
+		FROM webhooks
+		WHERE tenant_id = ?
+		  AND EXISTS (SELECT 1 FROM json_each(webhooks.events) WHERE value = ?)
+		ORDER BY id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	hooks, err := s.selectWebhooks(ctx, query, tenantID, event)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hooks, nil
+}
+
+func (s *Storage) selectWebhooks(ctx context.Context, query string, args ...any) ([]*domain.Webhook, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*domain.Webhook
+
+	for rows.Next() {
+		var hook domain.Webhook
+		var events, createdAt string
+
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.Secret, &createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(events), &hook.Events); err != nil {
+			return nil, err
+		}
+		if hook.CreatedAt, err = time.Parse(timestampLayout, createdAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+func (s *Storage) AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	const op = "storage.sqlite.AddWebhookDelivery"
+
+	const query = `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, next_attempt_at, created_at, tenant_id)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?5, ?6)
+		RETURNING id
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		query,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.Event,
+		string(delivery.Payload),
+		now.Format(timestampLayout),
+		tenantID,
+	).Scan(&delivery.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	delivery.Status = domain.DeliveryPending
+	delivery.NextAttemptAt = now
+	delivery.CreatedAt = now
+	delivery.TenantID = tenantID
+
+	return nil
+}
+
+// ClaimWebhookDeliveries returns the oldest pending deliveries of every
+// tenant that are due and postpones them by lease. Write transactions lock the whole database,
+// so concurrent dispatchers never claim the same delivery.
+func (s *Storage) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	const op = "storage.sqlite.ClaimWebhookDeliveries"
+
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = ?2
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= ?1
+			ORDER BY next_attempt_at, id
+			LIMIT ?3
+		)
+		RETURNING ` + deliveryColumns
+
+	deliveries, err := s.selectDeliveries(
+		ctx,
+		query,
+		now.UTC().Format(timestampLayout),
+		now.UTC().Add(lease).Format(timestampLayout),
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) RecordWebhookAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error {
+	const op = "storage.sqlite.RecordWebhookAttempt"
+
+	const update = `
+		UPDATE webhook_deliveries
+		SET status = ?2,
+			attempts = ?3,
+			next_attempt_at = ?4,
+			last_status_code = NULLIF(?5, 0),
+			last_error = NULLIF(?6, ''),
+			delivered_at = ?7
+		WHERE id = ?1
+	`
+
+	var deliveredAt sql.NullString
+	if delivery.DeliveredAt != nil {
+		deliveredAt = sql.NullString{String: delivery.DeliveredAt.UTC().Format(timestampLayout), Valid: true}
+	}
+
+	result, err := s.conn(ctx).ExecContext(
+		ctx,
+		update,
+		delivery.ID,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC().Format(timestampLayout),
+		delivery.LastStatusCode,
+		delivery.LastError,
+		deliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+	}
+
+	const insert = `
+		INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+		VALUES (?, ?, NULLIF(?, 0), NULLIF(?, ''), ?, ?)
+		RETURNING id
+	`
+
+	err = s.conn(ctx).QueryRowContext(
+		ctx,
+		insert,
+		attempt.DeliveryID,
+		attempt.Attempt,
+		attempt.StatusCode,
+		attempt.Error,
+		attempt.DurationMs,
+		attempt.AttemptedAt.UTC().Format(timestampLayout),
+	).Scan(&attempt.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListWebhookDeliveries(ctx context.Context, webhookID int, filter dto.ListWebhookDeliveries) ([]*domain.WebhookDelivery, error) {
+	const op = "storage.sqlite.ListWebhookDeliveries"
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = ?1 AND tenant_id = ?4 AND (?2 = '' OR status = ?2)
+		ORDER BY id DESC
+		LIMIT ?3
+	`
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := s.selectDeliveries(ctx, query, webhookID, filter.Status, filter.Limit, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *Storage) GetWebhookDelivery(ctx context.Context, id int) (*domain.WebhookDelivery, error) {
+	const op = "storage.sqlite.GetWebhookDelivery"
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := s.selectDeliveries(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ? AND tenant_id = ?", id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+	}
+	delivery := deliveries[0]
+
+	const query = `
+		SELECT
+			id,
+			delivery_id,
+			attempt,
+			COALESCE(status_code, 0),
+			COALESCE(error, ''),
+			duration_ms,
+			attempted_at
+		FROM webhook_attempts
+		WHERE delivery_id = ?
+		ORDER BY id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	delivery.Log = []*domain.WebhookAttempt{}
+
+	for rows.Next() {
+		var a domain.WebhookAttempt
+		var attemptedAt string
+
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &attemptedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if a.AttemptedAt, err = time.Parse(timestampLayout, attemptedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		delivery.Log = append(delivery.Log, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+func (s *Storage) RedeliverWebhookDelivery(ctx context.Context, id int, now time.Time) (*domain.WebhookDelivery, error) {
+	const op = "storage.sqlite.RedeliverWebhookDelivery"
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND tenant_id = ?
+		RETURNING ` + deliveryColumns
+
+	tenantID, err := storage.Tenant(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := s.selectDeliveries(ctx, query, now.UTC().Format(timestampLayout), id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotFound)
+	}
+
+	return deliveries[0], nil
+}
+
+func (s *Storage) selectDeliveries(ctx context.Context, query string, args ...any) ([]*domain.WebhookDelivery, error) {
+	rows, err := s.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*domain.WebhookDelivery
+
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var payload, nextAttemptAt, createdAt string
+		var deliveredAt sql.NullString
+
+		if err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.Event,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&nextAttemptAt,
+			&d.LastStatusCode,
+			&d.LastError,
+			&createdAt,
+			&deliveredAt,
+			&d.TenantID,
+		); err != nil {
+			return nil, err
+		}
+
+		d.Payload = json.RawMessage(payload)
+		if d.NextAttemptAt, err = time.Parse(timestampLayout, nextAttemptAt); err != nil {
+			return nil, err
+		}
+		if d.CreatedAt, err = time.Parse(timestampLayout, createdAt); err != nil {
+			return nil, err
+		}
+		if deliveredAt.Valid {
+			t, err := time.Parse(timestampLayout, deliveredAt.String)
+			if err != nil {
+				return nil, err
+			}
+			d.DeliveredAt = &t
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ExpiringSubscriptions looks at the subscriptions of every tenant.
+func (s *Storage) ExpiringSubscriptions(ctx context.Context, from, to time.Time) ([]*domain.UserSubscription, error) {
+	const op = "storage.sqlite.ExpiringSubscriptions"
+
+	const query = `
+		SELECT
+			id,
+			service_name,
+			price,
+			currency,
+			billing_period,
+			user_id,
+			start_date,
+			end_date,
+			updated_at,
+			tenant_id
+		FROM user_subscriptions
+		WHERE deleted_at IS NULL AND end_date BETWEEN ? AND ?
+		ORDER BY id
+	`
+
+	rows, err := s.conn(ctx).QueryContext(ctx, query, from.Format(dateLayout), to.Format(dateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var subs []*domain.UserSubscription
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+// AddExpiryNotice records that event was sent for the subscription ending on
+// endDate. It reports false when it was sent already.
+func (s *Storage) AddExpiryNotice(ctx context.Context, subscriptionID int64, event, endDate string) (bool, error) {
+	const op = "storage.sqlite.AddExpiryNotice"
+
+	const query = `
+		INSERT INTO expiry_notices (subscription_id, event, end_date, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING subscription_id
+	`
+
+	var id int64
+	if err := s.conn(ctx).QueryRowContext(ctx, query, subscriptionID, event, endDate, timestamp()).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
@@ -3,8 +3,15 @@ package storage
 import "errors"
 
 var (
-	ErrNotFound      = errors.New("user_subscription not found")
-	ErrUserSubExists = errors.New("user_subscription already exists")
-	ErrUserNotFound  = errors.New("user not found")
-	ErrOverlap       = errors.New("user subscription conflicts with existing record")
+	ErrNotFound        = errors.New("user_subscription not found")
+	ErrUserSubExists   = errors.New("user_subscription already exists")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrOverlap         = errors.New("user subscription conflicts with existing record")
+	ErrVersionMismatch = errors.New("user subscription was modified concurrently")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	ErrTenantNotFound = errors.New("tenant not found")
+	ErrNoTenant       = errors.New("no tenant in context")
 )
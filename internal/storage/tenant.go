@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+	"subscription/internal/lib/tenant"
+)
+
+// Tenant returns the tenant ctx is scoped to. Tenant data is never read or
+// written without one, a context missing it fails instead of reaching
+// across tenants.
+func Tenant(ctx context.Context) (string, error) {
+	id, ok := tenant.FromContext(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+	return id, nil
+}
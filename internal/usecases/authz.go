@@ -0,0 +1,49 @@
+package usecases
+
+import (
+	"context"
+	"subscription/internal/domain"
+	"subscription/internal/lib/auth"
+	"subscription/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// authorize checks that ctx may touch the subscriptions of userID.
+func authorize(ctx context.Context, userID uuid.UUID) error {
+	if !auth.CanAccess(ctx, userID) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+// owned reports the subscriptions of other users as missing, so that their
+// ids can't be probed.
+func owned(ctx context.Context, sub *domain.UserSubscription) error {
+	if !auth.CanAccess(ctx, sub.UserID) {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// scope limits a user_id filter to the user ctx is restricted to. An empty
+// filter defaults to that user, another user is forbidden.
+func scope(ctx context.Context, userID *uuid.UUID) error {
+	restricted, ok := auth.Restricted(ctx)
+	if !ok {
+		return nil
+	}
+	if *userID == uuid.Nil {
+		*userID = restricted
+	}
+	return authorize(ctx, *userID)
+}
+
+// boundTenant returns the tenant the principal of ctx is bound to. Platform
+// callers aren't bound to any and get an empty id.
+func boundTenant(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.Tenant
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"subscription/internal/domain"
+	"subscription/internal/lib/actor"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tracing"
+	"subscription/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type HistoryStorage interface {
+	AddHistoryRecord(ctx context.Context, rec *domain.HistoryRecord) error
+	GetHistory(ctx context.Context, subscriptionID int) ([]*domain.HistoryRecord, error)
+}
+
+func (s *UserSubscriptionService) History(ctx context.Context, id int) ([]*domain.HistoryRecord, error) {
+	const op = "subscription_service.History"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	records, err := s.history.GetHistory(ctx, id)
+	if err != nil {
+		s.log.Error("can't get subscription history", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(records) == 0 {
+		tracing.Fail(span, storage.ErrNotFound)
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	// Purges don't snapshot the subscription, every other record names
+	// its owner.
+	for _, rec := range records {
+		sub := rec.After
+		if sub == nil {
+			sub = rec.Before
+		}
+		if sub != nil {
+			if err := owned(ctx, sub); err != nil {
+				tracing.Fail(span, err)
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			break
+		}
+	}
+
+	return records, nil
+}
+
+// actionEvents names the webhook event sent for a recorded change. A restored
+// subscription is announced as updated, purges aren't announced.
+var actionEvents = map[string]string{
+	domain.ActionCreated:  domain.EventSubscriptionCreated,
+	domain.ActionUpdated:  domain.EventSubscriptionUpdated,
+	domain.ActionRestored: domain.EventSubscriptionUpdated,
+	domain.ActionDeleted:  domain.EventSubscriptionDeleted,
+}
+
+// record writes a history entry attributed to the actor and request of ctx
+// and queues the matching webhook and broker events. It must run in the
+// transaction of the change it describes.
+func (s *UserSubscriptionService) record(
+	ctx context.Context,
+	id int64,
+	action string,
+	before, after *domain.UserSubscription,
+) error {
+	err := s.history.AddHistoryRecord(ctx, &domain.HistoryRecord{
+		SubscriptionID: id,
+		Action:         action,
+		Before:         before,
+		After:          after,
+		Actor:          actor.FromContext(ctx),
+		RequestID:      middleware.GetReqID(ctx),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := publish(ctx, s.outbox, id, action, before, after); err != nil {
+		return err
+	}
+
+	event, ok := actionEvents[action]
+	if !ok {
+		return nil
+	}
+
+	sub := after
+	if sub == nil {
+		sub = before
+	}
+
+	return notify(ctx, s.webhooks, event, domain.WebhookEventData{Subscription: sub})
+}
@@ -0,0 +1,99 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tracing"
+)
+
+// Import creates subs in order. An atomic import runs in one transaction and
+// stops at the first rejected row, whose result then carries the error while
+// no row keeps its ID. Otherwise every row is stored on its own and failures
+// don't affect the other rows.
+//
+// When ctx ends first, the rows not stored carry ErrImportInterrupted: every
+// row of an atomic import, the rest of the rows otherwise.
+func (s *UserSubscriptionService) Import(
+	ctx context.Context,
+	subs []dto.CreateUserSubDTO,
+	atomic bool,
+) ([]domain.ImportResult, error) {
+	const op = "subscription_service.Import"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	results := make([]domain.ImportResult, len(subs))
+
+	if !atomic {
+		for i, sub := range subs {
+			err := ctx.Err()
+			if err == nil {
+				err = s.storage.WithinTx(ctx, func(ctx context.Context) error {
+					var err error
+					results[i].ID, err = s.add(ctx, sub)
+					return err
+				})
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					s.log.Warn("import interrupted", slog.Int("row", i), sl.Err(ctx.Err()))
+					tracing.Fail(span, ctx.Err())
+					interrupt(results[i:], ctx.Err())
+					break
+				}
+				results[i].Err = err
+			}
+		}
+
+		return results, nil
+	}
+
+	failed := -1
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		for i, sub := range subs {
+			id, err := s.add(ctx, sub)
+			if err != nil {
+				failed = i
+				return err
+			}
+			results[i].ID = id
+		}
+		return nil
+	})
+	if err != nil {
+		s.log.Error("import aborted", slog.Int("row", failed), sl.Err(err))
+
+		if ctx.Err() != nil {
+			tracing.Fail(span, ctx.Err())
+			interrupt(results, ctx.Err())
+			return results, nil
+		}
+		if failed < 0 {
+			tracing.Fail(span, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for i := range results {
+			results[i].ID = 0
+		}
+		results[failed].Err = err
+
+		return results, nil
+	}
+
+	return results, nil
+}
+
+// interrupt marks results as not stored because of err, the error of the
+// context of the import.
+func interrupt(results []domain.ImportResult, err error) {
+	for i := range results {
+		results[i].ID = 0
+		results[i].Err = fmt.Errorf("%w: %w", domain.ErrImportInterrupted, err)
+	}
+}
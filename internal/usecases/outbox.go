@@ -0,0 +1,145 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"subscription/internal/domain"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tenant"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OutboxStorage interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	AddOutboxEvent(ctx context.Context, ev *domain.OutboxEvent) error
+	TryLockOutbox(ctx context.Context) (bool, error)
+	ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.OutboxEvent, error)
+	ReleaseOutboxEvents(ctx context.Context, ids []int64) error
+	DeleteOutboxEvents(ctx context.Context, ids []int64) error
+}
+
+// EventPublisher delivers events to a message broker. Publish returns once
+// every event was accepted or fails as a whole, events sharing a key must
+// reach consumers in the order given.
+type EventPublisher interface {
+	Publish(ctx context.Context, events []*domain.OutboxEvent) error
+}
+
+// outboxEvents names the event published for a recorded change. Purges
+// aren't published, the subscription they removed is gone.
+var outboxEvents = map[string]string{
+	domain.ActionCreated:  domain.EventSubscriptionCreated,
+	domain.ActionUpdated:  domain.EventSubscriptionUpdated,
+	domain.ActionRestored: domain.EventSubscriptionRestored,
+	domain.ActionDeleted:  domain.EventSubscriptionDeleted,
+}
+
+// publish writes the event describing a change to the outbox. Like notify it
+// must run in the transaction of the change. Events are keyed by user, so
+// that the changes of one user are published in order.
+func publish(ctx context.Context, outbox OutboxStorage, id int64, action string, before, after *domain.UserSubscription) error {
+	event, ok := outboxEvents[action]
+	if !ok {
+		return nil
+	}
+
+	tenantID, _ := tenant.FromContext(ctx)
+
+	payload := domain.SubscriptionEvent{
+		ID:             uuid.NewString(),
+		Type:           event,
+		OccurredAt:     time.Now().UTC(),
+		TenantID:       tenantID,
+		SubscriptionID: id,
+		Before:         before,
+		After:          after,
+	}
+	if after != nil {
+		payload.UserID = after.UserID
+	} else if before != nil {
+		payload.UserID = before.UserID
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return outbox.AddOutboxEvent(ctx, &domain.OutboxEvent{
+		EventID: payload.ID,
+		Type:    event,
+		Key:     payload.UserID.String(),
+		Payload: raw,
+	})
+}
+
+type OutboxRelay struct {
+	log       *slog.Logger
+	storage   OutboxStorage
+	publisher EventPublisher
+	// lease bounds how long publishing a batch may take. Until it runs
+	// out no other relay publishes, so the events of a key can't overtake
+	// each other.
+	lease time.Duration
+}
+
+func NewOutboxRelay(storage OutboxStorage, publisher EventPublisher, lease time.Duration, log *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{storage: storage, publisher: publisher, lease: lease, log: log}
+}
+
+// Relay publishes up to limit of the oldest outbox events and removes them
+// from the outbox. Events are removed only after the publisher accepted
+// them, so a failure or crash in between publishes them again: delivery is
+// at least once and consumers deduplicate on the event ID. A failed batch is
+// released and retried as a whole on the next run, which keeps the order per
+// key. It returns the number of events published.
+func (r *OutboxRelay) Relay(ctx context.Context, limit int) (int, error) {
+	const op = "outbox_relay.Relay"
+
+	var events []*domain.OutboxEvent
+	err := r.storage.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := r.storage.TryLockOutbox(ctx)
+		if err != nil || !locked {
+			return err
+		}
+
+		events, err = r.storage.ClaimOutboxEvents(ctx, time.Now().UTC(), r.lease, limit)
+		return err
+	})
+	if err != nil {
+		r.log.Error("can't claim outbox events", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(events))
+	for i, ev := range events {
+		ids[i] = ev.ID
+	}
+
+	// The batch is published outside of a transaction, a slow broker
+	// mustn't hold up writers.
+	publishCtx, cancel := context.WithTimeout(ctx, r.lease)
+	defer cancel()
+
+	if err := r.publisher.Publish(publishCtx, events); err != nil {
+		r.log.Error("can't publish outbox events", slog.Int("count", len(events)), sl.Err(err))
+		if err := r.storage.ReleaseOutboxEvents(ctx, ids); err != nil {
+			r.log.Error("can't release outbox events", sl.Err(err))
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := r.storage.DeleteOutboxEvents(ctx, ids); err != nil {
+		r.log.Error("can't delete published outbox events", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,191 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/lib/auth"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/lib/tracing"
+	"time"
+)
+
+type BillingEventStorage interface {
+	// TryLockRenewals takes the lock of the replica running renewals until
+	// unlock is called, it reports false when another replica holds it.
+	TryLockRenewals(ctx context.Context) (unlock func(), locked bool, err error)
+	DueRenewals(ctx context.Context, today time.Time) ([]*domain.DueRenewal, error)
+	AddBillingEvent(ctx context.Context, ev *domain.BillingEvent) (bool, error)
+	GetBillingEvents(ctx context.Context, subscriptionID int) ([]*domain.BillingEvent, error)
+}
+
+// MaxRenewalsPerRun bounds the billing events a run emits. Periods left over
+// are billed by the next runs.
+const MaxRenewalsPerRun = 1000
+
+// Renew emits a billing event for every billing period that began by now and
+// has none yet. Periods already billed are skipped, so running it again, or
+// on several replicas at once, never bills a period twice. Every subscription
+// is billed in a transaction of its own, a failing one is left for the next
+// run. It returns the number of events emitted.
+func (s *UserSubscriptionService) Renew(ctx context.Context, now time.Time) (int, error) {
+	const op = "subscription_service.Renew"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	unlock, locked, err := s.events.TryLockRenewals(ctx)
+	if err != nil {
+		s.log.Error("can't lock renewals", sl.Err(err))
+		tracing.Fail(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer unlock()
+	if !locked {
+		s.log.Debug("renewals are running elsewhere, skipping")
+		return 0, nil
+	}
+
+	due, err := s.events.DueRenewals(ctx, today)
+	if err != nil {
+		s.log.Error("can't get due renewals", sl.Err(err))
+		tracing.Fail(span, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var emitted, failed int
+	for _, d := range due {
+		if emitted >= MaxRenewalsPerRun {
+			s.log.Info("renewal limit reached, the rest is left for the next run", slog.Int("limit", MaxRenewalsPerRun))
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			tracing.Fail(span, err)
+			return emitted, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var n int
+		err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			n, err = s.renew(ctx, d, today, MaxRenewalsPerRun-emitted)
+			return err
+		})
+		if err != nil {
+			s.log.Error("can't renew subscription", slog.String("subscription_id", d.Subscription.ID), sl.Err(err))
+			failed++
+			continue
+		}
+		emitted += n
+	}
+
+	if failed > 0 {
+		err := fmt.Errorf("%d subscriptions not renewed", failed)
+		tracing.Fail(span, err)
+		return emitted, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return emitted, nil
+}
+
+// renew bills at most limit due periods of one subscription. A subscription
+// whose periods can't be computed is logged and left for the next run rather
+// than holding back every other renewal.
+func (s *UserSubscriptionService) renew(ctx context.Context, due *domain.DueRenewal, today time.Time, limit int) (int, error) {
+	sub := due.Subscription
+	ctx = tenant.WithTenant(ctx, sub.TenantID)
+
+	renewals, err := billing.Renewals(sub, due.BilledUntil, today)
+	if err != nil {
+		s.log.Error("can't compute renewals", slog.String("subscription_id", sub.ID), sl.Err(err))
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(sub.ID, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	// Periods are billed in order, those cut off follow the last billed.
+	if len(renewals) > limit {
+		renewals = renewals[:limit]
+	}
+
+	var emitted int
+	for _, r := range renewals {
+		ev := &domain.BillingEvent{
+			SubscriptionID: id,
+			Type:           domain.BillingEventRenewal,
+			PeriodStart:    r.Period.From.Format(billing.DateLayout),
+			PeriodEnd:      r.Period.To.Format(billing.DateLayout),
+			Amount:         r.Amount,
+			Currency:       sub.Currency,
+		}
+		if r.Initial {
+			ev.Type = domain.BillingEventCharge
+		}
+
+		added, err := s.events.AddBillingEvent(ctx, ev)
+		if err != nil {
+			return 0, err
+		}
+		if !added {
+			continue
+		}
+		emitted++
+
+		event := domain.EventSubscriptionRenewed
+		if ev.Type == domain.BillingEventCharge {
+			event = domain.EventSubscriptionStarted
+		}
+		if err := notify(ctx, s.webhooks, event, domain.WebhookEventData{Subscription: sub, BillingEvent: ev}); err != nil {
+			return 0, err
+		}
+	}
+
+	return emitted, nil
+}
+
+func (s *UserSubscriptionService) BillingEvents(ctx context.Context, id int) ([]*domain.BillingEvent, error) {
+	const op = "subscription_service.BillingEvents"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	// Restricted callers may only see the events of their own live
+	// subscriptions.
+	if _, ok := auth.Restricted(ctx); ok {
+		sub, err := s.storage.GetUserSubscriptionById(ctx, id)
+		if err == nil {
+			err = owned(ctx, sub)
+		}
+		if err != nil {
+			tracing.Fail(span, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	events, err := s.events.GetBillingEvents(ctx, id)
+	if err != nil {
+		s.log.Error("can't get billing events", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Purged subscriptions keep their events, so only an unknown
+	// subscription without any is reported as missing.
+	if len(events) == 0 {
+		if _, err := s.storage.GetUserSubscriptionById(ctx, id); err != nil {
+			tracing.Fail(span, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return []*domain.BillingEvent{}, nil
+	}
+
+	return events, nil
+}
@@ -0,0 +1,110 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/storage/memory"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// failingEvents fails to store the billing events of one subscription.
+type failingEvents struct {
+	*memory.Storage
+	fail int64
+}
+
+func (f *failingEvents) AddBillingEvent(ctx context.Context, ev *domain.BillingEvent) (bool, error) {
+	if ev.SubscriptionID == f.fail {
+		return false, errors.New("storage is down")
+	}
+	return f.Storage.AddBillingEvent(ctx, ev)
+}
+
+func addSubscription(t *testing.T, ctx context.Context, store *memory.Storage, service, period, start string) int64 {
+	t.Helper()
+
+	id, err := store.AddUserSubscription(ctx, dto.CreateUserSubDTO{
+		ServiceName:   service,
+		Price:         decimal.NewFromInt(100),
+		Currency:      "RUB",
+		BillingPeriod: period,
+		UserID:        uuid.New(),
+		StartDate:     start,
+	})
+	if err != nil {
+		t.Fatalf("AddUserSubscription() error = %v", err)
+	}
+	return id
+}
+
+func TestRenewLimit(t *testing.T) {
+	store := memory.New()
+	s := NewSubscriptionService(store, store, store, store, store, nil, slog.New(slog.DiscardHandler))
+	ctx := tenant.WithTenant(context.Background(), "default")
+	now := time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+	// Weekly since 2000, some 1300 periods are due.
+	addSubscription(t, ctx, store, "Netflix", "weekly", "2000-01-01")
+
+	var total int
+	for run := 1; ; run++ {
+		n, err := s.Renew(ctx, now)
+		if err != nil {
+			t.Fatalf("Renew() error = %v", err)
+		}
+		if n > MaxRenewalsPerRun {
+			t.Fatalf("run %d emitted %d events, limit is %d", run, n, MaxRenewalsPerRun)
+		}
+		if n == 0 {
+			break
+		}
+		if run > 2 {
+			t.Fatalf("run %d still emits events", run)
+		}
+		total += n
+	}
+
+	if want := int(now.Sub(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC))/(7*24*time.Hour)) + 1; total != want {
+		t.Errorf("emitted %d events, want %d", total, want)
+	}
+}
+
+func TestRenewFailingSubscription(t *testing.T) {
+	store := memory.New()
+	ctx := tenant.WithTenant(context.Background(), "default")
+	now := time.Date(2025, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	failing := addSubscription(t, ctx, store, "Netflix", "monthly", "2025-01-01")
+	healthy := addSubscription(t, ctx, store, "Spotify", "monthly", "2025-01-01")
+
+	events := &failingEvents{Storage: store, fail: failing}
+	s := NewSubscriptionService(store, store, events, store, store, nil, slog.New(slog.DiscardHandler))
+
+	// The failing subscription doesn't hold back the others.
+	n, err := s.Renew(ctx, now)
+	if err == nil {
+		t.Error("Renew() error = nil, want the failure reported")
+	}
+	if n != 3 {
+		t.Errorf("Renew() emitted %d events, want 3", n)
+	}
+
+	billed, err := store.GetBillingEvents(ctx, int(healthy))
+	if err != nil || len(billed) != 3 {
+		t.Errorf("healthy subscription has %d events, %v, want 3", len(billed), err)
+	}
+
+	events.fail = 0
+	if n, err := s.Renew(ctx, now); err != nil || n != 3 {
+		t.Errorf("Renew() once recovered = %d, %v, want 3", n, err)
+	}
+}
@@ -4,103 +4,385 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"subscription/internal/domain"
 	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/fx"
 	"subscription/internal/lib/logger/sl"
-	"subscription/internal/storage/postgres"
-
-	"github.com/google/uuid"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/lib/tracing"
+	"time"
 )
 
+const tracerName = "subscription/internal/usecases"
+
 type SubscriptionStorage interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
 	AddUserSubscription(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error)
 	GetUserSubscriptionById(ctx context.Context, id int) (*domain.UserSubscription, error)
-	GetUserSubscriptionsListByUUID(ctx context.Context, userID uuid.UUID) ([]*domain.UserSubscription, error)
+	ListUserSubscriptions(ctx context.Context, dto dto.ListUserSubs) (*domain.UserSubscriptionPage, error)
+	ExportUserSubscriptions(ctx context.Context, dto dto.ListUserSubs, fn func(sub *domain.UserSubscription) error) error
 	DeleteUserSubscriptionByID(ctx context.Context, id int) error
+	RestoreUserSubscription(ctx context.Context, id int) (*domain.UserSubscription, error)
+	PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) ([]*domain.UserSubscription, error)
 	UpdateUserSubscription(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error)
-	CalculateTotalCost(ctx context.Context, dto dto.TotalCost) (int64, error)
+	CalculateTotalCost(ctx context.Context, dto dto.TotalCost, rates *fx.Rates) (*domain.TotalCost, error)
+	CostAnalytics(ctx context.Context, dto dto.CostAnalytics, rates *fx.Rates) ([]*domain.CostBucket, error)
 }
 
 type UserSubscriptionService struct {
-	log     *slog.Logger
-	storage SubscriptionStorage
+	log      *slog.Logger
+	storage  SubscriptionStorage
+	history  HistoryStorage
+	events   BillingEventStorage
+	webhooks WebhookStorage
+	outbox   OutboxStorage
+	rates    fx.Provider
 }
 
-func NewSubscriptionService(storage *postgres.Storage, log *slog.Logger) *UserSubscriptionService {
-	return &UserSubscriptionService{storage: storage, log: log}
+func NewSubscriptionService(
+	storage SubscriptionStorage,
+	history HistoryStorage,
+	events BillingEventStorage,
+	webhooks WebhookStorage,
+	outbox OutboxStorage,
+	rates fx.Provider,
+	log *slog.Logger,
+) *UserSubscriptionService {
+	return &UserSubscriptionService{
+		storage:  storage,
+		history:  history,
+		events:   events,
+		webhooks: webhooks,
+		outbox:   outbox,
+		rates:    rates,
+		log:      log,
+	}
 }
 
 func (s *UserSubscriptionService) Add(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
 	const op = "subscription_service.Add"
 
-	id, err := s.storage.AddUserSubscription(ctx, dto)
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	var id int64
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = s.add(ctx, dto)
+		return err
+	})
 	if err != nil {
 		s.log.Error("can't add subscription", sl.Err(err))
+		tracing.Fail(span, err)
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return id, nil
 }
 
+// add creates a subscription and records it in the history. It must run in
+// a transaction.
+func (s *UserSubscriptionService) add(ctx context.Context, dto dto.CreateUserSubDTO) (int64, error) {
+	if dto.Currency == "" {
+		dto.Currency = domain.DefaultCurrency
+	}
+	if dto.BillingPeriod == "" {
+		dto.BillingPeriod = domain.DefaultBillingPeriod
+	}
+
+	if err := authorize(ctx, dto.UserID); err != nil {
+		return 0, err
+	}
+
+	id, err := s.storage.AddUserSubscription(ctx, dto)
+	if err != nil {
+		return 0, err
+	}
+
+	after, err := s.storage.GetUserSubscriptionById(ctx, int(id))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.record(ctx, id, domain.ActionCreated, nil, after); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 func (s *UserSubscriptionService) GetById(ctx context.Context, id int) (*domain.UserSubscription, error) {
 	const op = "subscription_service.GetById"
 
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
 	subscription, err := s.storage.GetUserSubscriptionById(ctx, id)
+	if err == nil {
+		err = owned(ctx, subscription)
+	}
 	if err != nil {
 		s.log.Error("can't get subscription", sl.Err(err))
+		tracing.Fail(span, err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return subscription, nil
 }
 
-func (s *UserSubscriptionService) GetListByUUID(ctx context.Context, userId uuid.UUID) ([]*domain.UserSubscription, error) {
-	const op = "subscription_service.GetListByUUID"
+func (s *UserSubscriptionService) List(ctx context.Context, filter dto.ListUserSubs) (*domain.UserSubscriptionPage, error) {
+	const op = "subscription_service.List"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
 
-	subs, err := s.storage.GetUserSubscriptionsListByUUID(ctx, userId)
+	if err := scope(ctx, &filter.UserID); err != nil {
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page, err := s.storage.ListUserSubscriptions(ctx, filter)
 	if err != nil {
 		s.log.Error("can't get subscriptions list", sl.Err(err))
+		tracing.Fail(span, err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return subs, nil
+	return page, nil
+}
+
+// Export streams every subscription matching filter to fn, ignoring its
+// limit and cursor.
+func (s *UserSubscriptionService) Export(
+	ctx context.Context,
+	filter dto.ListUserSubs,
+	fn func(sub *domain.UserSubscription) error,
+) error {
+	const op = "subscription_service.Export"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	if err := scope(ctx, &filter.UserID); err != nil {
+		tracing.Fail(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.ExportUserSubscriptions(ctx, filter, fn); err != nil {
+		s.log.Error("can't export subscriptions", sl.Err(err))
+		tracing.Fail(span, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
 }
 
 func (s *UserSubscriptionService) DeleteById(ctx context.Context, id int) error {
 	const op = "subscription_service.DeleteById"
 
-	err := s.storage.DeleteUserSubscriptionByID(ctx, id)
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.storage.GetUserSubscriptionById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := owned(ctx, before); err != nil {
+			return err
+		}
+
+		if err := s.storage.DeleteUserSubscriptionByID(ctx, id); err != nil {
+			return err
+		}
+
+		return s.record(ctx, int64(id), domain.ActionDeleted, before, nil)
+	})
 
 	if err != nil {
 		s.log.Error("can't delete subscription", sl.Err(err))
+		tracing.Fail(span, err)
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+func (s *UserSubscriptionService) RestoreById(ctx context.Context, id int) (*domain.UserSubscription, error) {
+	const op = "subscription_service.RestoreById"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	var sub *domain.UserSubscription
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		sub, err = s.storage.RestoreUserSubscription(ctx, id)
+		if err != nil {
+			return err
+		}
+		// Restoring someone else's subscription is rolled back.
+		if err := owned(ctx, sub); err != nil {
+			return err
+		}
+
+		return s.record(ctx, int64(id), domain.ActionRestored, nil, sub)
+	})
+
+	if err != nil {
+		s.log.Error("can't restore subscription", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+// PurgeDeleted permanently removes subscriptions soft-deleted more than
+// retention ago in every tenant and returns their ids.
+func (s *UserSubscriptionService) PurgeDeleted(ctx context.Context, retention time.Duration) ([]int64, error) {
+	const op = "subscription_service.PurgeDeleted"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	var ids []int64
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		subs, err := s.storage.PurgeDeletedSubscriptions(ctx, retention)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range subs {
+			id, err := strconv.ParseInt(sub.ID, 10, 64)
+			if err != nil {
+				return err
+			}
+			// The purge is recorded in the tenant of the subscription.
+			if err := s.record(tenant.WithTenant(ctx, sub.TenantID), id, domain.ActionPurged, nil, nil); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		s.log.Error("can't purge deleted subscriptions", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
 func (s *UserSubscriptionService) UpdateById(ctx context.Context, dto dto.UpdateUserSubDTO) (*domain.UserSubscription, error) {
 	const op = "subscription_service.UpdateById"
 
-	sub, err := s.storage.UpdateUserSubscription(ctx, dto)
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	if dto.Currency == "" {
+		dto.Currency = domain.DefaultCurrency
+	}
+	if dto.BillingPeriod == "" {
+		dto.BillingPeriod = domain.DefaultBillingPeriod
+	}
+
+	var sub *domain.UserSubscription
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.storage.GetUserSubscriptionById(ctx, dto.ID)
+		if err != nil {
+			return err
+		}
+		if err := owned(ctx, before); err != nil {
+			return err
+		}
+		if err := authorize(ctx, dto.UserID); err != nil {
+			return err
+		}
+
+		sub, err = s.storage.UpdateUserSubscription(ctx, dto)
+		if err != nil {
+			return err
+		}
+
+		return s.record(ctx, int64(dto.ID), domain.ActionUpdated, before, sub)
+	})
 
 	if err != nil {
-		s.log.Error("can't delete subscription", sl.Err(err))
+		s.log.Error("can't update subscription", sl.Err(err))
+		tracing.Fail(span, err)
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return sub, nil
 }
 
-func (s *UserSubscriptionService) TotalCost(ctx context.Context, cost dto.TotalCost) (int64, error) {
+func (s *UserSubscriptionService) TotalCost(ctx context.Context, cost dto.TotalCost) (*domain.TotalCost, error) {
 	const op = "subscription_service.TotalCost"
 
-	totalCost, err := s.storage.CalculateTotalCost(ctx, cost)
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	if cost.Currency == "" {
+		cost.Currency = domain.DefaultCurrency
+	}
+
+	if err := scope(ctx, &cost.UserID); err != nil {
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rates, err := s.rates.Rates(ctx)
+	if err != nil {
+		s.log.Error("can't get exchange rates", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	totalCost, err := s.storage.CalculateTotalCost(ctx, cost, rates)
 
 	if err != nil {
 		s.log.Error("can't get totalCost list", sl.Err(err))
-		return 0, fmt.Errorf("%s: %w", op, err)
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return totalCost, nil
 }
+
+func (s *UserSubscriptionService) CostAnalytics(ctx context.Context, analytics dto.CostAnalytics) ([]*domain.CostBucket, error) {
+	const op = "subscription_service.CostAnalytics"
+
+	ctx, span := tracing.Start(ctx, tracerName, op)
+	defer span.End()
+
+	if analytics.Currency == "" {
+		analytics.Currency = domain.DefaultCurrency
+	}
+
+	if err := scope(ctx, &analytics.UserID); err != nil {
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rates, err := s.rates.Rates(ctx)
+	if err != nil {
+		s.log.Error("can't get exchange rates", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	buckets, err := s.storage.CostAnalytics(ctx, analytics, rates)
+
+	if err != nil {
+		s.log.Error("can't get cost analytics", sl.Err(err))
+		tracing.Fail(span, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,65 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"subscription/internal/domain"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/storage"
+	"time"
+)
+
+type TenantStorage interface {
+	TenantReports(ctx context.Context, tenantID string, today time.Time) ([]*domain.TenantReport, error)
+}
+
+type TenantService struct {
+	log     *slog.Logger
+	storage TenantStorage
+}
+
+func NewTenantService(storage TenantStorage, log *slog.Logger) *TenantService {
+	return &TenantService{storage: storage, log: log}
+}
+
+// List reports every tenant to platform callers and only their own tenant
+// to callers bound to one.
+func (s *TenantService) List(ctx context.Context) ([]*domain.TenantReport, error) {
+	const op = "tenant_service.List"
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	reports, err := s.storage.TenantReports(ctx, boundTenant(ctx), today)
+	if err != nil {
+		s.log.Error("can't report tenants", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reports, nil
+}
+
+// Report sums up one tenant. Other tenants are reported as missing to
+// callers bound to a tenant, so that their ids can't be probed.
+func (s *TenantService) Report(ctx context.Context, id string) (*domain.TenantReport, error) {
+	const op = "tenant_service.Report"
+
+	if bound := boundTenant(ctx); bound != "" && bound != id {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrTenantNotFound)
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	reports, err := s.storage.TenantReports(ctx, id, today)
+	if err != nil {
+		s.log.Error("can't report tenant", slog.String("tenant_id", id), sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrTenantNotFound)
+	}
+
+	return reports[0], nil
+}
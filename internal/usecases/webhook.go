@@ -0,0 +1,365 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/billing"
+	"subscription/internal/lib/logger/sl"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/lib/webhook"
+	"subscription/internal/storage"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookStorage interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	AddWebhook(ctx context.Context, hook *domain.Webhook) error
+	ListWebhooks(ctx context.Context) ([]*domain.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	WebhooksFor(ctx context.Context, event string) ([]*domain.Webhook, error)
+	AddWebhookDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *domain.WebhookDelivery, attempt *domain.WebhookAttempt) error
+	ListWebhookDeliveries(ctx context.Context, webhookID int, filter dto.ListWebhookDeliveries) ([]*domain.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int) (*domain.WebhookDelivery, error)
+	RedeliverWebhookDelivery(ctx context.Context, id int, now time.Time) (*domain.WebhookDelivery, error)
+	ExpiringSubscriptions(ctx context.Context, from, to time.Time) ([]*domain.UserSubscription, error)
+	AddExpiryNotice(ctx context.Context, subscriptionID int64, event, endDate string) (bool, error)
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, hook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error)
+}
+
+// DeliveryPolicy bounds how webhook deliveries are retried.
+type DeliveryPolicy struct {
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+	// Lease hides a claimed delivery from other dispatchers while it is
+	// attempted. A dispatcher dying mid-attempt leaves it to be retried once
+	// the lease runs out.
+	Lease time.Duration
+}
+
+// expiredLookback limits the expiry scan to subscriptions that ended
+// recently, so that enabling it doesn't announce every past expiry.
+const expiredLookback = 7 * 24 * time.Hour
+
+type WebhookService struct {
+	log     *slog.Logger
+	storage WebhookStorage
+	sender  WebhookSender
+	policy  DeliveryPolicy
+}
+
+func NewWebhookService(
+	storage WebhookStorage,
+	sender WebhookSender,
+	policy DeliveryPolicy,
+	log *slog.Logger,
+) *WebhookService {
+	return &WebhookService{storage: storage, sender: sender, policy: policy, log: log}
+}
+
+func (s *WebhookService) Register(ctx context.Context, dto dto.CreateWebhookDTO) (*domain.Webhook, error) {
+	const op = "webhook_service.Register"
+
+	hook := &domain.Webhook{
+		URL:    dto.URL,
+		Events: slices.Compact(slices.Sorted(slices.Values(dto.Events))),
+		Secret: dto.Secret,
+	}
+
+	if hook.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		hook.Secret = secret
+	}
+
+	if err := s.storage.AddWebhook(ctx, hook); err != nil {
+		s.log.Error("can't add webhook", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hook, nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]*domain.Webhook, error) {
+	const op = "webhook_service.List"
+
+	hooks, err := s.storage.ListWebhooks(ctx)
+	if err != nil {
+		s.log.Error("can't list webhooks", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+
+	return hooks, nil
+}
+
+func (s *WebhookService) GetById(ctx context.Context, id int) (*domain.Webhook, error) {
+	const op = "webhook_service.GetById"
+
+	hook, err := s.storage.GetWebhook(ctx, id)
+	if err != nil {
+		s.log.Error("can't get webhook", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	hook.Secret = ""
+
+	return hook, nil
+}
+
+func (s *WebhookService) DeleteById(ctx context.Context, id int) error {
+	const op = "webhook_service.DeleteById"
+
+	if err := s.storage.DeleteWebhook(ctx, id); err != nil {
+		s.log.Error("can't delete webhook", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the latest deliveries of a webhook, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID int, filter dto.ListWebhookDeliveries) ([]*domain.WebhookDelivery, error) {
+	const op = "webhook_service.Deliveries"
+
+	if _, err := s.storage.GetWebhook(ctx, webhookID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := s.storage.ListWebhookDeliveries(ctx, webhookID, filter)
+	if err != nil {
+		s.log.Error("can't list webhook deliveries", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// Delivery returns a delivery together with the log of its attempts.
+func (s *WebhookService) Delivery(ctx context.Context, id int) (*domain.WebhookDelivery, error) {
+	const op = "webhook_service.Delivery"
+
+	delivery, err := s.storage.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		s.log.Error("can't get webhook delivery", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// Redeliver queues a delivery for an immediate attempt with a fresh retry
+// budget, whatever its status.
+func (s *WebhookService) Redeliver(ctx context.Context, id int) (*domain.WebhookDelivery, error) {
+	const op = "webhook_service.Redeliver"
+
+	delivery, err := s.storage.RedeliverWebhookDelivery(ctx, id, time.Now().UTC())
+	if err != nil {
+		s.log.Error("can't redeliver webhook delivery", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return delivery, nil
+}
+
+// Dispatch attempts up to limit due deliveries and returns how many it
+// attempted. Failed attempts are retried with exponential backoff until the
+// policy runs out of attempts.
+//
+// Deliveries are claimed one at a time, right before they are attempted,
+// so that none outlives its lease waiting for the others. A delivery whose
+// attempt can't be recorded is left to be retried once its lease runs out.
+func (s *WebhookService) Dispatch(ctx context.Context, now time.Time, limit int) (int, error) {
+	const op = "webhook_service.Dispatch"
+
+	started := time.Now()
+	attempted := 0
+	for attempted < limit && ctx.Err() == nil {
+		// Leases start with the claim, not with the call.
+		claimedAt := now.Add(time.Since(started))
+		deliveries, err := s.storage.ClaimWebhookDeliveries(ctx, claimedAt, s.policy.Lease, 1)
+		if err != nil {
+			s.log.Error("can't claim webhook deliveries", sl.Err(err))
+			return attempted, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(deliveries) == 0 {
+			break
+		}
+
+		attempted++
+		if err := s.deliver(ctx, deliveries[0]); err != nil {
+			s.log.Error("can't record webhook delivery attempt", slog.Int64("delivery_id", deliveries[0].ID), sl.Err(err))
+		}
+	}
+
+	return attempted, nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	ctx = tenant.WithTenant(ctx, delivery.TenantID)
+
+	hook, err := s.storage.GetWebhook(ctx, int(delivery.WebhookID))
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			// Deleted after the delivery was claimed, its deliveries
+			// went with it.
+			return nil
+		}
+		return err
+	}
+
+	started := time.Now()
+	code, sendErr := s.sender.Send(ctx, hook, delivery)
+	finished := time.Now().UTC()
+
+	attempt := &domain.WebhookAttempt{
+		DeliveryID:  delivery.ID,
+		Attempt:     delivery.Attempts + 1,
+		StatusCode:  code,
+		DurationMs:  finished.Sub(started).Milliseconds(),
+		AttemptedAt: finished,
+	}
+
+	delivery.Attempts = attempt.Attempt
+	delivery.LastStatusCode = code
+	delivery.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = domain.DeliveryDelivered
+		delivery.DeliveredAt = &finished
+	case delivery.Attempts >= s.policy.MaxAttempts:
+		attempt.Error = sendErr.Error()
+		delivery.LastError = attempt.Error
+		delivery.Status = domain.DeliveryFailed
+	default:
+		attempt.Error = sendErr.Error()
+		delivery.LastError = attempt.Error
+		delivery.NextAttemptAt = finished.Add(webhook.Backoff(delivery.Attempts, s.policy.RetryBase, s.policy.RetryMax))
+	}
+
+	if sendErr != nil {
+		s.log.Warn("webhook delivery failed",
+			slog.Int64("delivery_id", delivery.ID),
+			slog.Int("attempt", attempt.Attempt),
+			slog.String("status", delivery.Status),
+			sl.Err(sendErr),
+		)
+	}
+
+	return s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		return s.storage.RecordWebhookAttempt(ctx, delivery, attempt)
+	})
+}
+
+// ScanExpiries queues subscription.expiring for subscriptions ending within
+// notice of now and subscription.expired for those that ended. Each is sent
+// once per end date, so moving the end date announces it again.
+func (s *WebhookService) ScanExpiries(ctx context.Context, now time.Time, notice time.Duration) (int, error) {
+	const op = "webhook_service.ScanExpiries"
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var queued int
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		subs, err := s.storage.ExpiringSubscriptions(ctx, today.Add(-expiredLookback), today.Add(notice))
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range subs {
+			ctx := tenant.WithTenant(ctx, sub.TenantID)
+
+			end, err := time.Parse(billing.DateLayout, sub.EndDate)
+			if err != nil {
+				return fmt.Errorf("invalid end_date of subscription %s: %w", sub.ID, err)
+			}
+
+			event := domain.EventSubscriptionExpiring
+			if end.Before(today) {
+				event = domain.EventSubscriptionExpired
+			}
+
+			id, err := strconv.ParseInt(sub.ID, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			added, err := s.storage.AddExpiryNotice(ctx, id, event, sub.EndDate)
+			if err != nil {
+				return err
+			}
+			if !added {
+				continue
+			}
+
+			if err := notify(ctx, s.storage, event, domain.WebhookEventData{Subscription: sub}); err != nil {
+				return err
+			}
+			queued++
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.log.Error("can't scan expiring subscriptions", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return queued, nil
+}
+
+// notify queues the event for every webhook subscribed to it. It must run in
+// the transaction of the change the event describes, so that the event is
+// sent if and only if the change commits.
+func notify(ctx context.Context, webhooks WebhookStorage, event string, data domain.WebhookEventData) error {
+	hooks, err := webhooks.WebhooksFor(ctx, event)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	payload := domain.WebhookEvent{
+		ID:        uuid.NewString(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		err := webhooks.AddWebhookDelivery(ctx, &domain.WebhookDelivery{
+			WebhookID: hook.ID,
+			EventID:   payload.ID,
+			Event:     event,
+			Payload:   raw,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
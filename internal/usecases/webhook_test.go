@@ -0,0 +1,96 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"subscription/internal/domain"
+	"subscription/internal/http_server/dto"
+	"subscription/internal/lib/tenant"
+	"subscription/internal/lib/webhook"
+	"subscription/internal/storage/memory"
+)
+
+// fakeSender answers deliveries with the codes queued, then with 200.
+type fakeSender struct {
+	codes []int
+	sent  int
+}
+
+func (f *fakeSender) Send(ctx context.Context, hook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	f.sent++
+	if len(f.codes) == 0 {
+		return http.StatusOK, nil
+	}
+
+	code := f.codes[0]
+	f.codes = f.codes[1:]
+	if code < 200 || code > 299 {
+		return code, errors.New(http.StatusText(code))
+	}
+	return code, nil
+}
+
+func TestDispatchRetries(t *testing.T) {
+	policy := DeliveryPolicy{MaxAttempts: 3, RetryBase: time.Minute, RetryMax: time.Hour, Lease: time.Minute}
+
+	tests := []struct {
+		name     string
+		codes    []int
+		status   string
+		attempts int
+	}{
+		{"delivered at once", nil, domain.DeliveryDelivered, 1},
+		{"delivered on retry", []int{http.StatusInternalServerError, http.StatusBadGateway}, domain.DeliveryDelivered, 3},
+		{"failed after the last attempt", []int{http.StatusInternalServerError, 0, http.StatusNotFound, http.StatusInternalServerError}, domain.DeliveryFailed, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			sender := &fakeSender{codes: tt.codes}
+			s := NewWebhookService(store, sender, policy, slog.New(slog.DiscardHandler))
+			ctx := tenant.WithTenant(context.Background(), "default")
+
+			hook, err := s.Register(ctx, dto.CreateWebhookDTO{URL: "https://example.com/hook", Events: []string{domain.EventSubscriptionCreated}})
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if err := notify(ctx, store, domain.EventSubscriptionCreated, domain.WebhookEventData{}); err != nil {
+				t.Fatalf("notify() error = %v", err)
+			}
+
+			now := time.Now()
+			for attempt := 1; attempt <= policy.MaxAttempts+1; attempt++ {
+				n, err := s.Dispatch(ctx, now, 10)
+				if err != nil {
+					t.Fatalf("Dispatch() error = %v", err)
+				}
+				if want := min(1, max(tt.attempts-attempt+1, 0)); n != want {
+					t.Fatalf("dispatch %d attempted %d deliveries, want %d", attempt, n, want)
+				}
+
+				// Nothing is due again before the backoff elapsed.
+				if n, _ := s.Dispatch(ctx, now.Add(policy.RetryBase/2), 10); n != 0 {
+					t.Fatalf("delivery attempted again before the backoff after attempt %d", attempt)
+				}
+				now = time.Now().Add(webhook.Backoff(attempt, policy.RetryBase, policy.RetryMax) + time.Second)
+			}
+
+			deliveries, err := s.Deliveries(ctx, int(hook.ID), dto.ListWebhookDeliveries{Limit: 10})
+			if err != nil || len(deliveries) != 1 {
+				t.Fatalf("Deliveries() = %d deliveries, %v, want 1", len(deliveries), err)
+			}
+			if d := deliveries[0]; d.Status != tt.status || d.Attempts != tt.attempts {
+				t.Errorf("delivery %s after %d attempts, want %s after %d", d.Status, d.Attempts, tt.status, tt.attempts)
+			}
+			if sender.sent != tt.attempts {
+				t.Errorf("sent %d times, want %d", sender.sent, tt.attempts)
+			}
+		})
+	}
+}
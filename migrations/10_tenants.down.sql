@@ -0,0 +1,54 @@
+-- Without tenants the subscriptions of other tenants could conflict with
+-- those of the default one, so they are dropped. The history is append-only
+-- and keeps its rows.
+DELETE FROM user_subscriptions WHERE tenant_id != 'default';
+DELETE FROM webhooks WHERE tenant_id != 'default';
+DELETE FROM outbox WHERE tenant_id != 'default';
+
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE webhook_deliveries
+    DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS webhooks_tenant_idx;
+
+ALTER TABLE webhooks
+    DROP COLUMN IF EXISTS tenant_id;
+
+ALTER TABLE billing_events
+    DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS subscription_history_subscription_idx;
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (subscription_id, id);
+
+ALTER TABLE subscription_history
+    DROP COLUMN IF EXISTS tenant_id;
+
+DROP INDEX IF EXISTS user_subscriptions_tenant_idx;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS no_overlap;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT no_overlap
+    EXCLUDE USING gist (
+        user_id WITH =,
+        service_name WITH =,
+        daterange(
+            start_date,
+            COALESCE(end_date, DATE '9999-12-31'),
+            '[]'
+        ) WITH &&
+    ) WHERE (deleted_at IS NULL);
+
+DROP INDEX IF EXISTS unique_subscription;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_subscription
+    ON user_subscriptions (user_id, service_name, start_date, end_date)
+    WHERE deleted_at IS NULL;
+
+ALTER TABLE user_subscriptions
+    DROP COLUMN IF EXISTS tenant_id;
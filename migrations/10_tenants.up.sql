@@ -0,0 +1,71 @@
+-- Every row belongs to a tenant. Rows written before tenants existed belong
+-- to the default one, new rows always name theirs.
+ALTER TABLE user_subscriptions
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE user_subscriptions
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Subscriptions of different tenants never conflict.
+DROP INDEX IF EXISTS unique_subscription;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_subscription
+    ON user_subscriptions (tenant_id, user_id, service_name, start_date, end_date)
+    WHERE deleted_at IS NULL;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS no_overlap;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT no_overlap
+    EXCLUDE USING gist (
+        tenant_id WITH =,
+        user_id WITH =,
+        service_name WITH =,
+        daterange(
+            start_date,
+            COALESCE(end_date, DATE '9999-12-31'),
+            '[]'
+        ) WITH &&
+    ) WHERE (deleted_at IS NULL);
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_tenant_idx
+    ON user_subscriptions (tenant_id, id);
+
+ALTER TABLE subscription_history
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE subscription_history
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS subscription_history_subscription_idx;
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (tenant_id, subscription_id, id);
+
+ALTER TABLE billing_events
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE billing_events
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE webhooks
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE webhooks
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS webhooks_tenant_idx
+    ON webhooks (tenant_id, id);
+
+ALTER TABLE webhook_deliveries
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+ALTER TABLE outbox
+    ALTER COLUMN tenant_id DROP DEFAULT;
@@ -0,0 +1,11 @@
+-- rate_limit_buckets holds the token buckets of the rate limiter shared by
+-- the replicas. allowed tells whether the last request got a token.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key VARCHAR(512) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx
+    ON rate_limit_buckets (updated_at);
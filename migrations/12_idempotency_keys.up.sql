@@ -0,0 +1,14 @@
+-- idempotency_keys holds the first response to the requests sent with an
+-- Idempotency-Key, keyed by tenant, client and key. status is NULL while the
+-- first request is being handled.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL,
+    status INTEGER,
+    content_type TEXT,
+    body BYTEA,
+    created_at TIMESTAMP NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx
+    ON idempotency_keys (created_at);
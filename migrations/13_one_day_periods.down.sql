@@ -0,0 +1,10 @@
+-- One-day subscriptions don't pass the previous check, so they are dropped.
+DELETE FROM user_subscriptions WHERE start_date = end_date;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS valid_period;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT valid_period CHECK (
+                                      end_date IS NULL OR start_date < end_date
+                                  );
@@ -0,0 +1,9 @@
+-- End dates name the last day of a subscription, so a subscription may start
+-- and end on the same day.
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS valid_period;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT valid_period CHECK (
+                                      end_date IS NULL OR start_date <= end_date
+                                  );
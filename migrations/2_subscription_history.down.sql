@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_history;
+DROP FUNCTION IF EXISTS forbid_subscription_history_change();
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS subscription_history (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    before JSONB,
+    after JSONB,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (subscription_id, id);
+
+CREATE OR REPLACE FUNCTION forbid_subscription_history_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'subscription_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscription_history_immutable
+    BEFORE UPDATE OR DELETE ON subscription_history
+    FOR EACH ROW EXECUTE FUNCTION forbid_subscription_history_change();
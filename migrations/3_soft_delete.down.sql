@@ -0,0 +1,26 @@
+DELETE FROM user_subscriptions WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS user_subscriptions_deleted_at_idx;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS no_overlap;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT no_overlap
+    EXCLUDE USING gist (
+        user_id WITH =,
+        service_name WITH =,
+        daterange(
+            start_date,
+            COALESCE(end_date, DATE '9999-12-31'),
+            '[]'
+        ) WITH &&
+    );
+
+DROP INDEX IF EXISTS unique_subscription;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT unique_subscription UNIQUE (user_id, service_name, start_date, end_date);
+
+ALTER TABLE user_subscriptions
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,30 @@
+ALTER TABLE user_subscriptions
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Soft-deleted rows must not block new subscriptions, so both constraints
+-- only cover live rows from now on.
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS unique_subscription;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_subscription
+    ON user_subscriptions (user_id, service_name, start_date, end_date)
+    WHERE deleted_at IS NULL;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS no_overlap;
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT no_overlap
+    EXCLUDE USING gist (
+        user_id WITH =,
+        service_name WITH =,
+        daterange(
+            start_date,
+            COALESCE(end_date, DATE '9999-12-31'),
+            '[]'
+        ) WITH &&
+    ) WHERE (deleted_at IS NULL);
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_deleted_at_idx
+    ON user_subscriptions (deleted_at)
+    WHERE deleted_at IS NOT NULL;
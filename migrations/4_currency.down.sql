@@ -0,0 +1,5 @@
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS valid_currency;
+
+ALTER TABLE user_subscriptions
+    DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,5 @@
+ALTER TABLE user_subscriptions
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT valid_currency CHECK (currency ~ '^[A-Z]{3}$');
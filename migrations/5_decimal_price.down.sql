@@ -0,0 +1,6 @@
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS valid_price;
+
+-- Fractional prices are rounded to whole units.
+ALTER TABLE user_subscriptions
+    ALTER COLUMN price TYPE INT USING ROUND(price)::INT;
@@ -0,0 +1,6 @@
+-- Integer prices were whole currency units, so they convert as is.
+ALTER TABLE user_subscriptions
+    ALTER COLUMN price TYPE NUMERIC(18, 4) USING price::NUMERIC(18, 4);
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT valid_price CHECK (price >= 0);
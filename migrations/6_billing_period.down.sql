@@ -0,0 +1,9 @@
+UPDATE user_subscriptions
+SET start_date = DATE_TRUNC('month', start_date)::DATE,
+    end_date = DATE_TRUNC('month', end_date)::DATE;
+
+ALTER TABLE user_subscriptions
+    DROP CONSTRAINT IF EXISTS valid_billing_period;
+
+ALTER TABLE user_subscriptions
+    DROP COLUMN IF EXISTS billing_period;
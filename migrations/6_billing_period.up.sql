@@ -0,0 +1,13 @@
+ALTER TABLE user_subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period VARCHAR(16) NOT NULL DEFAULT 'monthly';
+
+ALTER TABLE user_subscriptions
+    ADD CONSTRAINT valid_billing_period
+    CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly'));
+
+-- Dates used to be whole months with the end month included. End dates now
+-- name the last day of the subscription, so existing ones move to the end of
+-- their month.
+UPDATE user_subscriptions
+SET end_date = (DATE_TRUNC('month', end_date) + INTERVAL '1 month - 1 day')::DATE
+WHERE end_date IS NOT NULL;
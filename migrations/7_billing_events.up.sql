@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS billing_events (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    amount NUMERIC(18, 4) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- A period is billed once, however many schedulers see it due.
+    CONSTRAINT unique_billing_period UNIQUE (subscription_id, period_start),
+
+    CONSTRAINT valid_billing_event_period CHECK (period_start <= period_end)
+    );
@@ -0,0 +1,12 @@
+-- outbox holds subscription events until the relay publishes them to the
+-- broker, which removes them. A batch being published is claimed until
+-- claimed_until, so that a relay dying mid-batch leaves it to another one.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    partition_key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    claimed_until TIMESTAMP
+    );
@@ -0,0 +1,32 @@
+-- Without tenants the subscriptions of other tenants could conflict with
+-- those of the default one, so they are dropped. The history is append-only
+-- and keeps its rows.
+DELETE FROM user_subscriptions WHERE tenant_id != 'default';
+DELETE FROM webhooks WHERE tenant_id != 'default';
+DELETE FROM outbox WHERE tenant_id != 'default';
+
+ALTER TABLE outbox DROP COLUMN tenant_id;
+
+ALTER TABLE webhook_deliveries DROP COLUMN tenant_id;
+
+DROP INDEX IF EXISTS webhooks_tenant_idx;
+
+ALTER TABLE webhooks DROP COLUMN tenant_id;
+
+ALTER TABLE billing_events DROP COLUMN tenant_id;
+
+DROP INDEX IF EXISTS subscription_history_subscription_idx;
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (subscription_id, id);
+
+ALTER TABLE subscription_history DROP COLUMN tenant_id;
+
+DROP INDEX IF EXISTS user_subscriptions_tenant_idx;
+
+DROP INDEX IF EXISTS user_subscriptions_user_service_idx;
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_user_service_idx
+    ON user_subscriptions (user_id, service_name, start_date);
+
+ALTER TABLE user_subscriptions DROP COLUMN tenant_id;
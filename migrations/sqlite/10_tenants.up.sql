@@ -0,0 +1,30 @@
+-- Every row belongs to a tenant. Rows written before tenants existed belong
+-- to the default one, new rows always name theirs. The unique_subscription
+-- and no_overlap checks of the application compare tenants too.
+ALTER TABLE user_subscriptions ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS user_subscriptions_user_service_idx;
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_user_service_idx
+    ON user_subscriptions (tenant_id, user_id, service_name, start_date);
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_tenant_idx
+    ON user_subscriptions (tenant_id, id);
+
+ALTER TABLE subscription_history ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS subscription_history_subscription_idx;
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (tenant_id, subscription_id, id);
+
+ALTER TABLE billing_events ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE webhooks ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS webhooks_tenant_idx
+    ON webhooks (tenant_id, id);
+
+ALTER TABLE webhook_deliveries ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE outbox ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
@@ -0,0 +1,12 @@
+-- rate_limit_buckets holds the token buckets of the rate limiter shared by
+-- the replicas. allowed tells whether the last request got a token,
+-- updated_at is in Unix seconds.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    allowed INTEGER NOT NULL,
+    updated_at REAL NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx
+    ON rate_limit_buckets (updated_at);
@@ -0,0 +1,49 @@
+-- End dates name the last day of a subscription, so a subscription may start
+-- and end on the same day. SQLite can't change a check, the table is rebuilt.
+CREATE TABLE user_subscriptions_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_name TEXT NOT NULL,
+    price INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    deleted_at TEXT,
+    currency TEXT NOT NULL DEFAULT 'RUB'
+        CHECK (length(currency) = 3 AND currency = upper(currency)),
+    billing_period TEXT NOT NULL DEFAULT 'monthly'
+        CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly')),
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+
+    CONSTRAINT valid_period CHECK (
+                                      end_date IS NULL OR start_date <= end_date
+                                  )
+    );
+
+INSERT INTO user_subscriptions_new (
+    id, service_name, price, user_id, start_date, end_date, created_at,
+    updated_at, deleted_at, currency, billing_period, tenant_id
+)
+SELECT id, service_name, price, user_id, start_date, end_date, created_at,
+       updated_at, deleted_at, currency, billing_period, tenant_id
+FROM user_subscriptions;
+
+-- Ids of purged subscriptions stay taken.
+DELETE FROM sqlite_sequence WHERE name = 'user_subscriptions_new';
+INSERT INTO sqlite_sequence (name, seq)
+SELECT 'user_subscriptions_new', seq FROM sqlite_sequence WHERE name = 'user_subscriptions';
+
+DROP TABLE user_subscriptions;
+
+ALTER TABLE user_subscriptions_new RENAME TO user_subscriptions;
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_user_service_idx
+    ON user_subscriptions (tenant_id, user_id, service_name, start_date);
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_tenant_idx
+    ON user_subscriptions (tenant_id, id);
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_deleted_at_idx
+    ON user_subscriptions (deleted_at)
+    WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,19 @@
+-- SQLite has no btree_gist: unique_subscription and no_overlap are enforced
+-- by the application inside a write transaction.
+CREATE TABLE IF NOT EXISTS user_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_name TEXT NOT NULL,
+    price INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+
+    CONSTRAINT valid_period CHECK (
+                                      end_date IS NULL OR start_date < end_date
+                                  )
+    );
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_user_service_idx
+    ON user_subscriptions (user_id, service_name, start_date);
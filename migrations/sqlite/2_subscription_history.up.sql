@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS subscription_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    actor TEXT NOT NULL,
+    request_id TEXT,
+    created_at TEXT NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS subscription_history_subscription_idx
+    ON subscription_history (subscription_id, id);
+
+CREATE TRIGGER IF NOT EXISTS subscription_history_no_update
+    BEFORE UPDATE ON subscription_history
+BEGIN
+    SELECT RAISE(ABORT, 'subscription_history is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS subscription_history_no_delete
+    BEFORE DELETE ON subscription_history
+BEGIN
+    SELECT RAISE(ABORT, 'subscription_history is append-only');
+END;
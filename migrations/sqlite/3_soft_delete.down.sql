@@ -0,0 +1,5 @@
+DELETE FROM user_subscriptions WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS user_subscriptions_deleted_at_idx;
+
+ALTER TABLE user_subscriptions DROP COLUMN deleted_at;
@@ -0,0 +1,5 @@
+ALTER TABLE user_subscriptions ADD COLUMN deleted_at TEXT;
+
+CREATE INDEX IF NOT EXISTS user_subscriptions_deleted_at_idx
+    ON user_subscriptions (deleted_at)
+    WHERE deleted_at IS NOT NULL;
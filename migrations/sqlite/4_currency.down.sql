@@ -0,0 +1 @@
+ALTER TABLE user_subscriptions DROP COLUMN currency;
@@ -0,0 +1,2 @@
+ALTER TABLE user_subscriptions ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB'
+    CHECK (length(currency) = 3 AND currency = upper(currency));
@@ -0,0 +1 @@
+UPDATE user_subscriptions SET price = CAST(ROUND(price / 10000.0) AS INTEGER);
@@ -0,0 +1,3 @@
+-- SQLite has no exact decimal type: price now holds 10^-4 units, so the whole
+-- unit prices stored so far are scaled.
+UPDATE user_subscriptions SET price = price * 10000;
@@ -0,0 +1,5 @@
+UPDATE user_subscriptions
+SET start_date = date(start_date, 'start of month'),
+    end_date = date(end_date, 'start of month');
+
+ALTER TABLE user_subscriptions DROP COLUMN billing_period;
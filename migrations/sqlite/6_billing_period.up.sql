@@ -0,0 +1,9 @@
+ALTER TABLE user_subscriptions ADD COLUMN billing_period TEXT NOT NULL DEFAULT 'monthly'
+    CHECK (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly'));
+
+-- Dates used to be whole months with the end month included. End dates now
+-- name the last day of the subscription, so existing ones move to the end of
+-- their month.
+UPDATE user_subscriptions
+SET end_date = date(end_date, 'start of month', '+1 month', '-1 day')
+WHERE end_date IS NOT NULL;
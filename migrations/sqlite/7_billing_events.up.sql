@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS billing_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    period_start TEXT NOT NULL,
+    period_end TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+
+    -- A period is billed once, however many schedulers see it due.
+    CONSTRAINT unique_billing_period UNIQUE (subscription_id, period_start),
+
+    CONSTRAINT valid_billing_event_period CHECK (period_start <= period_end)
+    );
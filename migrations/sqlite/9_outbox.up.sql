@@ -0,0 +1,12 @@
+-- outbox holds subscription events until the relay publishes them to the
+-- broker, which removes them. A batch being published is claimed until
+-- claimed_until, so that a relay dying mid-batch leaves it to another one.
+CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    partition_key TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    claimed_until TEXT
+    );